@@ -0,0 +1,8 @@
+package main
+
+import "embed"
+
+// assets holds the templates and static files, so that the binary can be copied anywhere on its own
+//
+//go:embed templates static
+var assets embed.FS
@@ -0,0 +1,511 @@
+// Package client connects to a Sibyl room as a player, for writing bots and other tools.
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/synacor/sibyl/roomstate"
+)
+
+// ErrNotConnected is returned when an action is sent while the client is not connected to the room
+var ErrNotConnected = errors.New("client: not connected")
+
+// ErrClosed is returned when an action is sent after the client was closed
+var ErrClosed = errors.New("client: closed")
+
+const (
+	// The server pings every 27 seconds, so a connection which hasn't heard anything for this long is dead
+	readWait = time.Minute
+
+	// Write timeout
+	writeWait = 10 * time.Second
+
+	// How long to wait before reconnecting, which doubles with every failed attempt up to maxReconnectWait
+	defaultReconnectWait = time.Second
+	maxReconnectWait     = 30 * time.Second
+)
+
+// The room page holds the room and its token in SibylConfig
+var (
+	configTokenRx = regexp.MustCompile(`Token:\s*("(?:[^"\\]|\\.)*")`)
+	configRoomRx  = regexp.MustCompile(`Room:\s*("(?:[^"\\]|\\.)*")`)
+)
+
+// The state of a room, which is shared with the text UI.
+type (
+	Card    = roomstate.Card
+	Round   = roomstate.Round
+	Message = roomstate.Message
+	State   = roomstate.State
+)
+
+// Options configure a Client. The zero value is ready to use.
+type Options struct {
+	// Username is the name to join with. The server picks one if it is empty.
+	Username string
+
+	// HTTPClient is used to fetch the room page. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Dialer is used to connect to the room. Defaults to websocket.DefaultDialer.
+	Dialer *websocket.Dialer
+
+	// ReconnectWait is how long to wait before the first attempt to reconnect. Defaults to a second.
+	ReconnectWait time.Duration
+}
+
+// request is an action sent to the server. It mirrors server.WsRequest.
+type request struct {
+	Action   string `json:"action"`
+	Card     int    `json:"card"`
+	Deck     string `json:"deck"`
+	Room     string `json:"room"`
+	Token    string `json:"token"`
+	Value    string `json:"value"`
+	PlayerID int    `json:"playerID"`
+}
+
+// payload is anything the server sends: a state update, a chat message, or an error.
+type payload struct {
+	State
+	Message *Message `json:"message"`
+	Error   string   `json:"error"`
+}
+
+// Client is a player in a Sibyl room. It reconnects on its own if the connection drops, until it is
+// closed or removed from the room.
+type Client struct {
+	roomURL  *url.URL
+	options  Options
+	updates  chan State
+	messages chan Message
+	errors   chan error
+	closing  chan struct{}
+	done     chan struct{}
+
+	mu       sync.Mutex
+	conn     *websocket.Conn
+	room     string
+	token    string
+	username string
+	state    State
+	err      error
+	closed   bool
+}
+
+// Connect joins the room at roomURL, such as https://sibyl.example.com/r/Planning. It returns once the
+// client is connected.
+func Connect(roomURL string, options *Options) (*Client, error) {
+	u, err := url.Parse(roomURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(u.Path, "/r/") {
+		return nil, fmt.Errorf("client: %s is not the URL of a room", roomURL)
+	}
+
+	c := &Client{
+		roomURL:  u,
+		updates:  make(chan State, 1),
+		messages: make(chan Message, 64),
+		errors:   make(chan error, 16),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if options != nil {
+		c.options = *options
+	}
+	if c.options.HTTPClient == nil {
+		c.options.HTTPClient = http.DefaultClient
+	}
+	if c.options.Dialer == nil {
+		c.options.Dialer = websocket.DefaultDialer
+	}
+	if c.options.ReconnectWait <= 0 {
+		c.options.ReconnectWait = defaultReconnectWait
+	}
+	c.username = c.options.Username
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	go c.run(conn)
+	return c, nil
+}
+
+// Updates returns a channel of state updates. Only the latest update is kept for a slow reader, since
+// each update holds the complete state. The channel is closed when the client is closed or removed.
+func (c *Client) Updates() <-chan State {
+	return c.updates
+}
+
+// Messages returns a channel of chat messages and reactions. Messages are dropped if the channel is
+// not read from. The channel is closed when the client is closed or removed.
+func (c *Client) Messages() <-chan Message {
+	return c.messages
+}
+
+// Errors returns a channel of the errors the server reported about actions, such as a card played for
+// a deck the room no longer uses. The client keeps running after these. Errors are dropped if the
+// channel is not read from. The channel is closed when the client is closed or removed.
+func (c *Client) Errors() <-chan error {
+	return c.errors
+}
+
+// State returns the latest state of the room.
+func (c *Client) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state
+}
+
+// Err returns why the client stopped, such as being kicked from the room. It returns nil while the
+// client is running, and after it was closed with Close.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+// Done returns a channel which is closed once the client has stopped.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close leaves the room.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.closing)
+
+	if c.conn == nil {
+		return nil
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return c.conn.Close()
+}
+
+// SelectCard plays the card at the given index of the room's deck.
+func (c *Client) SelectCard(card int) error {
+	return c.SelectDeckCard(card, c.State().Deck)
+}
+
+// SelectDeckCard plays the card at the given index of the named deck, such as the deck of a state
+// which was shown to the player. If the room has switched to another deck since, the card isn't
+// played, and the error arrives on Errors.
+func (c *Client) SelectDeckCard(card int, deck string) error {
+	return c.send(request{Action: "select", Card: card, Deck: deck})
+}
+
+// Reveal shows everyone's cards.
+func (c *Client) Reveal() error {
+	return c.send(request{Action: "reveal"})
+}
+
+// Reset starts a new round with a new topic.
+func (c *Client) Reset() error {
+	return c.send(request{Action: "reset"})
+}
+
+// Revote starts another round on the same topic.
+func (c *Client) Revote() error {
+	return c.send(request{Action: "revote"})
+}
+
+// SetTopic sets what is being estimated.
+func (c *Client) SetTopic(topic string) error {
+	return c.send(request{Action: "topic", Value: topic})
+}
+
+// SetDeck switches the room to the named deck.
+func (c *Client) SetDeck(deck string) error {
+	return c.send(request{Action: "deck", Deck: deck})
+}
+
+// SetUsername changes the name of the player.
+func (c *Client) SetUsername(username string) error {
+	return c.send(request{Action: "username", Value: username})
+}
+
+// Chat sends a chat message to the room.
+func (c *Client) Chat(text string) error {
+	return c.send(request{Action: "chat", Value: text})
+}
+
+// React sends an emoji reaction to the room.
+func (c *Client) React(emoji string) error {
+	return c.send(request{Action: "react", Value: emoji})
+}
+
+// Kick removes a player from the room. With ban, they can't rejoin for a while.
+func (c *Client) Kick(playerID int, ban bool) error {
+	r := request{Action: "kick", PlayerID: playerID}
+	if ban {
+		r.Value = "ban"
+	}
+
+	return c.send(r)
+}
+
+// Mute turns a player into a spectator.
+func (c *Client) Mute(playerID int) error {
+	return c.send(request{Action: "mute", PlayerID: playerID})
+}
+
+// Unmute allows a spectator to vote again.
+func (c *Client) Unmute(playerID int) error {
+	return c.send(request{Action: "unmute", PlayerID: playerID})
+}
+
+// SetInsights sets whether the room's history includes a summary of how each player votes.
+func (c *Client) SetInsights(enabled bool) error {
+	r := request{Action: "insights", Value: "off"}
+	if enabled {
+		r.Value = "on"
+	}
+
+	return c.send(r)
+}
+
+// Commit commits the estimate of the revealed round to the story with the key, such as "PLAT-123". The
+// server must have a data_dir to keep it in.
+func (c *Client) Commit(story string) error {
+	return c.send(request{Action: "commit", Value: story})
+}
+
+// send sends an action to the room.
+func (c *Client) send(r request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	} else if c.conn == nil {
+		return ErrNotConnected
+	}
+
+	r.Room = c.room
+	r.Token = c.token
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteJSON(&r)
+}
+
+// run reads from the connection, and reconnects whenever it drops, until the client stops.
+func (c *Client) run(conn *websocket.Conn) {
+	defer func() {
+		close(c.updates)
+		close(c.messages)
+		close(c.errors)
+		close(c.done)
+	}()
+
+	wait := c.options.ReconnectWait
+	for {
+		if err := c.read(conn); err != nil {
+			c.stop(err)
+			return
+		}
+
+		for conn = nil; conn == nil; {
+			select {
+			case <-time.After(wait):
+			case <-c.closing:
+				return
+			}
+
+			var err error
+			if conn, err = c.connect(); err != nil && wait < maxReconnectWait {
+				wait *= 2
+			}
+		}
+		wait = c.options.ReconnectWait
+	}
+}
+
+// read reads from the connection until it drops. It returns an error if the client must not reconnect,
+// which is when the server closed the connection to remove the player, such as with a kick.
+func (c *Client) read(conn *websocket.Conn) error {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(readWait))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(readWait))
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(writeWait))
+	})
+
+	for {
+		var p payload
+		if err := conn.ReadJSON(&p); err != nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.conn = nil
+			if c.closed {
+				return ErrClosed
+			}
+
+			var ce *websocket.CloseError
+			if errors.As(err, &ce) && ce.Code == websocket.ClosePolicyViolation {
+				return errors.New(ce.Text)
+			}
+
+			return nil
+		}
+		conn.SetReadDeadline(time.Now().Add(readWait))
+
+		switch {
+		case p.Error != "":
+			select {
+			case c.errors <- errors.New(p.Error):
+			default:
+			}
+		case p.Message != nil:
+			select {
+			case c.messages <- *p.Message:
+			default:
+			}
+		default:
+			c.mu.Lock()
+			c.state = p.State
+			c.username = p.State.Username
+			c.mu.Unlock()
+
+			for _, m := range p.State.Messages {
+				select {
+				case c.messages <- m:
+				default:
+				}
+			}
+			c.publish(p.State)
+		}
+	}
+}
+
+// publish delivers an update, replacing any update which wasn't read yet.
+func (c *Client) publish(s State) {
+	for {
+		select {
+		case c.updates <- s:
+			return
+		default:
+			select {
+			case <-c.updates:
+			default:
+			}
+		}
+	}
+}
+
+// stop records why the client stopped.
+func (c *Client) stop(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn = nil
+	if err != ErrClosed {
+		c.err = err
+	}
+	if !c.closed {
+		c.closed = true
+		close(c.closing)
+	}
+}
+
+// connect fetches the token of the room, since it changes if the room was recreated, then joins it.
+func (c *Client) connect() (*websocket.Conn, error) {
+	room, token, err := c.fetchToken()
+	if err != nil {
+		return nil, err
+	}
+
+	wsURL := *c.roomURL
+	wsURL.Scheme = "ws"
+	if c.roomURL.Scheme == "https" {
+		wsURL.Scheme = "wss"
+	}
+	wsURL.Path = "/ws"
+
+	c.mu.Lock()
+	username := c.username
+	c.mu.Unlock()
+
+	wsURL.RawQuery = url.Values{"room": {room}, "token": {token}, "username": {username}}.Encode()
+	conn, _, err := c.options.Dialer.Dial(wsURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		conn.Close()
+		return nil, ErrClosed
+	}
+
+	c.conn = conn
+	c.room = room
+	c.token = token
+	return conn, nil
+}
+
+// fetchToken reads the room and its token from the room page.
+func (c *Client) fetchToken() (string, string, error) {
+	resp, err := c.options.HTTPClient.Get(c.roomURL.String())
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("client: could not get room: %s", resp.Status)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", err
+	}
+
+	var room, token string
+	if err := unquoteConfig(configRoomRx, b, &room); err != nil {
+		return "", "", err
+	}
+	if err := unquoteConfig(configTokenRx, b, &token); err != nil {
+		return "", "", err
+	}
+
+	return room, token, nil
+}
+
+// unquoteConfig finds a string in SibylConfig using rx and unquotes it into v.
+func unquoteConfig(rx *regexp.Regexp, page []byte, v *string) error {
+	m := rx.FindSubmatch(page)
+	if m == nil {
+		return errors.New("client: room not found")
+	}
+
+	return json.Unmarshal(m[1], v)
+}
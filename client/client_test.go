@@ -0,0 +1,237 @@
+package client_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/client"
+	"github.com/synacor/sibyl/deck"
+	"github.com/synacor/sibyl/server"
+)
+
+func TestConnect(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	_, err := client.Connect(ts.URL+"/nope", nil)
+	assert.Error(t, err)
+
+	_, err = client.Connect(ts.URL+"/r/Missing", nil)
+	assert.Error(t, err)
+
+	createRoom(t, ts, "Test")
+	c, err := client.Connect(ts.URL+"/r/Test", &client.Options{Username: "One"})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	s := nextUpdate(t, c)
+	assert.Equal(t, "One", s.Username)
+	assert.Equal(t, map[int]string{1: "One"}, s.Players)
+	assert.NotEmpty(t, s.Deck)
+}
+
+func TestActions(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	createRoom(t, ts, "Test")
+
+	one, err := client.Connect(ts.URL+"/r/Test", &client.Options{Username: "One"})
+	assert.NoError(t, err)
+	defer one.Close()
+	nextUpdate(t, one)
+
+	two, err := client.Connect(ts.URL+"/r/Test", &client.Options{Username: "Two"})
+	assert.NoError(t, err)
+	defer two.Close()
+	nextUpdate(t, two)
+	nextUpdate(t, one)
+
+	assert.NoError(t, one.SetTopic("New Topic"))
+	assert.Equal(t, "New Topic", nextUpdate(t, two).Topic)
+	nextUpdate(t, one)
+
+	assert.NoError(t, one.SelectCard(1))
+	s := nextUpdate(t, two)
+	assert.Equal(t, []client.Card{{Card: 1, PlayerID: 1, Player: "One"}}, s.Cards)
+	assert.False(t, s.Revealed)
+	nextUpdate(t, one)
+
+	assert.NoError(t, two.Reveal())
+	s = nextUpdate(t, one)
+	assert.True(t, s.Revealed)
+	assert.Equal(t, "One", s.Cards[0].Player)
+	nextUpdate(t, two)
+
+	assert.NoError(t, two.Reset())
+	s = nextUpdate(t, one)
+	assert.False(t, s.Revealed)
+	assert.Empty(t, s.Cards)
+	nextUpdate(t, two)
+
+	assert.NoError(t, one.SetDeck(deck.TShirtSizes.Name))
+	assert.Equal(t, deck.TShirtSizes.Name, nextUpdate(t, two).Deck)
+	nextUpdate(t, one)
+
+	assert.NoError(t, one.SetInsights(true))
+	assert.True(t, nextUpdate(t, two).Insights)
+
+	assert.NoError(t, two.Chat("Hello"))
+	select {
+	case m := <-one.Messages():
+		assert.Equal(t, client.Message{Kind: "chat", PlayerID: 2, Player: "Two", Text: "Hello", Time: m.Time}, m)
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	createRoom(t, ts, "Test")
+
+	conns := make(chan net.Conn, 2)
+	dialer := &websocket.Dialer{
+		NetDial: func(network, addr string) (net.Conn, error) {
+			conn, err := net.Dial(network, addr)
+			if err == nil {
+				conns <- conn
+			}
+			return conn, err
+		},
+	}
+
+	c, err := client.Connect(ts.URL+"/r/Test", &client.Options{Username: "One", Dialer: dialer, ReconnectWait: 10 * time.Millisecond})
+	assert.NoError(t, err)
+	defer c.Close()
+	nextUpdate(t, c)
+
+	// drop the connection out from under the client
+	(<-conns).Close()
+
+	s := nextUpdate(t, c)
+	assert.Equal(t, "One", s.Username)
+	assert.Equal(t, map[int]string{2: "One"}, s.Players)
+	assert.NoError(t, c.SetTopic("Still here"))
+	assert.Equal(t, "Still here", nextUpdate(t, c).Topic)
+}
+
+func TestKicked(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	createRoom(t, ts, "Test")
+
+	one, err := client.Connect(ts.URL+"/r/Test", nil)
+	assert.NoError(t, err)
+	defer one.Close()
+	nextUpdate(t, one)
+
+	two, err := client.Connect(ts.URL+"/r/Test", &client.Options{ReconnectWait: 10 * time.Millisecond})
+	assert.NoError(t, err)
+	nextUpdate(t, two)
+
+	assert.NoError(t, one.Kick(2, false))
+	select {
+	case <-two.Done():
+	case <-time.After(time.Second):
+		t.Fatal("kicked client is still running")
+	}
+
+	assert.EqualError(t, two.Err(), "You have been removed from the room.")
+	assert.Equal(t, client.ErrClosed, two.SetTopic("Topic"))
+}
+
+func TestActionErrors(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	createRoom(t, ts, "Test")
+
+	c, err := client.Connect(ts.URL+"/r/Test", nil)
+	assert.NoError(t, err)
+	defer c.Close()
+	nextUpdate(t, c)
+
+	// a card which isn't in the deck is reported, and the client keeps going
+	assert.NoError(t, c.SelectCard(99))
+	select {
+	case err := <-c.Errors():
+		assert.EqualError(t, err, "Your game had an invalid card. Please refresh your browser.")
+	case <-time.After(time.Second):
+		t.Fatal("no error received")
+	}
+
+	// as is a card of a deck the room isn't using
+	assert.NoError(t, c.SelectDeckCard(1, "T-Shirt Sizes"))
+	select {
+	case err := <-c.Errors():
+		assert.EqualError(t, err, "Your game is out of sync. Please refresh your browser.")
+	case <-time.After(time.Second):
+		t.Fatal("no error received")
+	}
+
+	assert.NoError(t, c.SetTopic("Still here"))
+	assert.Equal(t, "Still here", nextUpdate(t, c).Topic)
+	assert.NoError(t, c.Err())
+}
+
+func TestClose(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	createRoom(t, ts, "Test")
+
+	c, err := client.Connect(ts.URL+"/r/Test", nil)
+	assert.NoError(t, err)
+	nextUpdate(t, c)
+
+	assert.NoError(t, c.Close())
+	assert.NoError(t, c.Close())
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("closed client is still running")
+	}
+
+	assert.NoError(t, c.Err())
+	assert.Equal(t, client.ErrClosed, c.Reveal())
+	_, open := <-c.Updates()
+	assert.False(t, open)
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	s, err := server.New(os.DirFS(".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ListenForEvents(make(chan bool, 1))
+
+	return httptest.NewServer(s.ServeMux())
+}
+
+func createRoom(t *testing.T, ts *httptest.Server, room string) {
+	httpClient := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	resp, err := httpClient.PostForm(ts.URL+"/create", url.Values{"room": {room}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func nextUpdate(t *testing.T, c *client.Client) client.State {
+	select {
+	case s := <-c.Updates():
+		return s
+	case <-time.After(time.Second):
+		t.Fatal("no update received")
+	}
+
+	return client.State{}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/synacor/sibyl/server"
+)
+
+// secretKeys are the config keys which are never printed. They have no flag, so that they can't be
+// seen in the process list, and are only set with SIB_ environment variables or in the config file.
+var secretKeys = map[string]bool{
+	"admin_token": true,
+}
+
+// restartKeys are the config keys which are only read when sibyl starts serving. Every other key is
+// applied when the config file changes.
+var restartKeys = map[string]bool{
+	"port":            true,
+	"tls_port":        true,
+	"force_tls":       true,
+	"tls_private_key": true,
+	"tls_public_key":  true,
+	"grpc_port":       true,
+	"ssh_port":        true,
+	"ssh_host_key":    true,
+	"assets_dir":      true,
+	"data_dir":        true,
+	"otlp_endpoint":   true,
+	"otlp_insecure":   true,
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with the config",
+}
+
+var configCheckCmd = &cobra.Command{
+	Use:   "check [file]",
+	Short: "Check a config file, and print the effective config",
+	Long: `Check a config file, and print the effective config, which also takes the SIB_ environment
+variables and the defaults into account. Without a file, the config file "sibyl serve" would use is checked.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configFile
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		if err := loadConfig(path); err != nil {
+			return err
+		}
+
+		if used := viper.ConfigFileUsed(); used != "" {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Checked %s\n", used)
+		} else {
+			fmt.Fprintln(cmd.ErrOrStderr(), "No config file found")
+		}
+
+		b, err := json.MarshalIndent(effectiveConfig(), "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(b))
+
+		return validateConfig()
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configCheckCmd)
+}
+
+// configError lists everything wrong with the config.
+type configError []string
+
+func (e configError) Error() string {
+	return "invalid config:\n  " + strings.Join(e, "\n  ")
+}
+
+// validateConfig returns a configError if any setting can't be used.
+func validateConfig() error {
+	var problems configError
+	invalid := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	ports := make(map[int]string)
+	for _, key := range []string{"port", "tls_port", "grpc_port", "ssh_port"} {
+		port, err := cast.ToIntE(viper.Get(key))
+		switch {
+		case err != nil:
+			invalid("%s must be a number", key)
+		case port < 0 || port > maxPort || (port == 0 && key == "port"):
+			invalid("%s must be 0 < %s <= %d", key, key, maxPort)
+		case port == 0:
+		case ports[port] != "":
+			invalid("%s cannot equal %s", key, ports[port])
+		default:
+			ports[port] = key
+		}
+	}
+
+	if viper.GetInt("tls_port") > 0 {
+		for _, key := range []string{"tls_private_key", "tls_public_key"} {
+			if path := viper.GetString(key); path == "" {
+				invalid("must supply %s if tls_port is specified", key)
+			} else if _, err := os.Stat(path); err != nil {
+				invalid("%s: %v", key, err)
+			}
+		}
+	}
+
+	if dir := viper.GetString("assets_dir"); dir != "" {
+		if info, err := os.Stat(dir); err != nil {
+			invalid("assets_dir: %v", err)
+		} else if !info.IsDir() {
+			invalid("assets_dir must be a directory")
+		}
+	}
+
+	if dir := viper.GetString("data_dir"); dir != "" {
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			invalid("data_dir must be a directory")
+		}
+	}
+
+	if _, err := server.ConfigBranding(); err != nil {
+		invalid("branding: %v", err)
+	}
+
+	if _, err := server.ConfigRooms(); err != nil {
+		invalid("rooms: %v", err)
+	}
+
+	if _, err := log.ParseLevel(viper.GetString("log_level")); err != nil {
+		invalid("log_level: %v", err)
+	}
+
+	if format := viper.GetString("log_format"); format != "text" && format != "json" {
+		invalid("log_format must be text or json")
+	}
+
+	for _, key := range []string{"outlier_steps", "chat_rate_limit"} {
+		if n, err := cast.ToIntE(viper.Get(key)); err != nil || n < 0 {
+			invalid("%s must be a number, and not negative", key)
+		}
+	}
+
+	for _, key := range []string{"kick_ban", "idle_after", "drain_timeout", "room_destroy_delay"} {
+		if d, err := cast.ToDurationE(viper.Get(key)); err != nil || d < 0 {
+			invalid("%s must be a duration such as \"5m\"", key)
+		}
+	}
+
+	for _, key := range []string{"debug", "force_tls", "auto_reveal_skip_away", "delta_updates", "otlp_insecure", "dev_mode"} {
+		if _, err := cast.ToBoolE(viper.Get(key)); err != nil {
+			invalid("%s must be true or false", key)
+		}
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+
+	return nil
+}
+
+// effectiveConfig returns the value of every config key, as sibyl serve would see it. Secrets are
+// only shown to be set.
+func effectiveConfig() map[string]interface{} {
+	config := make(map[string]interface{})
+	serveCmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		key := configKey(flag)
+		switch {
+		case flag.Value.Type() == "int":
+			config[key] = viper.GetInt(key)
+		case flag.Value.Type() == "bool":
+			config[key] = viper.GetBool(key)
+		case flag.Value.Type() == "duration":
+			config[key] = viper.GetDuration(key).String()
+		default:
+			config[key] = viper.GetString(key)
+		}
+	})
+
+	for key := range secretKeys {
+		if viper.GetString(key) != "" {
+			config[key] = "********"
+		} else {
+			config[key] = ""
+		}
+	}
+
+	// branding and rooms are sections of the config file, which have no flag
+	config["branding"], _ = server.ConfigBranding()
+	config["rooms"], _ = server.ConfigRooms()
+
+	return config
+}
+
+// watchConfig applies changes to the config file to srv while it serves. This includes a Kubernetes
+// ConfigMap being updated, when the config file is mounted from one.
+func watchConfig(srv *server.Server) {
+	srv.SetEffectiveConfig(effectiveConfig())
+	if viper.ConfigFileUsed() == "" {
+		return
+	}
+
+	applied := effectiveConfig()
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		applied = reloadConfig(srv, applied)
+	})
+	viper.WatchConfig()
+}
+
+// reloadConfig applies what changed in the config since applied, and returns the config now in use.
+// An invalid config isn't applied at all, and keys which need a restart keep the value they had.
+func reloadConfig(srv *server.Server, applied map[string]interface{}) map[string]interface{} {
+	if err := validateConfig(); err != nil {
+		log.Errorf("not reloading config: %v", err)
+		return applied
+	}
+
+	config := effectiveConfig()
+	var changed, needRestart []string
+	for key, value := range config {
+		if reflect.DeepEqual(value, applied[key]) {
+			continue
+		}
+
+		if restartKeys[key] {
+			needRestart = append(needRestart, key)
+			config[key] = applied[key]
+		} else {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(needRestart)
+
+	if len(needRestart) > 0 {
+		log.WithField("keys", strings.Join(needRestart, ",")).Warn("config changes need a restart")
+	}
+	if len(changed) == 0 {
+		return config
+	}
+
+	configureLogger()
+	srv.Reload()
+	srv.SetEffectiveConfig(config)
+	log.WithField("keys", strings.Join(changed, ",")).Info("reloaded config")
+
+	return config
+}
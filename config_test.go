@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/server"
+)
+
+// setConfig overrides config keys for the rest of a test.
+func setConfig(t *testing.T, values map[string]interface{}) {
+	for key, value := range values {
+		viper.Set(key, value)
+	}
+
+	t.Cleanup(func() {
+		for key := range values {
+			viper.Set(key, nil)
+		}
+	})
+}
+
+func TestValidateConfig(t *testing.T) {
+	assert.NoError(t, validateConfig())
+
+	setConfig(t, map[string]interface{}{
+		"port":       "abc",
+		"tls_port":   5001,
+		"grpc_port":  5001,
+		"ssh_port":   70000,
+		"log_level":  "loud",
+		"log_format": "xml",
+		"kick_ban":   "soon",
+		"debug":      "maybe",
+		"assets_dir": "config.go",
+		"data_dir":   "config.go",
+		"rooms":      []interface{}{map[string]interface{}{"name": "Planning", "destroy_delay": "soon"}},
+	})
+
+	err := validateConfig()
+	assert.Equal(t, configError{
+		"port must be a number",
+		"grpc_port cannot equal tls_port",
+		"ssh_port must be 0 < ssh_port <= 65535",
+		"must supply tls_private_key if tls_port is specified",
+		"must supply tls_public_key if tls_port is specified",
+		"assets_dir must be a directory",
+		"data_dir must be a directory",
+		`rooms: the destroy_delay of "Planning" must be a duration such as "5m"`,
+		`log_level: not a valid logrus Level: "loud"`,
+		"log_format must be text or json",
+		`kick_ban must be a duration such as "5m"`,
+		"debug must be true or false",
+	}, err)
+	assert.Contains(t, err.Error(), "invalid config:\n  port must be a number\n")
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	config := effectiveConfig()
+	assert.Equal(t, defaultPort, config["port"])
+	assert.Equal(t, "5m0s", config["kick_ban"])
+	assert.Equal(t, false, config["delta_updates"])
+	assert.Equal(t, "", config["admin_token"])
+	assert.Nil(t, serveCmd.Flags().Lookup("admin-token"))
+	assert.Equal(t, server.DefaultBranding, config["branding"])
+
+	setConfig(t, map[string]interface{}{
+		"port":        "6000",
+		"admin_token": "secret",
+	})
+
+	config = effectiveConfig()
+	assert.Equal(t, 6000, config["port"])
+	assert.Equal(t, "********", config["admin_token"])
+}
+
+func TestReloadConfig(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+	defer log.SetLevel(log.GetLevel())
+
+	srv, err := server.New(assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := effectiveConfig()
+
+	// an invalid config isn't applied at all
+	setConfig(t, map[string]interface{}{"log_level": "loud", "kick_ban": "10m"})
+	assert.Equal(t, applied, reloadConfig(srv, applied))
+	assert.Equal(t, log.ErrorLevel, hook.LastEntry().Level)
+
+	setConfig(t, map[string]interface{}{"log_level": "debug", "port": 6000})
+	config := reloadConfig(srv, applied)
+	assert.Equal(t, "10m0s", config["kick_ban"])
+	assert.Equal(t, "debug", config["log_level"])
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+
+	// the port can't change while serving
+	assert.Equal(t, defaultPort, config["port"])
+
+	var restart, reloaded *log.Entry
+	for _, e := range hook.AllEntries() {
+		switch e.Message {
+		case "config changes need a restart":
+			restart = e
+		case "reloaded config":
+			reloaded = e
+		}
+	}
+	if assert.NotNil(t, restart) && assert.NotNil(t, reloaded) {
+		assert.Equal(t, "port", restart.Data["keys"])
+		assert.Equal(t, "kick_ban,log_level", reloaded.Data["keys"])
+	}
+
+	// once applied, only the port is logged again
+	hook.Reset()
+	assert.Equal(t, config, reloadConfig(srv, config))
+	assert.Len(t, hook.AllEntries(), 1)
+}
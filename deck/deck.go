@@ -23,6 +23,9 @@ var TShirtSizes = &Deck{"T-Shirt Sizes", []string{"XS", "S", "M", "L", "XL", "XX
 
 var Hours = &Deck{"Hours", []string{"0", ".5", "1", "2", "4", "8", "12", "16", "20", "24", "?", "☕"}}
 
+// nonEstimates are cards which do not represent an estimate, and can't be compared to other cards.
+var nonEstimates = map[string]bool{"?": true, "☕": true}
+
 // AllDecks contains a mapping of deck names to decks
 var AllDecks = map[string]*Deck{
 	ModifiedFibonacci.Name: ModifiedFibonacci,
@@ -39,3 +42,19 @@ func (d *Deck) GetCard(i int) (string, error) {
 
 	return d.Cards[i], nil
 }
+
+// IsEstimate returns true if the card for the specified index is an estimate. Estimates are ordered
+// from lowest to highest by their index, whereas cards such as "?" can't be compared.
+func (d *Deck) IsEstimate(i int) bool {
+	c, err := d.GetCard(i)
+	if err != nil {
+		return false
+	}
+
+	return IsEstimateCard(c)
+}
+
+// IsEstimateCard returns true if a card, such as "5", is an estimate rather than a card such as "?".
+func IsEstimateCard(card string) bool {
+	return !nonEstimates[card]
+}
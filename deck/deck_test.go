@@ -23,6 +23,17 @@ func TestDeck(t *testing.T) {
 	assert.Equal(t, err, ErrCardNotFound)
 }
 
+func TestIsEstimate(t *testing.T) {
+	assert.True(t, ModifiedFibonacci.IsEstimate(0))
+	assert.True(t, ModifiedFibonacci.IsEstimate(9))
+	assert.False(t, ModifiedFibonacci.IsEstimate(10))
+	assert.False(t, ModifiedFibonacci.IsEstimate(11))
+	assert.False(t, ModifiedFibonacci.IsEstimate(12))
+	assert.False(t, ModifiedFibonacci.IsEstimate(-1))
+	assert.True(t, TShirtSizes.IsEstimate(5))
+	assert.False(t, TShirtSizes.IsEstimate(6))
+}
+
 func TestSpotCheck(t *testing.T) {
 	c, _ := ModifiedFibonacci.GetCard(7)
 	assert.Equal(t, "20", c)
@@ -0,0 +1,144 @@
+package game
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// snapshotInterval is how many deltas are sent before a full update is sent again, so that a client
+// which somehow missed a change doesn't stay out of sync for long.
+const snapshotInterval = 20
+
+// deltaClient is implemented by clients which can apply deltas instead of full updates.
+type deltaClient interface {
+	WantsDeltas() bool
+}
+
+// wsDelta holds only what changed since the previous update, which has the sequence number Seq - 1.
+// Fields which did not change are left out.
+type wsDelta struct {
+	Seq        int               `json:"seq"`
+	Delta      bool              `json:"delta"`
+	Topic      *string           `json:"topic,omitempty"`
+	Players    map[int]string    `json:"players,omitempty"`
+	Cards      *[]*wsCard        `json:"cards,omitempty"`
+	Deck       *string           `json:"deck,omitempty"`
+	Revealed   *bool             `json:"reveal,omitempty"`
+	Reset      bool              `json:"reset,omitempty"`
+	Username   string            `json:"username"`
+	Elapsed    int               `json:"elapsed"`
+	Round      *int              `json:"round,omitempty"`
+	Rounds     *[]*wsRound       `json:"rounds,omitempty"`
+	Spectators *[]int            `json:"spectators,omitempty"`
+	Presence   *map[int]Presence `json:"presence,omitempty"`
+	Insights   *bool             `json:"insights,omitempty"`
+	Absent     *[]string         `json:"absent,omitempty"`
+
+	shared *sharedJSON
+}
+
+// diff returns the delta between two updates.
+func diff(old, u *wsUpdate) *wsDelta {
+	d := &wsDelta{
+		Seq:     u.Seq,
+		Delta:   true,
+		Reset:   u.Reset,
+		Elapsed: u.Elapsed,
+	}
+
+	if old.Topic != u.Topic {
+		d.Topic = &u.Topic
+	}
+	if !reflect.DeepEqual(old.Players, u.Players) {
+		d.Players = u.Players
+	}
+	if !reflect.DeepEqual(old.Cards, u.Cards) {
+		d.Cards = &u.Cards
+	}
+	if old.Deck != u.Deck {
+		d.Deck = &u.Deck
+	}
+	if old.Revealed != u.Revealed {
+		d.Revealed = &u.Revealed
+	}
+	if old.Round != u.Round {
+		d.Round = &u.Round
+	}
+	if !reflect.DeepEqual(old.Rounds, u.Rounds) {
+		d.Rounds = &u.Rounds
+	}
+	if !reflect.DeepEqual(old.Spectators, u.Spectators) {
+		d.Spectators = &u.Spectators
+	}
+	if !reflect.DeepEqual(old.Presence, u.Presence) {
+		d.Presence = &u.Presence
+	}
+	if old.Insights != u.Insights {
+		d.Insights = &u.Insights
+	}
+	if !reflect.DeepEqual(old.Absent, u.Absent) {
+		d.Absent = &u.Absent
+	}
+
+	return d
+}
+
+// sharedJSON holds the encoding of a payload which is broadcast to every client. Only the username
+// differs between clients, so everything else is encoded once and shared.
+type sharedJSON struct {
+	once sync.Once
+	b    []byte
+	err  error
+}
+
+// marshal returns the encoding of v, which must leave out the username, with the username added.
+func (s *sharedJSON) marshal(v interface{}, username string) ([]byte, error) {
+	s.once.Do(func() {
+		s.b, s.err = json.Marshal(v)
+	})
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	name, err := json.Marshal(username)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, len(s.b)+len(name)+len(`{"username":,`))
+	b = append(b, `{"username":`...)
+	b = append(b, name...)
+	if len(s.b) > 2 {
+		b = append(b, ',')
+	}
+	return append(b, s.b[1:]...), nil
+}
+
+// wsUpdateFields and wsDeltaFields have the same fields, but not the MarshalJSON methods.
+type wsUpdateFields wsUpdate
+type wsDeltaFields wsDelta
+
+// MarshalJSON encodes the update, sharing the encoding with other clients if it was broadcast.
+func (u wsUpdate) MarshalJSON() ([]byte, error) {
+	if u.shared == nil {
+		return json.Marshal(wsUpdateFields(u))
+	}
+
+	return u.shared.marshal(struct {
+		wsUpdateFields
+		Username string `json:"username,omitempty"`
+	}{wsUpdateFields: wsUpdateFields(u)}, u.Username)
+}
+
+// MarshalJSON encodes the delta, sharing the encoding with other clients.
+func (d *wsDelta) MarshalJSON() ([]byte, error) {
+	if d.shared == nil {
+		return json.Marshal((*wsDeltaFields)(d))
+	}
+
+	return d.shared.marshal(struct {
+		*wsDeltaFields
+		Username string `json:"username,omitempty"`
+	}{wsDeltaFields: (*wsDeltaFields)(d)}, d.Username)
+}
@@ -0,0 +1,150 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalUpdate(t *testing.T) {
+	u := wsUpdate{Topic: "Topic", Username: "One", Players: map[int]string{1: "One"}}
+	b, err := json.Marshal(u)
+	assert.NoError(t, err)
+
+	var unshared map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &unshared))
+	assert.Equal(t, "One", unshared["username"])
+	assert.Equal(t, "Topic", unshared["topic"])
+
+	// with a shared encoding, only the username differs
+	u.shared = &sharedJSON{}
+	u2 := u
+	u2.Username = "Two"
+
+	var one, two map[string]interface{}
+	b, err = json.Marshal(u)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(b, &one))
+	b, err = json.Marshal(u2)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(b, &two))
+
+	assert.Equal(t, unshared, one)
+	assert.Equal(t, "Two", two["username"])
+	delete(one, "username")
+	delete(two, "username")
+	assert.Equal(t, one, two)
+}
+
+func TestMarshalDelta(t *testing.T) {
+	topic := "New Topic"
+	d := &wsDelta{Seq: 2, Delta: true, Topic: &topic, Username: "One", shared: &sharedJSON{}}
+	b, err := json.Marshal(d)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"username":"One","seq":2,"delta":true,"topic":"New Topic","elapsed":0}`, string(b))
+}
+
+func TestDiff(t *testing.T) {
+	old := &wsUpdate{Topic: "Topic", Deck: "Deck", Players: map[int]string{1: "One"}, Cards: []*wsCard{}, Round: 1}
+	u := &wsUpdate{Seq: 5, Topic: "Topic", Deck: "Deck", Players: map[int]string{1: "One"}, Cards: []*wsCard{{Card: 1, PlayerID: 1}}, Round: 1, Elapsed: 3}
+
+	d := diff(old, u)
+	assert.Equal(t, 5, d.Seq)
+	assert.True(t, d.Delta)
+	assert.Equal(t, 3, d.Elapsed)
+	assert.Nil(t, d.Topic)
+	assert.Nil(t, d.Players)
+	assert.Nil(t, d.Deck)
+	assert.Nil(t, d.Round)
+	assert.Nil(t, d.Revealed)
+	assert.Equal(t, &u.Cards, d.Cards)
+
+	// an empty list of cards is still a change
+	d = diff(u, old)
+	assert.Equal(t, []*wsCard{}, *d.Cards)
+}
+
+func TestPublishDeltas(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2 := newClientTest(1), &deltaClientTest{newClientTest(2)}
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+
+	// everyone gets a full update when joining
+	u := c2.send[0].(wsUpdate)
+	assert.Equal(t, 2, u.Seq)
+
+	g.SetTopic("New Topic")
+	assert.Equal(t, "New Topic", c1.send[2].(wsUpdate).Topic)
+	d := c2.send[1].(*wsDelta)
+	assert.Equal(t, 3, d.Seq)
+	assert.Equal(t, "New Topic", *d.Topic)
+	assert.Nil(t, d.Players)
+	assert.Nil(t, d.Cards)
+
+	g.AddCard(c1, 1, g.Deck().Name)
+	d = c2.send[2].(*wsDelta)
+	assert.Equal(t, 4, d.Seq)
+	assert.Nil(t, d.Topic)
+	assert.Equal(t, 1, len(*d.Cards))
+
+	// a full update is sent every so often
+	for i := 0; i < snapshotInterval; i++ {
+		g.SendUpdate()
+	}
+	full := 0
+	for _, o := range c2.send[3:] {
+		if _, ok := o.(wsUpdate); ok {
+			full++
+		}
+	}
+	assert.Equal(t, 1, full)
+
+	// and when asked for
+	n := len(c2.send)
+	g.Resync(c2)
+	assert.Equal(t, g.state.seq, c2.send[n].(wsUpdate).Seq)
+}
+
+func TestPublishUnchangedCards(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	watcher := &deltaClientTest{newClientTest(1)}
+	g.RegisterClient(watcher)
+	for i := 2; i <= 6; i++ {
+		c := newClientTest(i)
+		g.RegisterClient(c)
+		g.AddCard(c, 1, g.Deck().Name)
+	}
+
+	// the cards are the same in every update, so they're left out of the deltas
+	n := len(watcher.send)
+	for i := 0; i < 5; i++ {
+		g.SendUpdate()
+	}
+	for _, o := range watcher.send[n:] {
+		if assert.IsType(t, &wsDelta{}, o) {
+			assert.Nil(t, o.(*wsDelta).Cards)
+		}
+	}
+}
+
+func TestSupersedes(t *testing.T) {
+	update, join, delta := wsUpdate{}, wsUpdate{Messages: []*wsMessage{{}}}, &wsDelta{}
+
+	assert.True(t, Supersedes(update, update))
+	assert.True(t, Supersedes(update, delta))
+	assert.False(t, Supersedes(update, join))
+	assert.False(t, Supersedes(update, &wsChat{}))
+	assert.False(t, Supersedes(delta, update))
+	assert.False(t, Supersedes(delta, delta))
+	assert.False(t, Supersedes(join, update))
+}
+
+type deltaClientTest struct {
+	*clientTest
+}
+
+func (c *deltaClientTest) WantsDeltas() bool {
+	return true
+}
@@ -2,16 +2,24 @@
 package game
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"math"
+	"reflect"
 	"regexp"
-	"sync"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/synacor/sibyl/deck"
+	"github.com/synacor/sibyl/i18n"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -23,10 +31,32 @@ const (
 
 	// TopicMaxLength is the max length a topic may be.
 	TopicMaxLength = 100
+
+	// ChatMaxLength is the max length a chat message may be.
+	ChatMaxLength = 200
+)
+
+// chatHistorySize is the number of chat messages and reactions kept for players who join late
+const chatHistorySize = 50
+
+// Message kinds
+const (
+	messageKindChat     = "chat"
+	messageKindReaction = "reaction"
+
+	// messageKindCommit tells the room that an estimate was committed to a story
+	messageKindCommit = "commit"
 )
 
-// waitToDestroy is the number of milliseconds to wait after last client to destroy the channel
-const waitToDestroy = 10000 // 10 seconds
+// Reactions are the emoji a player may react with.
+var Reactions = []string{"👍", "👎", "🎉", "🤔", "😂", "☕"}
+
+// DefaultDestroyDelay is how long a room stays open after the last client leaves, unless it's pinned
+const DefaultDestroyDelay = 10 * time.Second
+
+// DefaultPresenceInterval is how often a room checks whether the presence of its players changed, such
+// as a player going idle, see SetPresenceInterval
+const DefaultPresenceInterval = time.Minute
 
 // ErrInvalidRoomName is returned when the room name is not valid.
 var ErrInvalidRoomName = errors.New("sibyl: room name is invalid")
@@ -34,6 +64,7 @@ var ErrInvalidRoomName = errors.New("sibyl: room name is invalid")
 // Golang doesn't allow \p{Letter}, so we have to use the shorthand.
 // L = Letter, M = Mark, N = Number, P = Punctuation
 var validTopixRx = regexp.MustCompile(`^[\p{L}\p{M}\p{S}\p{N}\p{P} ]{1,100}\z`)
+var validChatRx = regexp.MustCompile(`^[\p{L}\p{M}\p{S}\p{N}\p{P} ]{1,200}\z`)
 var validRoomRx = regexp.MustCompile(`^[\p{L}\p{N} _-]{1,20}\z`)
 var withLetterOrNumberRx = regexp.MustCompile(`[\p{L}\p{N}]`)
 
@@ -45,61 +76,153 @@ type client interface {
 	RemoteAddr() string
 }
 
-type safeClients struct {
-	clients map[client]bool
-	mutex   sync.RWMutex
+// sessionClient is implemented by clients which know the browser session they belong to. Sessions
+// are used to keep kicked clients from immediately rejoining.
+type sessionClient interface {
+	Session() string
 }
 
-type safeCards struct {
-	deck   *deck.Deck
-	cards  map[client]int
-	reveal bool
-	mutex  sync.RWMutex
+// closeReasonClient is implemented by clients which can tell the remote end why they were disconnected.
+type closeReasonClient interface {
+	SetCloseReason(reason string)
 }
 
-type safeTopic struct {
-	topic string
-	mutex sync.RWMutex
+// connClient is implemented by clients whose connection has an ID, which is added to their log lines.
+type connClient interface {
+	ConnID() string
 }
 
-type safeDestroyAttempt struct {
-	attempt int
-	mutex   sync.RWMutex
+// contextClient is implemented by clients which trace writing the messages sent to them, as part of
+// the trace of whatever sent them.
+type contextClient interface {
+	SendContext(ctx context.Context, o interface{})
 }
 
-type safeClientLastID struct {
-	lastID int
-	mutex  sync.RWMutex
+// presenceClient is implemented by clients which keep track of whether their player is paying attention.
+type presenceClient interface {
+	Presence() Presence
 }
 
-type safeClock struct {
-	clock time.Time
-	mutex sync.RWMutex
+// localeClient is implemented by clients which know the locale of their player, which the messages
+// sent to them are translated to.
+type localeClient interface {
+	Locale() string
 }
 
-// Game represents an individual estimation session game
-type Game struct {
-	safeClients safeClients
-	safeCards   safeCards
-	safeTopic   safeTopic
-	safeClock   safeClock
+// spectatorClient is implemented by clients which may ask to join as a spectator.
+type spectatorClient interface {
+	Spectator() bool
+}
+
+// Presence describes whether a player is paying attention to the game.
+type Presence string
+
+// Presence constants
+const (
+	PresenceActive Presence = "active"
+	PresenceIdle   Presence = "idle"
+	PresenceAway   Presence = "away"
+)
+
+// state holds everything about a game which changes over time. It must only be read or written from
+// the game's event loop, which is what keeps it consistent without any locks.
+type state struct {
+	clients    map[client]bool
+	spectators map[client]bool
+	banned     map[string]time.Time
+
+	// skipAway will not wait on away players before revealing the cards
+	skipAway bool
 
+	// roster holds the names of the players expected to vote, see SetRoster. rostered holds the same
+	// names in lower case, to look them up.
+	roster   []string
+	rostered map[string]bool
+
+	deck   *deck.Deck
+	cards  map[client]int
+	reveal bool
+	round  int
+	rounds []*wsRound
+
+	// history holds the revealed rounds of the session, and estimate numbers the current estimate in
+	// it. See History.
+	history  []*HistoryRound
+	estimate int
+	insights bool
+
+	// teamInsights is whether the team whose room it is turned insights on, see SetTeamInsights
+	teamInsights bool
+
+	// onReveal is called with each round kept in the history, see SetOnReveal
+	onReveal func(HistoryRound)
+
+	// outlierSteps is how far from the median a card has to be to be considered an outlier. When
+	// zero, the lowest and highest cards are the outliers.
+	outlierSteps int
+
+	// destroyDelay is how long the room stays open after the last client leaves, and a pinned room is
+	// never destroyed that way
+	destroyDelay time.Duration
+	pinned       bool
+
+	topic          string
+	clock          time.Time
+	messages       []*wsMessage
+	destroyAttempt int
+	lastClientID   int
+
+	// ctx is the context of the command being run, so that what it does can be traced
+	ctx context.Context
+
+	// seq numbers each broadcast update, and last is the update with that number. deltas are made
+	// against it until sinceSnapshot reaches snapshotInterval.
+	seq           int
+	last          *wsUpdate
+	sinceSnapshot int
+}
+
+// Game represents an individual estimation session game.
+//
+// Each game runs its own event loop. Every exported method hands a command to the loop and waits for
+// it to finish, so commands are applied one at a time and always see a consistent state.
+type Game struct {
 	// Room is the name of the room
 	Room string
 
 	// Token is a unique token to ensure a user doesn't join a stale game
 	Token string
 
-	onComplete         chan *Game
-	waitToDestroy      int
-	safeDestroyAttempt safeDestroyAttempt
-	safeClientLastID   safeClientLastID
+	// ctx is the context of the commands given through this Game, see WithContext
+	ctx context.Context
+
+	*loop
+}
+
+// loop is the event loop of a game, along with the state only it may touch. It is shared by every
+// copy of a Game made by WithContext.
+type loop struct {
+	onComplete chan *Game
+
+	// presence fires when it's time to check the presence of the players, see checkPresence
+	presence *time.Ticker
+
+	commands chan func()
+	stopped  chan struct{}
+	state    state
 }
 
 type wsCard struct {
 	Card     int    `json:"card"`
 	PlayerID int    `json:"playerID"`
 	Player   string `json:"player"`
+	Outlier  bool   `json:"outlier"`
+}
+
+// wsRound is a revealed round of voting which was superseded by a re-vote on the same topic.
+type wsRound struct {
+	Round int       `json:"round"`
+	Cards []*wsCard `json:"cards"`
 }
 
 // wsUpdate is an update that will be sent via websocket to the client.
@@ -112,6 +235,67 @@ type wsUpdate struct {
 	Reset    bool           `json:"reset"`
 	Username string         `json:"username"`
 	Elapsed  int            `json:"elapsed"`
+	Round    int            `json:"round"`
+	Rounds   []*wsRound     `json:"rounds"`
+	Seq      int            `json:"seq"`
+
+	// Insights is whether the room's history includes a summary of how each player votes
+	Insights bool `json:"insights"`
+
+	// Spectators are the IDs of players who may watch, but not vote
+	Spectators []int `json:"spectators"`
+
+	// Presence holds the players who are idle or away. Players not listed are active.
+	Presence map[int]Presence `json:"presence"`
+
+	// Absent holds the names of the players on the roster who aren't in the room
+	Absent []string `json:"absent"`
+
+	// Messages holds the recent chat messages and reactions. It is only sent to players as they join.
+	Messages []*wsMessage `json:"messages,omitempty"`
+
+	shared *sharedJSON
+}
+
+// Coalescer is implemented by payloads which hold the complete game state. If Coalesce returns true,
+// a client that hasn't sent the payload yet may drop it in favour of a newer one.
+type Coalescer interface {
+	Coalesce() bool
+}
+
+// Coalesce returns true unless the update carries the recent messages for a player that just joined,
+// which a later update wouldn't repeat.
+func (u wsUpdate) Coalesce() bool {
+	return len(u.Messages) == 0
+}
+
+// Supersedes returns whether a payload waiting to be sent is no longer needed once o is sent. An update
+// that may be coalesced replaces any older such update, as well as any delta before it.
+func Supersedes(o, waiting interface{}) bool {
+	if co, ok := o.(Coalescer); !ok || !co.Coalesce() {
+		return false
+	}
+
+	if _, ok := waiting.(*wsDelta); ok {
+		return true
+	}
+
+	co, ok := waiting.(Coalescer)
+	return ok && co.Coalesce()
+}
+
+// wsMessage is a chat message or an emoji reaction from a player.
+type wsMessage struct {
+	Kind     string `json:"kind"`
+	PlayerID int    `json:"playerID"`
+	Player   string `json:"player"`
+	Text     string `json:"text"`
+	Time     int64  `json:"time"`
+}
+
+// wsChat is broadcast to all clients when a player chats or reacts.
+type wsChat struct {
+	Message *wsMessage `json:"message"`
 }
 
 // wsError is providers error information to the client
@@ -142,306 +326,911 @@ func New(room string, defaultDeck string, onComplete chan *Game) (*Game, error)
 	}
 
 	g := &Game{
-		safeClients: safeClients{
-			clients: make(map[client]bool),
-			mutex:   sync.RWMutex{},
-		},
-		safeCards: safeCards{
-			deck:   useDeck,
-			cards:  make(map[client]int),
-			reveal: false,
-			mutex:  sync.RWMutex{},
-		},
-		safeTopic: safeTopic{
-			topic: fmt.Sprintf("%s Estimation Session", room),
-			mutex: sync.RWMutex{},
-		},
-		safeClock: safeClock{
-			clock: time.Now(),
-		},
-
 		Room:  room,
 		Token: token,
-
-		onComplete:    onComplete,
-		waitToDestroy: waitToDestroy,
-		safeDestroyAttempt: safeDestroyAttempt{
-			attempt: 0,
-			mutex:   sync.RWMutex{},
+		ctx:   context.Background(),
+	}
+	g.loop = &loop{
+		onComplete: onComplete,
+		presence:   time.NewTicker(DefaultPresenceInterval),
+
+		commands: make(chan func()),
+		stopped:  make(chan struct{}),
+		state: state{
+			clients:    make(map[client]bool),
+			spectators: make(map[client]bool),
+			banned:     make(map[string]time.Time),
+			deck:       useDeck,
+			cards:      make(map[client]int),
+			reveal:     false,
+			round:      1,
+			rounds:     make([]*wsRound, 0),
+			history:    make([]*HistoryRound, 0),
+			estimate:   1,
+			topic:      fmt.Sprintf("%s Estimation Session", room),
+			clock:      time.Now(),
+			messages:   make([]*wsMessage, 0, chatHistorySize),
+
+			destroyDelay: DefaultDestroyDelay,
 		},
 	}
 
+	go g.run()
+
 	return g, nil
 }
 
+// run is the event loop of the game. It applies commands until the game is destroyed.
+func (g *Game) run() {
+	for {
+		select {
+		case cmd := <-g.commands:
+			cmd()
+		case <-g.presence.C:
+			g.checkPresence()
+		case <-g.stopped:
+			g.presence.Stop()
+			if g.onComplete != nil {
+				g.onComplete <- g
+			}
+			return
+		}
+	}
+}
+
+// do runs fn on the event loop and waits for it to finish. If the game has been destroyed, fn is
+// never run. Methods called from within fn must not call do again, or the loop will deadlock.
+//
+// The command is traced as op, along with how long it waited for the loop.
+func (g *Game) do(op string, fn func()) {
+	ctx, span := tracer().Start(g.context(), "game."+op, trace.WithAttributes(attribute.String("sibyl.room", g.Room)))
+	defer span.End()
+	_, wait := tracer().Start(ctx, "game.wait")
+
+	done := make(chan struct{})
+	cmd := func() {
+		wait.End()
+		g.state.ctx = ctx
+		fn()
+		g.state.ctx = nil
+		close(done)
+	}
+
+	select {
+	case g.commands <- cmd:
+		<-done
+	case <-g.stopped:
+		wait.End()
+	}
+}
+
+// WithContext returns a copy of the game whose commands are part of ctx, such as the trace of the
+// action which led to them. The copy shares everything else with the game.
+func (g *Game) WithContext(ctx context.Context) *Game {
+	g2 := *g
+	g2.ctx = ctx
+	return &g2
+}
+
+// context returns the context of the commands given through this Game.
+func (g *Game) context() context.Context {
+	if g.ctx == nil {
+		return context.Background()
+	}
+
+	return g.ctx
+}
+
 // NextClientID returns the next available ID to use for a client.
 func (g *Game) NextClientID() int {
-	g.safeClientLastID.mutex.Lock()
-	defer g.safeClientLastID.mutex.Unlock()
+	var id int
+	g.do("NextClientID", func() {
+		g.state.lastClientID++
+		id = g.state.lastClientID
+	})
 
-	g.safeClientLastID.lastID++
-	return g.safeClientLastID.lastID
+	return id
 }
 
 // RegisterClient registers a client with the game.
 func (g *Game) RegisterClient(client client) {
-	g.safeClients.mutex.Lock()
-	g.safeClients.clients[client] = true
-	g.safeClients.mutex.Unlock()
+	g.do("RegisterClient", func() {
+		g.state.clients[client] = true
+		if sc, ok := client.(spectatorClient); ok && sc.Spectator() {
+			g.state.spectators[client] = true
+		}
 
-	log.WithFields(log.Fields{"room": g.Room, "client": client.RemoteAddr()}).Info("registered client")
+		g.clientLog(client).Info("registered client")
 
-	g.SendUpdate()
+		// the new player also gets the recent messages, so they can catch up on the discussion
+		u := g.publish(g.updatePayload(false), client)
+		u.Username = client.Name()
+		u.Messages = g.messages()
+		g.send(client, u)
+	})
 }
 
 // UnregisterClient registers a client from the game.
+// It is safe to unregister a client more than once.
 func (g *Game) UnregisterClient(client client) {
-	g.safeClients.mutex.Lock()
-	delete(g.safeClients.clients, client)
-	nclients := len(g.safeClients.clients)
-	g.safeClients.mutex.Unlock()
+	g.do("UnregisterClient", func() {
+		g.unregister(client)
+	})
+}
+
+func (g *Game) unregister(client client) {
+	if _, found := g.state.clients[client]; !found {
+		return
+	}
+	delete(g.state.clients, client)
+	delete(g.state.spectators, client)
 
 	shouldReset := false
-	g.safeCards.mutex.RLock()
-	_, found := g.safeCards.cards[client]
-	ncards := len(g.safeCards.cards)
-	g.safeCards.mutex.RUnlock()
-
-	if found {
-		g.safeCards.mutex.Lock()
-		delete(g.safeCards.cards, client)
-		g.safeCards.mutex.Unlock()
-
-		// was at 1, now will be at zero. reset the game
-		if ncards == 1 {
+	if _, found := g.state.cards[client]; found {
+		delete(g.state.cards, client)
+
+		// was the last card. reset the game
+		if len(g.state.cards) == 0 {
 			shouldReset = true
 		}
 	}
 
 	client.CloseChannel()
-	log.WithFields(log.Fields{"room": g.Room, "client": client.RemoteAddr()}).Info("unregistered client")
+	g.clientLog(client).Info("unregistered client")
 
-	if nclients == 0 {
+	if len(g.state.clients) == 0 {
 		g.reset()
-
-		g.safeDestroyAttempt.mutex.Lock()
-		g.safeDestroyAttempt.attempt++
-		attempt := g.safeDestroyAttempt.attempt
-		g.safeDestroyAttempt.mutex.Unlock()
-
-		go func() {
-			t := time.NewTimer(time.Millisecond * time.Duration(g.waitToDestroy))
-			<-t.C
-
-			g.safeDestroyAttempt.mutex.RLock()
-			currentAttempt := g.safeDestroyAttempt.attempt
-			g.safeDestroyAttempt.mutex.RUnlock()
-
-			if attempt != currentAttempt {
-				return
-			}
-
-			g.safeClients.mutex.RLock()
-			defer g.safeClients.mutex.RUnlock()
-
-			if len(g.safeClients.clients) == 0 {
-				g.onComplete <- g
-			}
-		}()
+		g.destroyLater()
 
 		return
 	}
 
 	if shouldReset {
-		g.Reset()
+		g.reset()
+		g.broadcast(g.updatePayload(true))
 		return
 	}
 
-	g.SendUpdate()
+	// the client that left may have been the last one everybody was waiting on
+	g.revealIfEveryoneVoted()
+	g.broadcast(g.updatePayload(false))
+}
+
+// clientLog returns a log entry for something that happened to a client of the game.
+func (g *Game) clientLog(c client) *log.Entry {
+	fields := log.Fields{"room": g.Room, "client_id": c.ID(), "client": c.RemoteAddr()}
+	if cc, ok := c.(connClient); ok {
+		fields["conn_id"] = cc.ConnID()
+	}
+
+	return log.WithFields(fields)
 }
 
 // SendUpdate will send an update to all clients
 func (g *Game) SendUpdate() {
-	g.broadcast(g.updatePayload(false))
+	g.do("SendUpdate", func() {
+		g.broadcast(g.updatePayload(false))
+	})
+}
+
+// sendUpdateTo will send a full update to a single client.
+func (g *Game) sendUpdateTo(c client) {
+	u := g.updatePayload(false)
+	u.Seq = g.state.seq
+	u.Username = c.Name()
+	g.send(c, u)
+}
+
+// Resync sends a full update to a client which noticed it missed a delta.
+func (g *Game) Resync(c client) {
+	g.do("Resync", func() {
+		if _, registered := g.state.clients[c]; registered {
+			g.sendUpdateTo(c)
+		}
+	})
 }
 
 // broadcast will send a message to all registered clients.
 func (g *Game) broadcast(obj interface{}) {
-	g.safeClients.mutex.RLock()
-	defer g.safeClients.mutex.RUnlock()
+	if u, ok := obj.(wsUpdate); ok {
+		g.publish(u, nil)
+		return
+	}
+
+	ctx, span := g.startBroadcast()
+	defer span.End()
+
+	for client := range g.state.clients {
+		g.sendContext(ctx, client, obj)
+	}
+}
+
+// publish numbers an update and sends it to all registered clients, other than except. Clients that
+// asked for deltas are only sent what changed since the previous update. Returns the numbered update.
+func (g *Game) publish(u wsUpdate, except client) wsUpdate {
+	ctx, span := g.startBroadcast()
+	defer span.End()
+
+	g.state.seq++
+	u.Seq = g.state.seq
+
+	var delta *wsDelta
+	if g.state.last != nil && g.state.sinceSnapshot < snapshotInterval {
+		delta = diff(g.state.last, &u)
+		delta.shared = &sharedJSON{}
+		g.state.sinceSnapshot++
+	} else {
+		g.state.sinceSnapshot = 0
+	}
+	last := u
+	g.state.last = &last
+
+	u.shared = &sharedJSON{}
+	for client := range g.state.clients {
+		if client == except {
+			continue
+		}
 
-	for client := range g.safeClients.clients {
-		if o, ok := obj.(wsUpdate); ok {
-			o.Username = client.Name()
-			obj = o
+		if dc, ok := client.(deltaClient); ok && delta != nil && dc.WantsDeltas() {
+			d := *delta
+			d.Username = client.Name()
+			g.sendContext(ctx, client, &d)
+			continue
 		}
 
-		client.Send(obj)
+		cu := u
+		cu.Username = client.Name()
+		g.sendContext(ctx, client, cu)
 	}
+
+	u.shared = nil
+	return u
 }
 
-// errorPayload returns an object which can be sent to the client which holds an error.
-func (g *Game) errorPayload(errstr string) *wsError {
-	return &wsError{errstr}
+// errorPayload returns an object which can be sent to the client which holds an error, translated for
+// the client. errstr is a key of the i18n catalog, or a literal message.
+func (g *Game) errorPayload(c client, errstr string) *wsError {
+	return &wsError{translate(c, errstr)}
+}
+
+// translate returns a message of the i18n catalog in the locale of the client.
+func translate(c client, key string) string {
+	locale := i18n.Default
+	if lc, ok := c.(localeClient); ok {
+		locale = lc.Locale()
+	}
+
+	return i18n.T(locale, key)
+}
+
+// disconnect sends the client why it's being disconnected, and has it tell the remote end too.
+func (g *Game) disconnect(c client, reason string) {
+	g.send(c, g.errorPayload(c, reason))
+	if cr, ok := c.(closeReasonClient); ok {
+		cr.SetCloseReason(translate(c, reason))
+	}
 }
 
 // updatePayload returns a game update object which can be broadcasted to clients.
 func (g *Game) updatePayload(reset bool) wsUpdate {
 	var u wsUpdate
 
-	g.safeCards.mutex.RLock()
-	cards := make([]*wsCard, 0, len(g.safeCards.cards))
-	for c, card := range g.safeCards.cards {
+	u.Topic = g.state.topic
+	u.Players, u.Spectators, u.Presence = g.players()
+	u.Absent = g.absent()
+	u.Deck = g.state.deck.Name
+	u.Cards = g.cards()
+	if g.state.reveal {
+		markOutliers(u.Cards, g.state.deck, g.state.outlierSteps)
+	}
+	u.Revealed = g.state.reveal
+	u.Reset = reset
+	u.Round = g.state.round
+	u.Rounds = g.state.rounds
+	u.Insights = g.state.insights
+	u.Elapsed = int(time.Now().Sub(g.state.clock).Seconds())
+
+	return u
+}
+
+// cards returns the cards selected in the current round.
+func (g *Game) cards() []*wsCard {
+	cards := make([]*wsCard, 0, len(g.state.cards))
+	for c, card := range g.state.cards {
 		cards = append(cards, &wsCard{
 			Card:     card,
 			Player:   c.Name(),
 			PlayerID: c.ID(),
 		})
 	}
-	u.Topic = g.Topic()
-	u.Players = g.players()
-	u.Deck = g.safeCards.deck.Name
-	u.Cards = cards
-	u.Revealed = g.safeCards.reveal
-	u.Reset = reset
-	g.safeCards.mutex.RUnlock()
 
-	g.safeClock.mutex.RLock()
-	u.Elapsed = int(time.Now().Sub(g.safeClock.clock).Seconds())
-	g.safeClock.mutex.RUnlock()
+	// in the same order every time, so that unchanged cards aren't sent again as a delta
+	sort.Slice(cards, func(i, j int) bool {
+		return cards[i].PlayerID < cards[j].PlayerID
+	})
 
-	return u
+	return cards
 }
 
-func (g *Game) players() map[int]string {
-	g.safeClients.mutex.RLock()
-	defer g.safeClients.mutex.RUnlock()
-
+func (g *Game) players() (map[int]string, []int, map[int]Presence) {
 	players := make(map[int]string)
-	for client := range g.safeClients.clients {
+	spectators := make([]int, 0, len(g.state.spectators))
+	presence := make(map[int]Presence)
+	for client := range g.state.clients {
 		players[client.ID()] = client.Name()
+		if g.state.spectators[client] {
+			spectators = append(spectators, client.ID())
+		}
+		if p := presenceOf(client); p != PresenceActive {
+			presence[client.ID()] = p
+		}
+	}
+	sort.Ints(spectators)
+
+	return players, spectators, presence
+}
+
+// absent returns the names of the players on the roster who aren't in the room.
+func (g *Game) absent() []string {
+	present := make(map[string]bool, len(g.state.clients))
+	for client := range g.state.clients {
+		present[strings.ToLower(client.Name())] = true
 	}
 
-	return players
+	absent := make([]string, 0)
+	for _, name := range g.state.roster {
+		if !present[strings.ToLower(name)] {
+			absent = append(absent, name)
+		}
+	}
+
+	return absent
 }
 
-// SetTopic will set the topic of the room in a concurrency-safe manner.
+// presenceOf returns the presence of a client. Clients that don't track presence are always active.
+func presenceOf(c client) Presence {
+	if pc, ok := c.(presenceClient); ok {
+		return pc.Presence()
+	}
+
+	return PresenceActive
+}
+
+// SetSkipAway sets whether away players are left out when checking if everyone has voted.
+func (g *Game) SetSkipAway(skip bool) {
+	g.do("SetSkipAway", func() {
+		g.state.skipAway = skip
+	})
+}
+
+// SetDestroyDelay sets how long the room stays open after the last client leaves. A room which is
+// already waiting to be destroyed waits this long from now instead.
+func (g *Game) SetDestroyDelay(d time.Duration) {
+	g.do("SetDestroyDelay", func() {
+		g.state.destroyDelay = d
+		if g.state.destroyAttempt > 0 && len(g.state.clients) == 0 {
+			g.destroyLater()
+		}
+	})
+}
+
+// SetPinned sets whether the room stays open when the last client leaves. A pinned room is only
+// destroyed by Close. Unpinning a room nobody is in destroys it after its destroy delay.
+func (g *Game) SetPinned(pinned bool) {
+	g.do("SetPinned", func() {
+		wasPinned := g.state.pinned
+		g.state.pinned = pinned
+		if wasPinned && !pinned && len(g.state.clients) == 0 {
+			g.destroyLater()
+		}
+	})
+}
+
+// Pinned returns whether the room stays open when the last client leaves, see SetPinned.
+func (g *Game) Pinned() bool {
+	pinned := false
+	g.do("Pinned", func() {
+		pinned = g.state.pinned
+	})
+
+	return pinned
+}
+
+// destroyLater destroys the room after its destroy delay, unless a client joins first or the room is
+// pinned. Only the last call counts.
+func (g *Game) destroyLater() {
+	g.state.destroyAttempt++
+	if g.state.pinned {
+		return
+	}
+
+	attempt := g.state.destroyAttempt
+	time.AfterFunc(g.state.destroyDelay, func() {
+		g.do("destroy", func() {
+			if attempt == g.state.destroyAttempt && len(g.state.clients) == 0 && !g.state.pinned {
+				close(g.stopped)
+			}
+		})
+	})
+}
+
+// SetRoster sets the names of the players expected to vote. Once there is a roster, the cards are
+// revealed when the players on it who are in the room have voted, without waiting on anyone else.
+// Names are matched regardless of case.
+func (g *Game) SetRoster(names []string) {
+	g.do("SetRoster", func() {
+		if slices.Equal(names, g.state.roster) {
+			return
+		}
+
+		g.state.roster = append([]string(nil), names...)
+		g.state.rostered = make(map[string]bool, len(names))
+		for _, name := range names {
+			g.state.rostered[strings.ToLower(name)] = true
+		}
+
+		g.revealIfEveryoneVoted()
+		g.broadcast(g.updatePayload(false))
+	})
+}
+
+// PresenceChanged should be called when a client's presence has changed, so that other players can be
+// told, and the cards revealed if the game was only waiting on that player.
+func (g *Game) PresenceChanged() {
+	g.do("PresenceChanged", func() {
+		g.revealIfEveryoneVoted()
+		g.broadcast(g.updatePayload(false))
+	})
+}
+
+// SetPresenceInterval sets how often the room checks whether the presence of its players changed. A
+// player's presence can change without them doing anything, such as when they go idle, so nothing else
+// would tell the room.
+func (g *Game) SetPresenceInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	g.do("SetPresenceInterval", func() {
+		g.presence.Reset(d)
+	})
+}
+
+// checkPresence tells the room about players whose presence changed since the last update.
+func (g *Game) checkPresence() {
+	if g.state.last == nil {
+		return
+	}
+
+	if _, _, presence := g.players(); reflect.DeepEqual(presence, g.state.last.Presence) {
+		return
+	}
+
+	g.revealIfEveryoneVoted()
+	g.broadcast(g.updatePayload(false))
+}
+
+// clientByID returns the registered client with the specified ID, or nil if there isn't one.
+func (g *Game) clientByID(id int) client {
+	for client := range g.state.clients {
+		if client.ID() == id {
+			return client
+		}
+	}
+
+	return nil
+}
+
+// SetTopic will set the topic of the room.
 func (g *Game) SetTopic(topic string) {
 	if !validTopixRx.MatchString(topic) || !withLetterOrNumberRx.MatchString(topic) {
 		return
 	}
 
-	g.safeTopic.mutex.Lock()
+	g.do("SetTopic", func() {
+		if topic == g.state.topic {
+			return
+		}
 
-	didChange := false
-	if topic != g.safeTopic.topic {
-		didChange = true
+		g.state.topic = topic
+		g.broadcast(g.updatePayload(false))
+	})
+}
 
-		g.safeTopic.topic = topic
+// Chat sends a chat message from a client to everyone in the room. Messages follow the same rules as
+// topics. Returns false if the message is invalid.
+func (g *Game) Chat(c client, text string) bool {
+	if !validChatRx.MatchString(text) || !withLetterOrNumberRx.MatchString(text) {
+		return false
 	}
 
-	g.safeTopic.mutex.Unlock()
+	g.addMessage(c, messageKindChat, text)
+	return true
+}
 
-	if didChange {
-		g.SendUpdate()
+// React sends an emoji reaction from a client to everyone in the room. Returns false if the emoji is
+// not one of the allowed Reactions.
+func (g *Game) React(c client, emoji string) bool {
+	for _, r := range Reactions {
+		if r == emoji {
+			g.addMessage(c, messageKindReaction, emoji)
+			return true
+		}
 	}
+
+	return false
 }
 
-// Topic will return the topic of the room in a concurrency-safe manner.
+// AnnounceCommit tells everyone in the room that a client committed the estimate card to a story.
+func (g *Game) AnnounceCommit(c client, story, card string) {
+	g.addMessage(c, messageKindCommit, fmt.Sprintf("%s: %s", story, card))
+}
+
+// addMessage keeps the message in the history and broadcasts it to everyone.
+func (g *Game) addMessage(c client, kind, text string) {
+	m := &wsMessage{
+		Kind:     kind,
+		PlayerID: c.ID(),
+		Player:   c.Name(),
+		Text:     text,
+		Time:     time.Now().Unix(),
+	}
+
+	op := "Chat"
+	if kind == messageKindReaction {
+		op = "React"
+	} else if kind == messageKindCommit {
+		op = "AnnounceCommit"
+	}
+
+	g.do(op, func() {
+		if len(g.state.messages) >= chatHistorySize {
+			g.state.messages = append(g.state.messages[:0], g.state.messages[1:]...)
+		}
+		g.state.messages = append(g.state.messages, m)
+
+		g.broadcast(&wsChat{m})
+	})
+}
+
+// messages returns a copy of the recent messages.
+func (g *Game) messages() []*wsMessage {
+	messages := make([]*wsMessage, len(g.state.messages))
+	copy(messages, g.state.messages)
+	return messages
+}
+
+// Topic will return the topic of the room.
 func (g *Game) Topic() string {
-	g.safeTopic.mutex.RLock()
-	defer g.safeTopic.mutex.RUnlock()
+	var topic string
+	g.do("Topic", func() {
+		topic = g.state.topic
+	})
 
-	return g.safeTopic.topic
+	return topic
 }
 
 // SetDeck changes the active deck being used.
 func (g *Game) SetDeck(deck *deck.Deck) {
-	g.safeCards.mutex.Lock()
-	if deck == g.safeCards.deck {
-		g.safeCards.mutex.Unlock()
-		return
-	}
-	g.safeCards.deck = deck
-	g.safeCards.mutex.Unlock()
+	g.do("SetDeck", func() {
+		if deck == g.state.deck {
+			return
+		}
 
-	g.Reset()
+		g.state.deck = deck
+		g.reset()
+		g.broadcast(g.updatePayload(true))
+	})
 }
 
 // Deck returns the active deck being used.
 func (g *Game) Deck() *deck.Deck {
-	g.safeCards.mutex.RLock()
-	defer g.safeCards.mutex.RUnlock()
-	return g.safeCards.deck
+	var d *deck.Deck
+	g.do("Deck", func() {
+		d = g.state.deck
+	})
+
+	return d
 }
 
 // AddCard is when a client has selected an individual card.
 func (g *Game) AddCard(c client, card int, deck string) {
-	g.safeCards.mutex.Lock()
+	g.do("AddCard", func() {
+		if deck != g.state.deck.Name {
+			g.clientLog(c).Warnf("client is out of sync: got %s, expects %s", deck, g.state.deck.Name)
+			g.send(c, g.errorPayload(c, "error.out_of_sync"))
+			return
+		}
+
+		if _, err := g.state.deck.GetCard(card); err != nil {
+			g.clientLog(c).Warnf("client submitted an invalid card (%d) for deck \"%s\"", card, g.state.deck.Name)
+			g.send(c, g.errorPayload(c, "error.invalid_card"))
+			return
+		}
+
+		if g.state.spectators[c] {
+			g.clientLog(c).Warn("spectator submitted a card")
+			g.sendUpdateTo(c)
+			return
+		}
+
+		if g.state.reveal {
+			// votes are locked once a round is revealed. the client is likely a step behind, so bring it up to date
+			g.clientLog(c).Warnf("client submitted a card after round %d was revealed", g.state.round)
+			g.sendUpdateTo(c)
+			return
+		}
+
+		if _, registered := g.state.clients[c]; !registered {
+			g.clientLog(c).Warn("unregistered client submitted a card")
+			return
+		}
+
+		g.state.cards[c] = card
 
-	if deck != g.safeCards.deck.Name {
-		log.WithFields(log.Fields{"room": g.Room, "client": c.RemoteAddr()}).Warnf("client is out of sync: got %s, expects %s", deck, g.safeCards.deck.Name)
-		c.Send(g.errorPayload("Your game is out of sync. Please refresh your browser."))
-		g.safeCards.mutex.Unlock()
+		g.revealIfEveryoneVoted()
+		g.broadcast(g.updatePayload(false))
+	})
+}
+
+// revealIfEveryoneVoted reveals the cards once every voter has selected a card.
+// Spectators are never waited on, and neither are away players if skipAway is set, nor players who
+// aren't on the roster if there is one. While none of the players on the roster are here, everyone is
+// waited on as if there were no roster.
+func (g *Game) revealIfEveryoneVoted() {
+	if len(g.state.cards) == 0 {
 		return
 	}
 
-	if _, err := g.safeCards.deck.GetCard(card); err != nil {
-		log.WithFields(log.Fields{"room": g.Room, "client": c.RemoteAddr()}).Warnf("client submitted an invalid card (%d) for deck \"%s\"", card, g.safeCards.deck.Name)
-		c.Send(g.errorPayload("Your game had an invalid card. Please refresh your browser."))
-		g.safeCards.mutex.Unlock()
+	voters := make([]client, 0, len(g.state.clients))
+	rostered := false
+	for c := range g.state.clients {
+		if g.state.spectators[c] || (g.state.skipAway && presenceOf(c) == PresenceAway) {
+			continue
+		}
+
+		voters = append(voters, c)
+		if g.state.rostered[strings.ToLower(c.Name())] {
+			rostered = true
+		}
+	}
+
+	for _, c := range voters {
+		if _, voted := g.state.cards[c]; voted {
+			continue
+		}
+
+		if rostered && !g.state.rostered[strings.ToLower(c.Name())] {
+			continue
+		}
+
 		return
 	}
 
-	g.safeCards.cards[c] = card
-	ncards := len(g.safeCards.cards)
-	g.safeCards.mutex.Unlock()
+	g.revealRound()
+}
+
+// Kick removes the client with the specified ID from the game, and lets them know why. If banFor is
+// greater than zero, the client's session is kept from rejoining for that long. reason is a key of the
+// i18n catalog, or a literal message. Returns false if there is no such client.
+func (g *Game) Kick(id int, reason string, banFor time.Duration) bool {
+	found := false
+	g.do("Kick", func() {
+		c := g.clientByID(id)
+		if c == nil {
+			return
+		}
+		found = true
+
+		if sc, ok := c.(sessionClient); ok && banFor > 0 && sc.Session() != "" {
+			g.state.banned[sc.Session()] = time.Now().Add(banFor)
+		}
+
+		g.clientLog(c).Info("kicked client")
+
+		g.disconnect(c, reason)
+		g.unregister(c)
+	})
+
+	return found
+}
+
+// Close disconnects every client, telling them why, and destroys the game. reason is a key of the i18n
+// catalog, or a literal message. Closing a game which has already been destroyed does nothing.
+func (g *Game) Close(reason string) {
+	g.do("Close", func() {
+		for c := range g.state.clients {
+			g.disconnect(c, reason)
+			c.CloseChannel()
+		}
+
+		g.state.clients = make(map[client]bool)
+		g.state.spectators = make(map[client]bool)
+		log.WithFields(log.Fields{"room": g.Room}).Info("room closed")
+
+		close(g.stopped)
+	})
+}
+
+// IsBanned returns true if the session was kicked from the game and may not rejoin yet.
+func (g *Game) IsBanned(session string) bool {
+	if session == "" {
+		return false
+	}
+
+	banned := false
+	g.do("IsBanned", func() {
+		until, found := g.state.banned[session]
+		if !found {
+			return
+		}
+
+		if time.Now().After(until) {
+			delete(g.state.banned, session)
+			return
+		}
+
+		banned = true
+	})
+
+	return banned
+}
+
+// Mute turns the client with the specified ID into a spectator. Spectators stay in the room, but can't
+// vote and aren't waited on before the cards are revealed. Returns false if there is no such client.
+func (g *Game) Mute(id int) bool {
+	return g.setSpectator(id, true)
+}
 
-	g.safeClients.mutex.RLock()
-	nclients := len(g.safeClients.clients)
-	g.safeClients.mutex.RUnlock()
+// Unmute allows a spectator to vote again. Returns false if there is no such client.
+func (g *Game) Unmute(id int) bool {
+	return g.setSpectator(id, false)
+}
 
-	if ncards == nclients {
-		g.safeCards.mutex.Lock()
-		g.safeCards.reveal = true
-		g.safeCards.mutex.Unlock()
+func (g *Game) setSpectator(id int, spectator bool) bool {
+	op := "Unmute"
+	if spectator {
+		op = "Mute"
 	}
 
-	g.SendUpdate()
+	found := false
+	g.do(op, func() {
+		c := g.clientByID(id)
+		if c == nil {
+			return
+		}
+		found = true
+
+		if spectator {
+			g.state.spectators[c] = true
+			delete(g.state.cards, c)
+
+			// the spectator may have been the last one everybody was waiting on
+			g.revealIfEveryoneVoted()
+		} else {
+			delete(g.state.spectators, c)
+		}
+
+		g.broadcast(g.updatePayload(false))
+	})
+
+	return found
 }
 
 // Reveal is when a client has requested to show all the cards.
 func (g *Game) Reveal() {
-	g.safeCards.mutex.Lock()
-	g.safeCards.reveal = true
-	g.safeCards.mutex.Unlock()
+	g.do("Reveal", func() {
+		g.revealRound()
+		g.broadcast(g.updatePayload(false))
+	})
+}
 
-	g.SendUpdate()
+// Revote starts a new round on the same topic. The votes of the revealed round are kept so the team
+// can see how their estimates converged. Nothing happens if the current round has not been revealed.
+func (g *Game) Revote() {
+	g.do("Revote", func() {
+		if !g.state.reveal {
+			return
+		}
+
+		cards := g.cards()
+		markOutliers(cards, g.state.deck, g.state.outlierSteps)
+		g.state.rounds = append(g.state.rounds, &wsRound{
+			Round: g.state.round,
+			Cards: cards,
+		})
+		g.state.round++
+		g.state.reveal = false
+		g.state.cards = make(map[client]int)
+		g.state.clock = time.Now()
+
+		g.broadcast(g.updatePayload(true))
+	})
+}
+
+// SetOutlierSteps sets how many steps in the deck a card must be from the median to be flagged as an
+// outlier once revealed. Zero flags the lowest and highest cards instead.
+func (g *Game) SetOutlierSteps(steps int) {
+	if steps < 0 {
+		steps = 0
+	}
+
+	g.do("SetOutlierSteps", func() {
+		g.state.outlierSteps = steps
+	})
+}
+
+// Round returns the number of the current round of voting.
+func (g *Game) Round() int {
+	var round int
+	g.do("Round", func() {
+		round = g.state.round
+	})
+
+	return round
 }
 
 // Reset is when a client has request that the entire game be reset.
 func (g *Game) Reset() {
-	g.reset()
-	g.broadcast(g.updatePayload(true))
+	g.do("Reset", func() {
+		g.reset()
+		g.broadcast(g.updatePayload(true))
+	})
 }
 
 func (g *Game) reset() {
-	g.safeCards.mutex.Lock()
-	g.safeCards.reveal = false
-	g.safeCards.cards = make(map[client]int)
-	g.safeCards.mutex.Unlock()
-
-	g.safeClock.mutex.Lock()
-	g.safeClock.clock = time.Now()
-	g.safeClock.mutex.Unlock()
+	g.nextEstimate()
+	g.state.reveal = false
+	g.state.cards = make(map[client]int)
+	g.state.round = 1
+	g.state.rounds = make([]*wsRound, 0)
+	g.state.clock = time.Now()
 }
 
 // RegisteredClientsCount returns the number of active registered clients
 func (g *Game) RegisteredClientsCount() int {
-	g.safeClients.mutex.RLock()
-	defer g.safeClients.mutex.RUnlock()
+	var n int
+	g.do("RegisteredClientsCount", func() {
+		n = len(g.state.clients)
+	})
+
+	return n
+}
+
+// markOutliers flags the cards which are outliers, using the order of the cards in the deck. Cards
+// that aren't estimates (such as "?") are never outliers. If steps is zero, the lowest and highest
+// cards are outliers, unless everyone agrees. Otherwise, any card more than steps away from the median
+// is an outlier.
+func markOutliers(cards []*wsCard, d *deck.Deck, steps int) {
+	values := make([]int, 0, len(cards))
+	for _, c := range cards {
+		if d.IsEstimate(c.Card) {
+			values = append(values, c.Card)
+		}
+	}
+
+	if len(values) < 2 {
+		return
+	}
+
+	sort.Ints(values)
+	low, high := values[0], values[len(values)-1]
+	if low == high {
+		return
+	}
 
-	return len(g.safeClients.clients)
+	mid := median(values)
+	for _, c := range cards {
+		if !d.IsEstimate(c.Card) {
+			continue
+		}
+
+		if steps == 0 {
+			c.Outlier = c.Card == low || c.Card == high
+		} else {
+			c.Outlier = math.Abs(float64(c.Card)-mid) > float64(steps)
+		}
+	}
 }
 
 func generateToken() (string, error) {
@@ -0,0 +1,236 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// These tests hammer a game from many goroutines at once. Run them with -race.
+
+const concurrentClients = 50
+
+func TestConcurrentVotesReveal(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	clients := registerConcurrentClients(g, concurrentClients)
+
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *concurrentClient) {
+			defer wg.Done()
+			g.AddCard(c, rand.Intn(10), g.Deck().Name)
+		}(c)
+	}
+	wg.Wait()
+
+	for _, c := range clients {
+		u := c.lastUpdate()
+		assert.True(t, u.Revealed)
+		assert.Equal(t, concurrentClients, len(u.Cards))
+	}
+}
+
+func TestConcurrentVotesAndLeaves(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	clients := registerConcurrentClients(g, concurrentClients)
+
+	// half of the room votes while the other half leaves. whoever is last, the cards must be revealed
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		wg.Add(1)
+		go func(i int, c *concurrentClient) {
+			defer wg.Done()
+			if i%2 == 0 {
+				g.AddCard(c, 1, g.Deck().Name)
+			} else {
+				g.UnregisterClient(c)
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	assert.Equal(t, concurrentClients/2, g.RegisteredClientsCount())
+	for i, c := range clients {
+		if i%2 == 0 {
+			u := c.lastUpdate()
+			assert.True(t, u.Revealed)
+			assert.Equal(t, concurrentClients/2, len(u.Cards))
+		}
+	}
+}
+
+func TestConcurrentLastVoterLeaves(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		g, _ := New("Test", "", nil)
+		c1, c2, c3 := newConcurrentClient(1), newConcurrentClient(2), newConcurrentClient(3)
+		g.RegisterClient(c1)
+		g.RegisterClient(c2)
+		g.RegisterClient(c3)
+		g.AddCard(c1, 1, g.Deck().Name)
+
+		// c1 leaving takes the last card with it, which resets the game. a reset at the same time must
+		// not be applied on top of a half finished unregister
+		var wg sync.WaitGroup
+		wg.Add(3)
+		go func() { defer wg.Done(); g.UnregisterClient(c1) }()
+		go func() { defer wg.Done(); g.Reset() }()
+		go func() { defer wg.Done(); g.AddCard(c2, 2, g.Deck().Name) }()
+		wg.Wait()
+
+		g.AddCard(c2, 2, g.Deck().Name)
+		g.AddCard(c3, 3, g.Deck().Name)
+		u := c3.lastUpdate()
+		assert.True(t, u.Revealed)
+		assert.Equal(t, 2, len(u.Players))
+		assert.Equal(t, 2, len(u.Cards))
+		for _, card := range u.Cards {
+			assert.NotEqual(t, 1, card.PlayerID)
+		}
+	}
+}
+
+func TestConcurrentChurn(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	g.SetDestroyDelay(time.Minute)
+
+	var wg sync.WaitGroup
+	clients := make([]*concurrentClient, concurrentClients)
+	for i := range clients {
+		clients[i] = newConcurrentClient(g.NextClientID())
+		wg.Add(1)
+		go func(c *concurrentClient) {
+			defer wg.Done()
+			g.RegisterClient(c)
+			for j := 0; j < 20; j++ {
+				switch rand.Intn(9) {
+				case 0:
+					g.Reveal()
+				case 1:
+					g.Reset()
+				case 2:
+					g.Revote()
+				case 3:
+					g.SetTopic(fmt.Sprintf("Topic %d", j))
+				case 4:
+					g.Chat(c, "Hello")
+				case 5:
+					g.Mute(rand.Intn(concurrentClients) + 1)
+				case 6:
+					g.Unmute(rand.Intn(concurrentClients) + 1)
+				default:
+					g.AddCard(c, rand.Intn(10), g.Deck().Name)
+				}
+			}
+			g.UnregisterClient(c)
+		}(clients[i])
+	}
+	wg.Wait()
+
+	assert.Equal(t, 0, g.RegisteredClientsCount())
+	for _, c := range clients {
+		c.mu.Lock()
+		assert.Equal(t, 1, c.closeChannelInvoked)
+		assert.Equal(t, 0, c.sentAfterClose)
+		c.mu.Unlock()
+	}
+}
+
+func TestConcurrentDestroy(t *testing.T) {
+	onComplete := make(chan *Game, 1)
+	g, _ := New("Test", "", onComplete)
+	g.SetDestroyDelay(time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentClients; i++ {
+		wg.Add(1)
+		go func(c *concurrentClient) {
+			defer wg.Done()
+			g.RegisterClient(c)
+			g.UnregisterClient(c)
+		}(newConcurrentClient(i + 1))
+	}
+	wg.Wait()
+
+	assert.Equal(t, g, <-onComplete)
+
+	// a destroyed game ignores anything else it is asked to do
+	g.RegisterClient(newConcurrentClient(99))
+	assert.Equal(t, 0, g.RegisteredClientsCount())
+}
+
+func registerConcurrentClients(g *Game, n int) []*concurrentClient {
+	clients := make([]*concurrentClient, n)
+	var wg sync.WaitGroup
+	for i := range clients {
+		clients[i] = newConcurrentClient(i + 1)
+		wg.Add(1)
+		go func(c *concurrentClient) {
+			defer wg.Done()
+			g.RegisterClient(c)
+		}(clients[i])
+	}
+	wg.Wait()
+
+	return clients
+}
+
+// concurrentClient is a client which may be used from many goroutines. It counts anything sent to it
+// after its channel was closed, which would panic with a real client.
+type concurrentClient struct {
+	id                  int
+	send                []interface{}
+	closeChannelInvoked int
+	sentAfterClose      int
+	mu                  sync.Mutex
+}
+
+func newConcurrentClient(id int) *concurrentClient {
+	return &concurrentClient{id: id}
+}
+
+func (c *concurrentClient) Send(o interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closeChannelInvoked > 0 {
+		c.sentAfterClose++
+	}
+	c.send = append(c.send, o)
+}
+
+func (c *concurrentClient) CloseChannel() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeChannelInvoked++
+}
+
+func (c *concurrentClient) RemoteAddr() string {
+	return fmt.Sprintf("1.2.3.4:%d", c.id)
+}
+
+func (c *concurrentClient) ID() int {
+	return c.id
+}
+
+func (c *concurrentClient) Name() string {
+	return fmt.Sprintf("Player %d", c.id)
+}
+
+// lastUpdate returns the last game update sent to the client.
+func (c *concurrentClient) lastUpdate() wsUpdate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := len(c.send) - 1; i >= 0; i-- {
+		if u, ok := c.send[i].(wsUpdate); ok {
+			return u
+		}
+	}
+
+	return wsUpdate{}
+}
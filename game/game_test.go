@@ -77,9 +77,10 @@ func TestAddCard(t *testing.T) {
 	u := c2.send[3].(wsUpdate)
 	sort.Sort(byID(u.Cards))
 
+	// with only two different cards, both players are outliers
 	assert.Equal(t, []*wsCard{
-		{1, 1, ""},
-		{2, 2, ""},
+		{1, 1, "", true},
+		{2, 2, "", true},
 	}, u.Cards)
 
 	assert.Equal(t, false, u.Reset)
@@ -130,22 +131,154 @@ func TestReveal(t *testing.T) {
 	assert.Equal(t, false, u.Reset)
 }
 
+func TestAddCardAfterReveal(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2 := newClientTest(1), newClientTest(2)
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+
+	g.AddCard(c1, 1, g.Deck().Name)
+	g.Reveal()
+	g.AddCard(c1, 2, g.Deck().Name)
+	g.AddCard(c2, 3, g.Deck().Name)
+
+	// c1 = 2 client registers + 1 card + 1 reveal + 1 resync
+	// c2 = 1 client register + 1 card + 1 reveal + 1 resync
+	assert.Equal(t, 5, len(c1.send))
+	assert.Equal(t, 4, len(c2.send))
+
+	u := c2.send[3].(wsUpdate)
+	assert.Equal(t, true, u.Revealed)
+	assert.Equal(t, []*wsCard{{1, 1, "", false}}, u.Cards)
+}
+
+func TestRevote(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2 := newClientTest(1), newClientTest(2)
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+
+	// can't start a new round until the current one has been revealed
+	g.Revote()
+	assert.Equal(t, 1, g.Round())
+	assert.Equal(t, 1, len(c2.send))
+
+	g.AddCard(c1, 1, g.Deck().Name)
+	g.AddCard(c2, 5, g.Deck().Name)
+	g.Revote()
+	assert.Equal(t, 2, g.Round())
+	assert.Equal(t, 4, len(c2.send))
+
+	u := c2.send[3].(wsUpdate)
+	assert.Equal(t, false, u.Revealed)
+	assert.Equal(t, true, u.Reset)
+	assert.Equal(t, 2, u.Round)
+	assert.Equal(t, []*wsCard{}, u.Cards)
+	assert.Equal(t, 1, len(u.Rounds))
+	assert.Equal(t, 1, u.Rounds[0].Round)
+	sort.Sort(byID(u.Rounds[0].Cards))
+	assert.Equal(t, []*wsCard{{1, 1, "", true}, {5, 2, "", true}}, u.Rounds[0].Cards)
+
+	g.AddCard(c1, 2, g.Deck().Name)
+	g.AddCard(c2, 2, g.Deck().Name)
+	g.Revote()
+	assert.Equal(t, 3, g.Round())
+	assert.Equal(t, 2, len(c2.send[6].(wsUpdate).Rounds))
+
+	// a reset starts a new story
+	g.Reset()
+	u = c2.send[7].(wsUpdate)
+	assert.Equal(t, 1, u.Round)
+	assert.Equal(t, []*wsRound{}, u.Rounds)
+}
+
+func TestOutliers(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2, c3, c4 := newClientTest(1), newClientTest(2), newClientTest(3), newClientTest(4)
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+	g.RegisterClient(c3)
+	g.RegisterClient(c4)
+
+	// 1, 3, 3, ?
+	g.AddCard(c1, 1, g.Deck().Name)
+	g.AddCard(c2, 3, g.Deck().Name)
+	g.AddCard(c3, 3, g.Deck().Name)
+
+	u := c1.send[len(c1.send)-1].(wsUpdate)
+	assert.False(t, u.Revealed)
+	for _, c := range u.Cards {
+		assert.False(t, c.Outlier)
+	}
+
+	g.AddCard(c4, 10, g.Deck().Name)
+	u = c1.send[len(c1.send)-1].(wsUpdate)
+	assert.True(t, u.Revealed)
+	sort.Sort(byID(u.Cards))
+	assert.Equal(t, []*wsCard{
+		{1, 1, "", true},
+		{3, 2, "", true},
+		{3, 3, "", true},
+		{10, 4, "", false},
+	}, u.Cards)
+}
+
+func TestMarkOutliers(t *testing.T) {
+	cards := func(values ...int) []*wsCard {
+		cards := make([]*wsCard, len(values))
+		for i, v := range values {
+			cards[i] = &wsCard{Card: v, PlayerID: i}
+		}
+		return cards
+	}
+	flagged := func(cards []*wsCard) []int {
+		ids := make([]int, 0)
+		for _, c := range cards {
+			if c.Outlier {
+				ids = append(ids, c.PlayerID)
+			}
+		}
+		return ids
+	}
+
+	c := cards(2, 2, 2)
+	markOutliers(c, deck.ModifiedFibonacci, 0)
+	assert.Equal(t, []int{}, flagged(c))
+
+	c = cards(1, 2, 2, 5, 11)
+	markOutliers(c, deck.ModifiedFibonacci, 0)
+	assert.Equal(t, []int{0, 3}, flagged(c))
+
+	// median is 3
+	c = cards(1, 2, 3, 4, 8)
+	markOutliers(c, deck.ModifiedFibonacci, 1)
+	assert.Equal(t, []int{0, 4}, flagged(c))
+
+	markOutliers(c, deck.ModifiedFibonacci, 5)
+	assert.Equal(t, []int{}, flagged(c))
+
+	// median is 2.5
+	c = cards(1, 2, 3, 6)
+	markOutliers(c, deck.ModifiedFibonacci, 1)
+	assert.Equal(t, []int{0, 3}, flagged(c))
+}
+
 func TestReset(t *testing.T) {
 	g, _ := New("Test", "", nil)
-	g.safeCards.reveal = true
-	g.safeCards.cards = map[client]int{newClientTest(1): 0, newClientTest(2): 1, newClientTest(3): 2}
+	g.state.reveal = true
+	g.state.cards = map[client]int{newClientTest(1): 0, newClientTest(2): 1, newClientTest(3): 2}
 
 	c1 := newClientTest(1)
 	g.RegisterClient(c1)
 
-	assert.True(t, time.Now().After(g.safeClock.clock))
-	clock := g.safeClock.clock
+	assert.True(t, time.Now().After(g.state.clock))
+	clock := g.state.clock
 
 	g.Reset()
 
 	// make sure clock is updated on reset
-	assert.True(t, time.Now().After(g.safeClock.clock))
-	assert.True(t, clock.Before(g.safeClock.clock))
+	assert.True(t, time.Now().After(g.state.clock))
+	assert.True(t, clock.Before(g.state.clock))
 
 	assert.Equal(t, 2, len(c1.send))
 
@@ -154,9 +287,9 @@ func TestReset(t *testing.T) {
 	assert.Equal(t, false, u1.Reset)
 	sort.Sort(byID(u1.Cards))
 	assert.Equal(t, []*wsCard{
-		{0, 1, ""},
-		{1, 2, ""},
-		{2, 3, ""},
+		{0, 1, "", true},
+		{1, 2, "", false},
+		{2, 3, "", true},
 	}, u1.Cards)
 
 	u2 := c1.send[1].(wsUpdate)
@@ -205,7 +338,7 @@ func TestUnregisterClient(t *testing.T) {
 	onComplete := make(chan *Game)
 
 	g, _ := New("Test", "", onComplete)
-	g.waitToDestroy = 1
+	g.SetDestroyDelay(time.Millisecond)
 
 	c1, c2 := newClientTest(1), newClientTest(2)
 	g.RegisterClient(c1)
@@ -240,6 +373,370 @@ func TestUnregisterClient(t *testing.T) {
 	timer.Stop()
 }
 
+func TestPinned(t *testing.T) {
+	onComplete := make(chan *Game)
+
+	g, _ := New("Test", "", onComplete)
+	g.SetDestroyDelay(time.Millisecond)
+	g.SetPinned(true)
+	assert.True(t, g.Pinned())
+
+	c1 := newClientTest(1)
+	g.RegisterClient(c1)
+	g.UnregisterClient(c1)
+
+	select {
+	case <-onComplete:
+		assert.Fail(t, "a pinned room should not be destroyed")
+	case <-time.After(5 * time.Millisecond):
+	}
+
+	// once unpinned, the empty room is destroyed
+	g.SetPinned(false)
+	select {
+	case g2 := <-onComplete:
+		assert.Equal(t, g, g2)
+	case <-time.After(time.Second):
+		assert.Fail(t, "should have been destroyed")
+	}
+}
+
+func TestSetDestroyDelay(t *testing.T) {
+	onComplete := make(chan *Game)
+
+	g, _ := New("Test", "", onComplete)
+	g.SetDestroyDelay(time.Hour)
+
+	c1 := newClientTest(1)
+	g.RegisterClient(c1)
+	g.UnregisterClient(c1)
+
+	// a room waiting to be destroyed waits for the new delay instead
+	g.SetDestroyDelay(time.Millisecond)
+	select {
+	case g2 := <-onComplete:
+		assert.Equal(t, g, g2)
+	case <-time.After(time.Second):
+		assert.Fail(t, "should have been destroyed")
+	}
+}
+
+func TestUnregisterClientTwice(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2 := newClientTest(1), newClientTest(2)
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+
+	g.UnregisterClient(c2)
+	g.UnregisterClient(c2)
+	assert.Equal(t, 1, c2.closeChannelInvoked)
+	assert.Equal(t, 3, len(c1.send))
+}
+
+func TestKick(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2, c3 := newClientTest(1), newClientTest(2), newClientTest(3)
+	c2.session = "session-2"
+	c3.session = "session-3"
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+	g.RegisterClient(c3)
+
+	g.AddCard(c1, 1, g.Deck().Name)
+	g.AddCard(c2, 1, g.Deck().Name)
+
+	assert.False(t, g.Kick(99, "Bye", 0))
+
+	// c3 was holding up the reveal
+	assert.True(t, g.Kick(3, "Bye", 0))
+	assert.Equal(t, 2, g.RegisteredClientsCount())
+	assert.Equal(t, 1, c3.closeChannelInvoked)
+	assert.Equal(t, "Bye", c3.closeReason)
+	assert.Equal(t, "Bye", c3.send[len(c3.send)-1].(*wsError).Error)
+	assert.False(t, g.IsBanned("session-3"))
+
+	u := c1.send[len(c1.send)-1].(wsUpdate)
+	assert.Equal(t, 2, len(u.Players))
+
+	assert.True(t, g.Kick(2, "Bye", time.Minute))
+	assert.True(t, g.IsBanned("session-2"))
+	assert.False(t, g.IsBanned(""))
+
+	g.state.banned["session-2"] = time.Now().Add(-time.Second)
+	assert.False(t, g.IsBanned("session-2"))
+}
+
+func TestClose(t *testing.T) {
+	onComplete := make(chan *Game, 1)
+	g, _ := New("Test", "", onComplete)
+	c1, c2 := newClientTest(1), newClientTest(2)
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+
+	g.Close("Closed")
+	assert.Equal(t, g, <-onComplete)
+	for _, c := range []*clientTest{c1, c2} {
+		assert.Equal(t, 1, c.closeChannelInvoked)
+		assert.Equal(t, "Closed", c.closeReason)
+		assert.Equal(t, "Closed", c.send[len(c.send)-1].(*wsError).Error)
+	}
+
+	// the game is gone, so nothing else happens
+	g.Close("Closed")
+	g.UnregisterClient(c1)
+	assert.Equal(t, 1, c1.closeChannelInvoked)
+	assert.Equal(t, 0, g.RegisteredClientsCount())
+}
+
+func TestMute(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2, c3 := newClientTest(1), newClientTest(2), newClientTest(3)
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+	g.RegisterClient(c3)
+
+	g.AddCard(c1, 1, g.Deck().Name)
+	g.AddCard(c2, 2, g.Deck().Name)
+
+	assert.False(t, g.Mute(99))
+
+	// everyone who can still vote has voted
+	assert.True(t, g.Mute(3))
+	u := c1.send[len(c1.send)-1].(wsUpdate)
+	assert.True(t, u.Revealed)
+	assert.Equal(t, []int{3}, u.Spectators)
+	assert.Equal(t, 3, len(u.Players))
+
+	// spectators can't vote
+	g.Revote()
+	n := len(c1.send)
+	g.AddCard(c3, 1, g.Deck().Name)
+	assert.Equal(t, n, len(c1.send))
+	assert.Equal(t, []*wsCard{}, c3.send[len(c3.send)-1].(wsUpdate).Cards)
+
+	// a muted voter loses their card
+	g.AddCard(c2, 2, g.Deck().Name)
+	g.Mute(2)
+	u = c1.send[len(c1.send)-1].(wsUpdate)
+	assert.False(t, u.Revealed)
+	assert.Equal(t, []*wsCard{}, u.Cards)
+	assert.Equal(t, []int{2, 3}, u.Spectators)
+
+	assert.True(t, g.Unmute(3))
+	u = c1.send[len(c1.send)-1].(wsUpdate)
+	assert.Equal(t, []int{2}, u.Spectators)
+}
+
+func TestRegisterSpectator(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2 := newClientTest(1), &spectatorClientTest{newClientTest(2)}
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+
+	u := c1.send[len(c1.send)-1].(wsUpdate)
+	assert.Equal(t, []int{2}, u.Spectators)
+
+	// nobody else needs to vote
+	g.AddCard(c1, 1, g.Deck().Name)
+	assert.True(t, c1.send[len(c1.send)-1].(wsUpdate).Revealed)
+}
+
+func TestPresence(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2, c3 := newClientTest(1), newClientTest(2), newClientTest(3)
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+	g.RegisterClient(c3)
+
+	c2.presence = PresenceIdle
+	c3.presence = PresenceAway
+	g.AddCard(c1, 1, g.Deck().Name)
+	g.AddCard(c2, 1, g.Deck().Name)
+
+	// away players are waited on by default
+	u := c1.send[len(c1.send)-1].(wsUpdate)
+	assert.False(t, u.Revealed)
+	assert.Equal(t, map[int]Presence{2: PresenceIdle, 3: PresenceAway}, u.Presence)
+
+	g.SetSkipAway(true)
+	g.PresenceChanged()
+	u = c1.send[len(c1.send)-1].(wsUpdate)
+	assert.True(t, u.Revealed)
+}
+
+func TestPresenceNeedsACard(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	g.SetSkipAway(true)
+	c1 := newClientTest(1)
+	c1.presence = PresenceAway
+	g.RegisterClient(c1)
+
+	g.PresenceChanged()
+	assert.False(t, c1.send[len(c1.send)-1].(wsUpdate).Revealed)
+}
+
+// idleClient goes idle at idleAt, like a player who stops doing anything. Its updates can be read while
+// the game runs.
+type idleClient struct {
+	*clientTest
+	idleAt  time.Time
+	updates chan wsUpdate
+}
+
+func newIdleClient(id int, idleAt time.Time) *idleClient {
+	return &idleClient{clientTest: newClientTest(id), idleAt: idleAt, updates: make(chan wsUpdate, 100)}
+}
+
+func (c *idleClient) Send(o interface{}) {
+	if u, ok := o.(wsUpdate); ok {
+		select {
+		case c.updates <- u:
+		default:
+		}
+	}
+}
+
+func (c *idleClient) Presence() Presence {
+	if time.Now().After(c.idleAt) {
+		return PresenceIdle
+	}
+
+	return PresenceActive
+}
+
+func TestPresenceInterval(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	g.SetPresenceInterval(5 * time.Millisecond)
+	c1, c2 := newIdleClient(1, time.Now().Add(20*time.Millisecond)), newIdleClient(2, time.Now().Add(time.Hour))
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+
+	// nothing else happens, but the room is told once player 1 goes idle
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case u := <-c2.updates:
+			if u.Presence[1] == PresenceIdle {
+				return
+			}
+		case <-timeout:
+			assert.Fail(t, "should have been told that player 1 is idle")
+			return
+		}
+	}
+}
+
+func TestRoster(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	alex, bea, guest := newClientTest(1), newClientTest(2), newClientTest(3)
+	alex.name, bea.name, guest.name = "Alex", "bea", "Guest"
+	g.RegisterClient(alex)
+	g.RegisterClient(guest)
+
+	g.SetRoster([]string{"Alex", "Bea", "Cy"})
+	u := alex.send[len(alex.send)-1].(wsUpdate)
+	assert.Equal(t, []string{"Bea", "Cy"}, u.Absent)
+
+	// setting the same roster again, as when a team's config is reloaded, tells no one
+	sent := len(alex.send)
+	g.SetRoster([]string{"Alex", "Bea", "Cy"})
+	assert.Equal(t, sent, len(alex.send))
+
+	// the guest isn't waited on, and neither are the players on the roster who aren't here
+	g.AddCard(alex, 1, g.Deck().Name)
+	assert.True(t, alex.send[len(alex.send)-1].(wsUpdate).Revealed)
+
+	g.Reset()
+	g.RegisterClient(bea)
+	u = alex.send[len(alex.send)-1].(wsUpdate)
+	assert.Equal(t, []string{"Cy"}, u.Absent)
+
+	g.AddCard(alex, 1, g.Deck().Name)
+	assert.False(t, alex.send[len(alex.send)-1].(wsUpdate).Revealed)
+	g.AddCard(bea, 2, g.Deck().Name)
+	assert.True(t, alex.send[len(alex.send)-1].(wsUpdate).Revealed)
+}
+
+func TestRosterOnlyGuests(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	g1, g2, g3 := newClientTest(1), newClientTest(2), newClientTest(3)
+	g1.name, g2.name, g3.name = "Guest 1", "Guest 2", "Guest 3"
+	g.RegisterClient(g1)
+	g.RegisterClient(g2)
+	g.RegisterClient(g3)
+	g.SetRoster([]string{"Alex", "Bea"})
+
+	// with nobody on the roster here, every guest is waited on
+	g.AddCard(g1, 1, g.Deck().Name)
+	assert.False(t, g1.send[len(g1.send)-1].(wsUpdate).Revealed)
+	g.AddCard(g2, 1, g.Deck().Name)
+	assert.False(t, g1.send[len(g1.send)-1].(wsUpdate).Revealed)
+	g.AddCard(g3, 1, g.Deck().Name)
+	assert.True(t, g1.send[len(g1.send)-1].(wsUpdate).Revealed)
+}
+
+func TestChat(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2 := newClientTest(1), newClientTest(2)
+	c1.name = "One"
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+
+	assert.False(t, g.Chat(c1, "Should be invalid: \t"))
+	assert.False(t, g.Chat(c1, "!!!"))
+	assert.False(t, g.Chat(c1, strings.Repeat("a", ChatMaxLength+1)))
+	assert.Equal(t, 1, len(c2.send))
+
+	assert.True(t, g.Chat(c1, "Why a 13?"))
+	assert.Equal(t, 2, len(c2.send))
+	m := c2.send[1].(*wsChat).Message
+	assert.Equal(t, messageKindChat, m.Kind)
+	assert.Equal(t, 1, m.PlayerID)
+	assert.Equal(t, "One", m.Player)
+	assert.Equal(t, "Why a 13?", m.Text)
+	assert.Equal(t, m, c1.send[2].(*wsChat).Message)
+}
+
+func TestReact(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1 := newClientTest(1)
+	g.RegisterClient(c1)
+
+	assert.False(t, g.React(c1, "x"))
+	assert.Equal(t, 1, len(c1.send))
+
+	assert.True(t, g.React(c1, "👍"))
+	m := c1.send[1].(*wsChat).Message
+	assert.Equal(t, messageKindReaction, m.Kind)
+	assert.Equal(t, "👍", m.Text)
+}
+
+func TestMessageHistory(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1 := newClientTest(1)
+	g.RegisterClient(c1)
+	assert.Equal(t, []*wsMessage{}, c1.send[0].(wsUpdate).Messages)
+
+	for i := 0; i < chatHistorySize+5; i++ {
+		g.Chat(c1, fmt.Sprintf("Message %d", i))
+	}
+
+	// late joiners get the most recent messages
+	c2 := newClientTest(2)
+	g.RegisterClient(c2)
+	u := c2.send[0].(wsUpdate)
+	assert.Equal(t, chatHistorySize, len(u.Messages))
+	assert.Equal(t, "Message 5", u.Messages[0].Text)
+	assert.Equal(t, fmt.Sprintf("Message %d", chatHistorySize+4), u.Messages[chatHistorySize-1].Text)
+
+	// but everyone else doesn't get them again
+	assert.Nil(t, c1.send[len(c1.send)-1].(wsUpdate).Messages)
+
+	// and the welcome update mustn't be replaced by a later one
+	assert.False(t, u.Coalesce())
+	assert.True(t, c1.send[len(c1.send)-1].(wsUpdate).Coalesce())
+}
+
 func TestNextClientID(t *testing.T) {
 	g, _ := New("Test", "", nil)
 	assert.Equal(t, 1, g.NextClientID())
@@ -253,13 +750,17 @@ type clientTest struct {
 	port                int
 	id                  int
 	name                string
+	session             string
+	closeReason         string
+	presence            Presence
 }
 
 func newClientTest(id int) *clientTest {
 	return &clientTest{
-		send: make([]interface{}, 0),
-		port: id,
-		id:   id,
+		send:     make([]interface{}, 0),
+		port:     id,
+		id:       id,
+		presence: PresenceActive,
 	}
 }
 
@@ -283,8 +784,28 @@ func (c *clientTest) Name() string {
 	return c.name
 }
 
+func (c *clientTest) Presence() Presence {
+	return c.presence
+}
+
+func (c *clientTest) Session() string {
+	return c.session
+}
+
+func (c *clientTest) SetCloseReason(reason string) {
+	c.closeReason = reason
+}
+
 type byID []*wsCard
 
 func (b byID) Len() int           { return len(b) }
 func (b byID) Less(i, j int) bool { return b[i].PlayerID < b[j].PlayerID }
 func (b byID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+type spectatorClientTest struct {
+	*clientTest
+}
+
+func (c *spectatorClientTest) Spectator() bool {
+	return true
+}
@@ -0,0 +1,294 @@
+package game
+
+import (
+	"math"
+	"sort"
+
+	"github.com/synacor/sibyl/deck"
+)
+
+// historySize is the number of revealed rounds kept in a game's history
+const historySize = 500
+
+// HistoryRound is a revealed round of voting, as kept in the game's history.
+type HistoryRound struct {
+	// Estimate numbers the things estimated during the session. Re-votes keep the number of the round
+	// they follow, while resetting the game or changing the deck starts a new estimate.
+	Estimate int    `json:"estimate"`
+	Round    int    `json:"round"`
+	Topic    string `json:"topic"`
+	Deck     string `json:"deck"`
+
+	// Votes holds the card each player voted, sorted by player ID
+	Votes []Vote `json:"votes"`
+
+	// cards holds the votes as positions in the deck by player ID, which is what insights are measured in
+	cards map[int]int
+	deck  *deck.Deck
+}
+
+// Vote is the card a player voted in a revealed round. Players are told apart by ID, since two of them
+// may have the same name.
+type Vote struct {
+	PlayerID int    `json:"playerID"`
+	Player   string `json:"player"`
+	Card     string `json:"card"`
+}
+
+// PlayerInsight summarizes how a player's votes compared to the rest of the team over the session.
+// Distances are in steps of the deck, and positive when the player voted higher than the team.
+type PlayerInsight struct {
+	PlayerID int    `json:"playerID"`
+	Player   string `json:"player"`
+
+	// Rounds is how many rounds the player voted an estimate in, rather than a card such as "?"
+	Rounds int `json:"rounds"`
+
+	// FromMedian is how far the player's votes were from the median vote of their round, on average
+	FromMedian float64 `json:"fromMedian"`
+
+	// FromFinal is how far the player's votes were from the median vote of the last round of the same
+	// estimate, on average. For an estimate still being voted on, that is its latest revealed round.
+	FromFinal float64 `json:"fromFinal"`
+}
+
+// History is the revealed rounds of a session, along with what they say about each player when the
+// room has insights enabled.
+type History struct {
+	Rounds []*HistoryRound `json:"rounds"`
+
+	// Insights is null unless the room has insights enabled, see SetInsights
+	Insights []*PlayerInsight `json:"insights"`
+}
+
+// Estimate is what the team settled on in a revealed round.
+type Estimate struct {
+	Topic string
+	Deck  string
+	Card  string
+}
+
+// Estimate returns the estimate of the revealed round, which is the median of the cards voted. Between
+// two cards, it's the higher one. Returns false if the round hasn't been revealed, or if nobody voted an
+// estimate.
+func (g *Game) Estimate() (Estimate, bool) {
+	var e Estimate
+	found := false
+	g.do("Estimate", func() {
+		if !g.state.reveal {
+			return
+		}
+
+		values := make([]int, 0, len(g.state.cards))
+		for _, card := range g.state.cards {
+			if g.state.deck.IsEstimate(card) {
+				values = append(values, card)
+			}
+		}
+		if len(values) == 0 {
+			return
+		}
+
+		sort.Ints(values)
+		e.Topic = g.state.topic
+		e.Deck = g.state.deck.Name
+		e.Card, _ = g.state.deck.GetCard(values[len(values)/2])
+		found = true
+	})
+
+	return e, found
+}
+
+// History returns the revealed rounds of the session. Players are told apart by ID, so that the votes
+// of two players with the same name aren't mixed up.
+func (g *Game) History() History {
+	var h History
+	g.do("History", func() {
+		h.Rounds = make([]*HistoryRound, len(g.state.history))
+		copy(h.Rounds, g.state.history)
+		if g.state.insights {
+			h.Insights = insights(h.Rounds)
+		}
+	})
+
+	return h
+}
+
+// SetInsights sets whether the history includes a summary of how each player votes. It's off unless
+// the room turns it on, since it singles players out.
+func (g *Game) SetInsights(enabled bool) {
+	g.do("SetInsights", func() {
+		g.setInsights(enabled)
+	})
+}
+
+// SetTeamInsights sets whether the team whose room it is has insights turned on. Insights are turned
+// on when the team turns them on, and off when it turns them off again, but insights the room turned on
+// itself are left on.
+func (g *Game) SetTeamInsights(enabled bool) {
+	g.do("SetTeamInsights", func() {
+		if enabled == g.state.teamInsights {
+			return
+		}
+
+		g.state.teamInsights = enabled
+		g.setInsights(enabled)
+	})
+}
+
+func (g *Game) setInsights(enabled bool) {
+	if enabled == g.state.insights {
+		return
+	}
+
+	g.state.insights = enabled
+	g.broadcast(g.updatePayload(false))
+}
+
+// SetOnReveal sets a function to call with each round kept in the history, such as to keep it elsewhere
+// too. It's called by the game's event loop, so it must not call the game.
+func (g *Game) SetOnReveal(fn func(HistoryRound)) {
+	g.do("SetOnReveal", func() {
+		g.state.onReveal = fn
+	})
+}
+
+// revealRound reveals the cards of the current round, and keeps its votes in the history. A round is
+// only kept once, however many times it's revealed.
+func (g *Game) revealRound() {
+	if g.state.reveal {
+		return
+	}
+	g.state.reveal = true
+
+	if len(g.state.cards) == 0 {
+		return
+	}
+
+	r := &HistoryRound{
+		Estimate: g.state.estimate,
+		Round:    g.state.round,
+		Topic:    g.state.topic,
+		Deck:     g.state.deck.Name,
+		Votes:    make([]Vote, 0, len(g.state.cards)),
+		cards:    make(map[int]int, len(g.state.cards)),
+		deck:     g.state.deck,
+	}
+	for c, card := range g.state.cards {
+		name, _ := g.state.deck.GetCard(card)
+		r.Votes = append(r.Votes, Vote{PlayerID: c.ID(), Player: c.Name(), Card: name})
+		r.cards[c.ID()] = card
+	}
+	sort.Slice(r.Votes, func(i, j int) bool {
+		return r.Votes[i].PlayerID < r.Votes[j].PlayerID
+	})
+
+	if len(g.state.history) >= historySize {
+		g.state.history = append(g.state.history[:0], g.state.history[1:]...)
+	}
+	g.state.history = append(g.state.history, r)
+
+	if g.state.onReveal != nil {
+		g.state.onReveal(*r)
+	}
+}
+
+// nextEstimate starts a new estimate in the history, unless nothing was revealed for the current one.
+func (g *Game) nextEstimate() {
+	if n := len(g.state.history); n > 0 && g.state.history[n-1].Estimate == g.state.estimate {
+		g.state.estimate++
+	}
+}
+
+// insights summarizes the votes of each player over rounds, sorted by player name and then ID. Rounds
+// with fewer than two estimates have nothing to compare against, and are left out.
+func insights(rounds []*HistoryRound) []*PlayerInsight {
+	// the median of the last round of each estimate
+	final := make(map[int]float64)
+	for _, r := range rounds {
+		if m, ok := r.median(); ok {
+			final[r.Estimate] = m
+		} else {
+			delete(final, r.Estimate)
+		}
+	}
+
+	byPlayer := make(map[int]*PlayerInsight)
+	fromFinal := make(map[int]int)
+	for _, r := range rounds {
+		m, ok := r.median()
+		if !ok {
+			continue
+		}
+
+		for _, v := range r.Votes {
+			card := r.cards[v.PlayerID]
+			if !r.deck.IsEstimate(card) {
+				continue
+			}
+
+			// a player who changed their name is shown with the latest one
+			pi, found := byPlayer[v.PlayerID]
+			if !found {
+				pi = &PlayerInsight{PlayerID: v.PlayerID}
+				byPlayer[v.PlayerID] = pi
+			}
+			pi.Player = v.Player
+
+			pi.Rounds++
+			pi.FromMedian += float64(card) - m
+			if f, ok := final[r.Estimate]; ok {
+				pi.FromFinal += float64(card) - f
+				fromFinal[v.PlayerID]++
+			}
+		}
+	}
+
+	result := make([]*PlayerInsight, 0, len(byPlayer))
+	for id, pi := range byPlayer {
+		pi.FromMedian = roundSteps(pi.FromMedian / float64(pi.Rounds))
+		if n := fromFinal[id]; n > 0 {
+			pi.FromFinal = roundSteps(pi.FromFinal / float64(n))
+		}
+		result = append(result, pi)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Player != result[j].Player {
+			return result[i].Player < result[j].Player
+		}
+		return result[i].PlayerID < result[j].PlayerID
+	})
+
+	return result
+}
+
+// median returns the median of the estimates voted in the round, and false if there were fewer than two.
+func (r *HistoryRound) median() (float64, bool) {
+	values := make([]int, 0, len(r.cards))
+	for _, card := range r.cards {
+		if r.deck.IsEstimate(card) {
+			values = append(values, card)
+		}
+	}
+
+	if len(values) < 2 {
+		return 0, false
+	}
+
+	sort.Ints(values)
+	return median(values), true
+}
+
+// median returns the median of values, which must be sorted and not empty.
+func median(values []int) float64 {
+	if n := len(values); n%2 == 0 {
+		return float64(values[n/2-1]+values[n/2]) / 2
+	}
+
+	return float64(values[len(values)/2])
+}
+
+// roundSteps rounds a distance in steps to one decimal, which is as precise as it's meaningful.
+func roundSteps(steps float64) float64 {
+	return math.Round(steps*10) / 10
+}
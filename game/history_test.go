@@ -0,0 +1,195 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	alex, bea, cy := newClientTest(1), newClientTest(2), newClientTest(3)
+	alex.name, bea.name, cy.name = "Alex", "Bea", "Cy"
+	g.RegisterClient(alex)
+	g.RegisterClient(bea)
+	g.RegisterClient(cy)
+
+	vote := func(a, b, c int) {
+		g.AddCard(alex, a, g.Deck().Name)
+		g.AddCard(bea, b, g.Deck().Name)
+		g.AddCard(cy, c, g.Deck().Name)
+	}
+
+	// the first estimate takes a re-vote: 5, 2, 2 and then 3, 3, 2
+	vote(4, 2, 2)
+	g.Reveal()
+	g.Revote()
+	vote(3, 3, 2)
+
+	// nothing revealed before a reset doesn't use up an estimate
+	g.Reset()
+	g.Reset()
+
+	// the second estimate: 8, 3, ?
+	g.SetTopic("Login page")
+	vote(5, 3, 10)
+
+	h := g.History()
+	assert.Nil(t, h.Insights)
+	if assert.Len(t, h.Rounds, 3) {
+		assert.Equal(t, []int{1, 1, 2}, []int{h.Rounds[0].Estimate, h.Rounds[1].Estimate, h.Rounds[2].Estimate})
+		assert.Equal(t, []int{1, 2, 1}, []int{h.Rounds[0].Round, h.Rounds[1].Round, h.Rounds[2].Round})
+		assert.Equal(t, []Vote{
+			{PlayerID: 1, Player: "Alex", Card: "5"},
+			{PlayerID: 2, Player: "Bea", Card: "2"},
+			{PlayerID: 3, Player: "Cy", Card: "2"},
+		}, h.Rounds[0].Votes)
+		assert.Equal(t, "Login page", h.Rounds[2].Topic)
+		assert.Equal(t, "Modified Fibonacci", h.Rounds[2].Deck)
+	}
+
+	g.SetInsights(true)
+	u := cy.send[len(cy.send)-1].(wsUpdate)
+	assert.True(t, u.Insights)
+
+	h = g.History()
+	assert.Equal(t, []*PlayerInsight{
+		{PlayerID: 1, Player: "Alex", Rounds: 3, FromMedian: 1, FromFinal: 0.7},
+		{PlayerID: 2, Player: "Bea", Rounds: 3, FromMedian: -0.3, FromFinal: -0.7},
+		{PlayerID: 3, Player: "Cy", Rounds: 2, FromMedian: -0.5, FromFinal: -1},
+	}, h.Insights)
+
+	g.SetInsights(false)
+	assert.Nil(t, g.History().Insights)
+}
+
+func TestTeamInsights(t *testing.T) {
+	g, _ := New("Test", "", nil)
+
+	// the team turns insights on and off
+	g.SetTeamInsights(true)
+	assert.NotNil(t, g.History().Insights)
+	g.SetTeamInsights(false)
+	assert.Nil(t, g.History().Insights)
+
+	// insights the room turned on are kept when the team's config is applied again
+	g.SetInsights(true)
+	g.SetTeamInsights(false)
+	assert.NotNil(t, g.History().Insights)
+
+	// once the team turned them on, the team turns them off
+	g.SetTeamInsights(true)
+	g.SetTeamInsights(false)
+	assert.Nil(t, g.History().Insights)
+}
+
+func TestHistorySize(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1 := newClientTest(1)
+	g.RegisterClient(c1)
+
+	for i := 0; i < historySize+5; i++ {
+		g.AddCard(c1, 1, g.Deck().Name)
+		g.Revote()
+	}
+
+	h := g.History()
+	assert.Len(t, h.Rounds, historySize)
+	assert.Equal(t, 6, h.Rounds[0].Round)
+}
+
+func TestInsightsNeedTwoEstimates(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2 := newClientTest(1), newClientTest(2)
+	c1.name, c2.name = "Alex", "Bea"
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+	g.SetInsights(true)
+
+	// "?" isn't an estimate, so there's nothing to compare Alex's vote with
+	g.AddCard(c1, 3, g.Deck().Name)
+	g.AddCard(c2, 10, g.Deck().Name)
+
+	h := g.History()
+	assert.Len(t, h.Rounds, 1)
+	assert.Equal(t, []*PlayerInsight{}, h.Insights)
+}
+
+func TestHistorySameName(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2, c3 := newClientTest(1), newClientTest(2), newClientTest(3)
+	c1.name, c2.name, c3.name = "Alex", "Alex", "Bea"
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+	g.RegisterClient(c3)
+	g.SetInsights(true)
+
+	// both players named Alex keep their own votes: 1, 5 and 2
+	g.AddCard(c1, 1, g.Deck().Name)
+	g.AddCard(c2, 4, g.Deck().Name)
+	g.AddCard(c3, 2, g.Deck().Name)
+
+	h := g.History()
+	if assert.Len(t, h.Rounds, 1) {
+		assert.Equal(t, []Vote{
+			{PlayerID: 1, Player: "Alex", Card: "1"},
+			{PlayerID: 2, Player: "Alex", Card: "5"},
+			{PlayerID: 3, Player: "Bea", Card: "2"},
+		}, h.Rounds[0].Votes)
+	}
+	assert.Equal(t, []*PlayerInsight{
+		{PlayerID: 1, Player: "Alex", Rounds: 1, FromMedian: -1, FromFinal: -1},
+		{PlayerID: 2, Player: "Alex", Rounds: 1, FromMedian: 2, FromFinal: 2},
+		{PlayerID: 3, Player: "Bea", Rounds: 1, FromMedian: 0, FromFinal: 0},
+	}, h.Insights)
+}
+
+func TestEstimate(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1, c2, c3 := newClientTest(1), newClientTest(2), newClientTest(3)
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+	g.RegisterClient(c3)
+
+	_, found := g.Estimate()
+	assert.False(t, found)
+
+	// 2 and 5 settle on 5, and "?" isn't counted
+	g.AddCard(c1, 2, g.Deck().Name)
+	g.AddCard(c2, 4, g.Deck().Name)
+	_, found = g.Estimate()
+	assert.False(t, found)
+
+	g.AddCard(c3, 10, g.Deck().Name)
+	e, found := g.Estimate()
+	assert.True(t, found)
+	assert.Equal(t, Estimate{Topic: "Test Estimation Session", Deck: "Modified Fibonacci", Card: "5"}, e)
+
+	g.AnnounceCommit(c1, "PLAT-1", e.Card)
+	m := c2.send[len(c2.send)-1].(*wsChat).Message
+	assert.Equal(t, messageKindCommit, m.Kind)
+	assert.Equal(t, "PLAT-1: 5", m.Text)
+
+	g.Revote()
+	g.Reveal()
+	_, found = g.Estimate()
+	assert.False(t, found)
+}
+
+func TestOnReveal(t *testing.T) {
+	g, _ := New("Test", "", nil)
+	c1 := newClientTest(1)
+	c1.name = "Alex"
+	g.RegisterClient(c1)
+
+	var rounds []HistoryRound
+	g.SetOnReveal(func(r HistoryRound) {
+		rounds = append(rounds, r)
+	})
+
+	g.AddCard(c1, 3, g.Deck().Name)
+	g.Reveal()
+	if assert.Len(t, rounds, 1) {
+		assert.Equal(t, []Vote{{PlayerID: 1, Player: "Alex", Card: "3"}}, rounds[0].Votes)
+	}
+}
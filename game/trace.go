@@ -0,0 +1,46 @@
+package game
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of this package
+const instrumentationName = "github.com/synacor/sibyl/game"
+
+// tracer returns the tracer of this package, from the tracer provider in use.
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// traceContext returns the context of the command being run by the event loop.
+func (g *Game) traceContext() context.Context {
+	if g.state.ctx == nil {
+		return context.Background()
+	}
+
+	return g.state.ctx
+}
+
+// startBroadcast traces sending something to every client.
+func (g *Game) startBroadcast() (context.Context, trace.Span) {
+	return tracer().Start(g.traceContext(), "game.broadcast", trace.WithAttributes(attribute.Int("sibyl.clients", len(g.state.clients))))
+}
+
+// send sends a message to a client, as part of the command being run.
+func (g *Game) send(c client, o interface{}) {
+	g.sendContext(g.traceContext(), c, o)
+}
+
+// sendContext sends a message to a client, as part of ctx.
+func (g *Game) sendContext(ctx context.Context, c client, o interface{}) {
+	if cc, ok := c.(contextClient); ok {
+		cc.SendContext(ctx, o)
+		return
+	}
+
+	c.Send(o)
+}
@@ -0,0 +1,74 @@
+package game
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans records the spans ended for the rest of a test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	return sr
+}
+
+// spanNamed returns the last span ended with the name.
+func spanNamed(sr *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	var span sdktrace.ReadOnlySpan
+	for _, s := range sr.Ended() {
+		if s.Name() == name {
+			span = s
+		}
+	}
+
+	return span
+}
+
+type contextClientTest struct {
+	*clientTest
+	contexts []context.Context
+}
+
+func (c *contextClientTest) SendContext(ctx context.Context, o interface{}) {
+	c.contexts = append(c.contexts, ctx)
+	c.Send(o)
+}
+
+func TestTrace(t *testing.T) {
+	sr := recordSpans(t)
+
+	g, _ := New("Test", "", nil)
+	c := &contextClientTest{clientTest: newClientTest(1)}
+	g.RegisterClient(c)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "action")
+	g.WithContext(ctx).Reveal()
+	parent.End()
+
+	reveal := spanNamed(sr, "game.Reveal")
+	if !assert.NotNil(t, reveal) {
+		return
+	}
+	assert.Equal(t, parent.SpanContext().SpanID(), reveal.Parent().SpanID())
+	assert.Equal(t, reveal.SpanContext().SpanID(), spanNamed(sr, "game.wait").Parent().SpanID())
+
+	broadcast := spanNamed(sr, "game.broadcast")
+	assert.Equal(t, reveal.SpanContext().SpanID(), broadcast.Parent().SpanID())
+
+	// the update is sent as part of the broadcast
+	assert.Len(t, c.send, 2)
+	assert.Equal(t, broadcast.SpanContext().SpanID(), trace.SpanContextFromContext(c.contexts[1]).SpanID())
+
+	// the game itself isn't part of the trace
+	g.Reset()
+	assert.False(t, spanNamed(sr, "game.Reset").Parent().IsValid())
+}
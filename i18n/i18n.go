@@ -0,0 +1,136 @@
+// Package i18n translates the messages shown to players.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale used when no other locale is wanted or supported.
+const Default = "en"
+
+//go:embed locales/*.json
+var files embed.FS
+
+// catalogs maps each supported locale to its messages
+var catalogs = make(map[string]map[string]string)
+
+func init() {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, e := range entries {
+		b, err := files.ReadFile(path.Join("locales", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(b, &messages); err != nil {
+			panic(fmt.Sprintf("could not parse %s: %v", e.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(e.Name(), ".json")] = messages
+	}
+}
+
+// Locale is a supported locale, and its name in its own language.
+type Locale struct {
+	Code string
+	Name string
+}
+
+// Locales returns the supported locales, sorted by code.
+func Locales() []Locale {
+	locales := make([]Locale, 0, len(catalogs))
+	for code := range catalogs {
+		locales = append(locales, Locale{code, T(code, "language.name")})
+	}
+	sort.Slice(locales, func(i, j int) bool { return locales[i].Code < locales[j].Code })
+
+	return locales
+}
+
+// Supported returns whether there are messages for locale.
+func Supported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// T returns the message for key in locale, formatted with args as by fmt.Sprintf. Messages missing
+// from locale are taken from the Default locale, and keys which aren't in any catalog are returned
+// as they are, so that literal messages can be given too.
+func T(locale, key string, args ...interface{}) string {
+	msg, ok := catalogs[locale][key]
+	if !ok {
+		if msg, ok = catalogs[Default][key]; !ok {
+			msg = key
+		}
+	}
+
+	if len(args) == 0 {
+		return msg
+	}
+
+	return fmt.Sprintf(msg, args...)
+}
+
+// Messages returns the messages in locale whose keys start with prefix, with the prefix removed.
+func Messages(locale, prefix string) map[string]string {
+	messages := make(map[string]string)
+	for _, catalog := range []map[string]string{catalogs[Default], catalogs[locale]} {
+		for key, msg := range catalog {
+			if strings.HasPrefix(key, prefix) {
+				messages[strings.TrimPrefix(key, prefix)] = msg
+			}
+		}
+	}
+
+	return messages
+}
+
+// Negotiate returns the supported locale which an Accept-Language header prefers, such as "de" for
+// "de-CH, de;q=0.9, en;q=0.8", or Default if it prefers none of them.
+func Negotiate(acceptLanguage string) string {
+	type tag struct {
+		locale string
+		q      float64
+	}
+
+	var tags []tag
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		t := tag{locale: strings.ToLower(strings.TrimSpace(fields[0])), q: 1}
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if q, err := strconv.ParseFloat(v, 64); err == nil {
+					t.q = q
+				}
+			}
+		}
+
+		if t.locale != "" && t.q > 0 {
+			tags = append(tags, t)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if Supported(t.locale) {
+			return t.locale
+		}
+
+		// a regional variant, such as de-CH, gets the language
+		if lang, _, found := strings.Cut(t.locale, "-"); found && Supported(lang) {
+			return lang
+		}
+	}
+
+	return Default
+}
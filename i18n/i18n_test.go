@@ -0,0 +1,54 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestT(t *testing.T) {
+	assert.Equal(t, "Reveal", T("en", "room.reveal"))
+	assert.Equal(t, "Aufdecken", T("de", "room.reveal"))
+	assert.Equal(t, "Reveal", T("xx", "room.reveal"))
+	assert.Equal(t, "Round 3", T("en", "js.round", "3"))
+	assert.Equal(t, "Runde 3", T("de", "js.round", "3"))
+
+	// literal messages are kept
+	assert.Equal(t, "Go away.", T("de", "Go away."))
+}
+
+func TestCatalogsMatch(t *testing.T) {
+	for _, l := range Locales() {
+		for key, msg := range catalogs[Default] {
+			translated, ok := catalogs[l.Code][key]
+			if assert.True(t, ok, "%s is missing %s", l.Code, key) {
+				assert.Equal(t, strings.Count(msg, "%"), strings.Count(translated, "%"), "%s has other arguments in %s", l.Code, key)
+			}
+		}
+
+		assert.Len(t, catalogs[l.Code], len(catalogs[Default]), "%s has messages which %s doesn't", l.Code, Default)
+	}
+}
+
+func TestLocales(t *testing.T) {
+	assert.Equal(t, []Locale{{"de", "Deutsch"}, {"en", "English"}}, Locales())
+	assert.True(t, Supported("de"))
+	assert.False(t, Supported("DE"))
+}
+
+func TestMessages(t *testing.T) {
+	messages := Messages("de", "js.")
+	assert.Equal(t, "Verbunden.", messages["connected"])
+	assert.NotContains(t, messages, "reveal")
+}
+
+func TestNegotiate(t *testing.T) {
+	assert.Equal(t, "en", Negotiate(""))
+	assert.Equal(t, "de", Negotiate("de"))
+	assert.Equal(t, "de", Negotiate("de-CH, de;q=0.9, en;q=0.8"))
+	assert.Equal(t, "de", Negotiate("fr-FR, fr;q=0.9, de;q=0.5"))
+	assert.Equal(t, "en", Negotiate("de;q=0.5, en"))
+	assert.Equal(t, "en", Negotiate("de;q=0, fr"))
+	assert.Equal(t, "en", Negotiate("*"))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/spf13/cobra"
+	"github.com/synacor/sibyl/client"
+	"github.com/synacor/sibyl/tui"
+	"golang.org/x/term"
+)
+
+// joinName is the name to join a room with
+var joinName string
+
+var joinCmd = &cobra.Command{
+	Use:   "join <room url>",
+	Short: "Join a room from the terminal",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return join(args[0])
+	},
+}
+
+func init() {
+	joinCmd.Flags().StringVar(&joinName, "name", "", "the name to join with")
+}
+
+// join runs `sibyl join <url>`, which joins a room from the terminal.
+func join(roomURL string) error {
+	u, err := url.Parse(roomURL)
+	if err != nil {
+		return err
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("sibyl join needs a terminal")
+	}
+
+	c, err := client.Connect(roomURL, &client.Options{Username: joinName})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	old, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), old)
+
+	if err := tui.Run(path.Base(u.Path), c, c.Updates(), os.Stdin, os.Stdout); err != nil {
+		return err
+	}
+
+	return c.Err()
+}
@@ -3,112 +3,90 @@ package main
 
 import (
 	"fmt"
+	"io"
+	stdlog "log"
 	"math"
-	"net/http"
 	"os"
-	"strings"
 
-	rice "github.com/GeertJohan/go.rice"
-	"github.com/gorilla/handlers"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"github.com/synacor/sibyl/server"
+	"google.golang.org/grpc/grpclog"
 )
 
 const defaultPort = 5000
 
 var maxPort = int(math.Pow(2, 16) - 1)
-var s *server.Server
 
-func main() {
-	// reminder, that viper will only look at the first config file it sees
-	viper.SetConfigName("config")
-	viper.SetConfigType("json")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("/etc/sibyl")
-	viper.SetEnvPrefix("sib")
-	viper.BindEnv("port")
-	viper.BindEnv("tls_port")
-	viper.BindEnv("log_level")
-	viper.SetDefault("log_level", "info")
-	viper.SetDefault("port", defaultPort)
-	if err := viper.ReadInConfig(); err != nil {
-		// viper requires a config file to be present for some reason. this will check for that error
-		// and silently ignore it
-		if _, isConfigFileNotFoundError := err.(viper.ConfigFileNotFoundError); !isConfigFileNotFoundError {
-			panic(err)
-		}
-	}
-	configureLogger()
+// configFile is the config file given with --config. Without it, config.json is looked for in the
+// working directory and then /etc/sibyl.
+var configFile string
 
-	tbox := rice.MustFindBox("templates")
-	sbox := rice.MustFindBox("static")
+var rootCmd = &cobra.Command{
+	Use:   "sibyl",
+	Short: "Sibyl is an online agile estimation tool",
+	Long: `Sibyl is an online agile estimation tool.
 
-	s = server.New(tbox, sbox)
-	mux := s.ServeMux()
+Run without a command, sibyl serves rooms, just like "sibyl serve".`,
+	SilenceUsage: true,
+	RunE:         runServe,
+}
 
-	done := make(chan bool, 1)
-	go serve(mux)
-	go s.ListenForEvents(done)
+func init() {
+	// every config key can be set with SIB_ and its name in upper case, such as SIB_PORT
+	viper.SetEnvPrefix("sib")
+	viper.AutomaticEnv()
+	viper.SetDefault("log_level", "info")
+	viper.SetDefault("log_format", "text")
+	viper.SetDefault("port", defaultPort)
 
-	<-done
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "the config file to use instead of ./config.json or /etc/sibyl/config.json")
+	rootCmd.AddCommand(serveCmd, versionCmd, configCmd, roomsCmd, joinCmd)
 }
 
-func configureLogger() {
-	levelStr := viper.GetString("log_level")
-	level, err := log.ParseLevel(levelStr)
-	if err != nil {
-		log.Fatalf("level %s does not exist", level)
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
 	}
-	log.SetLevel(level)
-	log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
 }
 
-func serve(mux *http.ServeMux) {
-	port := viper.GetInt("port")
-	tlsPort := viper.GetInt("tls_port")
-	forceTLS := viper.GetBool("force_tls")
-	tlsPrivateKeyFile := viper.GetString("tls_private_key")
-	tlsPublicKeyFile := viper.GetString("tls_public_key")
-
-	if port <= 0 || port > maxPort {
-		log.Fatalf("PORT must be 0 < PORT <= %d", maxPort)
-	} else if tlsPort > 0 && port == tlsPort {
-		log.Fatalf("PORT cannot equal TLS_PORT")
-	} else if tlsPort > maxPort {
-		log.Fatalf("TLS_PORT must be 0 < TLS_PORT <= %d", maxPort)
-	} else if tlsPort > 0 && (tlsPublicKeyFile == "" || tlsPrivateKeyFile == "") {
-		log.Fatal("must supply TLS_PRIVATE_KEY and TLS_PUBLIC_KEY if TLS_PORT specified")
+// loadConfig reads the config file at path. When path is empty, the first config.json found is read,
+// and having none at all is fine.
+func loadConfig(path string) error {
+	viper.SetConfigType("json")
+	if path != "" {
+		viper.SetConfigFile(path)
+	} else {
+		// reminder, that viper will only look at the first config file it sees
+		viper.SetConfigName("config")
+		viper.AddConfigPath(".")
+		viper.AddConfigPath("/etc/sibyl")
 	}
 
-	if tlsPort > 0 {
-		go func() {
-			pstr := fmt.Sprintf(":%d", tlsPort)
-			log.WithFields(log.Fields{"pid": os.Getpid()}).Printf("Listening on %s", pstr)
+	if err := viper.ReadInConfig(); err != nil {
+		if _, isConfigFileNotFoundError := err.(viper.ConfigFileNotFoundError); isConfigFileNotFoundError {
+			return nil
+		}
 
-			log.Fatal(http.ListenAndServeTLS(pstr, tlsPublicKeyFile, tlsPrivateKeyFile, handlers.CombinedLoggingHandler(os.Stdout, mux)))
-		}()
+		return fmt.Errorf("could not read config: %v", err)
 	}
 
-	pstr := fmt.Sprintf(":%d", port)
-	log.WithFields(log.Fields{"pid": os.Getpid()}).Printf("Listening on %s", pstr)
-	log.Fatal(http.ListenAndServe(pstr, handlers.CombinedLoggingHandler(os.Stdout, maybeRedirectToTLS(tlsPort, forceTLS, mux))))
+	return nil
 }
 
-// maybeRedirectToTLS is middleware for optionally redirecting the user to the TLS version based on arguments passed to the application.
-func maybeRedirectToTLS(tlsPort int, forceTLS bool, h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if forceTLS && tlsPort > 0 {
-			hostname := strings.Split(r.Host, ":")[0]
-			if tlsPort != 443 {
-				hostname += fmt.Sprintf(":%d", tlsPort)
-			}
-
-			url := "https://" + hostname + r.URL.String()
-			http.Redirect(w, r, url, http.StatusMovedPermanently)
-			return
-		}
+// configureLogger sets up logging from the config, which must be valid. Everything is logged through
+// logrus, including the logs of the standard library and gRPC, so that log_format applies to all of it.
+func configureLogger() {
+	level, _ := log.ParseLevel(viper.GetString("log_level"))
+	log.SetLevel(level)
+
+	if viper.GetString("log_format") == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	} else {
+		log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
+	}
 
-		h.ServeHTTP(w, r)
-	})
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(log.StandardLogger().WriterLevel(log.WarnLevel))
+	grpclog.SetLoggerV2(grpclog.NewLoggerV2(io.Discard, log.StandardLogger().WriterLevel(log.WarnLevel), log.StandardLogger().WriterLevel(log.ErrorLevel)))
 }
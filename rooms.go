@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/synacor/sibyl/server"
+)
+
+// adminTimeout is how long a request to the admin interface may take
+const adminTimeout = 10 * time.Second
+
+var (
+	// adminServer is the URL of the server to manage. It defaults to the port in the config.
+	adminServer string
+
+	// adminToken defaults to admin_token in the config.
+	adminToken string
+)
+
+var roomsCmd = &cobra.Command{
+	Use:   "rooms",
+	Short: "Manage the rooms of a running server",
+	Long: `Manage the rooms of a running server, through its admin interface. The server must have an
+admin_token, which is given with --token or read from the config.`,
+}
+
+var roomsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the open rooms",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := adminRequest(http.MethodGet, "/admin/rooms")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		var rooms []server.AdminRoom
+		if err := json.NewDecoder(resp.Body).Decode(&rooms); err != nil {
+			return fmt.Errorf("could not read the rooms: %v", err)
+		}
+
+		if len(rooms) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No open rooms")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ROOM\tPLAYERS\tROUND\tPINNED")
+		for _, r := range rooms {
+			pinned := "no"
+			if r.Pinned {
+				pinned = "yes"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", r.Room, r.Players, r.Round, pinned)
+		}
+
+		return w.Flush()
+	},
+}
+
+var roomsCloseCmd = &cobra.Command{
+	Use:   "close <room>",
+	Short: "Close a room, disconnecting its players",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := adminRequest(http.MethodDelete, "/admin/rooms/"+url.PathEscape(args[0]))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		fmt.Fprintf(cmd.OutOrStdout(), "Closed %s\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	roomsCmd.PersistentFlags().StringVar(&adminServer, "server", "", "the URL of the server (default http://localhost:<port>)")
+	roomsCmd.PersistentFlags().StringVar(&adminToken, "token", "", "the admin token of the server (default admin_token)")
+	roomsCmd.AddCommand(roomsListCmd, roomsCloseCmd)
+}
+
+// adminRequest makes a request to the admin interface. It returns an error unless the request succeeded.
+func adminRequest(method, path string) (*http.Response, error) {
+	if err := loadConfig(configFile); err != nil {
+		return nil, err
+	}
+
+	base := adminServer
+	if base == "" {
+		base = fmt.Sprintf("http://localhost:%d", viper.GetInt("port"))
+	}
+
+	token := adminToken
+	if token == "" {
+		token = viper.GetString("admin_token")
+	}
+	if token == "" {
+		return nil, errors.New("no admin token: use --token, or set admin_token")
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(base, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := (&http.Client{Timeout: adminTimeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized:
+			return nil, errors.New("the admin token was not accepted")
+		case resp.StatusCode == http.StatusNotFound && method == http.MethodDelete:
+			return nil, errors.New("the room does not exist")
+		case resp.StatusCode == http.StatusNotFound:
+			return nil, errors.New("the admin interface is off; set admin_token on the server")
+		}
+
+		return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,55 @@
+// Package roomstate holds the state of a room as it's sent to players, for the client and the text UI.
+package roomstate
+
+// Card is a card played by a player.
+type Card struct {
+	Card     int    `json:"card"`
+	PlayerID int    `json:"playerID"`
+	Player   string `json:"player"`
+	Outlier  bool   `json:"outlier"`
+}
+
+// Round holds the cards of a round which was revealed.
+type Round struct {
+	Round int    `json:"round"`
+	Cards []Card `json:"cards"`
+}
+
+// Message is a chat message or an emoji reaction from a player.
+type Message struct {
+	Kind     string `json:"kind"`
+	PlayerID int    `json:"playerID"`
+	Player   string `json:"player"`
+	Text     string `json:"text"`
+	Time     int64  `json:"time"`
+}
+
+// State is the state of a room, as sent to every player whenever it changes.
+type State struct {
+	Topic    string         `json:"topic"`
+	Players  map[int]string `json:"players"`
+	Cards    []Card         `json:"cards"`
+	Deck     string         `json:"deck"`
+	Revealed bool           `json:"reveal"`
+	Reset    bool           `json:"reset"`
+	Username string         `json:"username"`
+	Elapsed  int            `json:"elapsed"`
+	Round    int            `json:"round"`
+	Rounds   []Round        `json:"rounds"`
+	Seq      int            `json:"seq"`
+
+	// Insights is whether the room's history includes a summary of how each player votes
+	Insights bool `json:"insights"`
+
+	// Spectators are the IDs of players who may watch, but not vote
+	Spectators []int `json:"spectators"`
+
+	// Presence holds the players who are "idle" or "away". Players not listed are active.
+	Presence map[int]string `json:"presence"`
+
+	// Absent holds the names of the players on the roster of a team's room who aren't in it
+	Absent []string `json:"absent"`
+
+	// Messages holds the recent chat messages and reactions. It is only sent when joining the room.
+	Messages []Message `json:"messages"`
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/synacor/sibyl/game"
+	"github.com/synacor/sibyl/server"
+)
+
+var s *server.Server
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve rooms",
+	Long: `Serve rooms over HTTP, and optionally TLS, gRPC and SSH.
+
+Settings are taken from the flags, then the SIB_ environment variables, then the config file. Each
+flag is named after its config key, with dashes instead of underscores. admin_token has no flag, so
+that it isn't shown in the process list; set SIB_ADMIN_TOKEN or put it in the config file.`,
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	// set here, since runServe reads the flags of serveCmd through effectiveConfig
+	serveCmd.RunE = runServe
+
+	f := serveCmd.Flags()
+	f.Int("port", defaultPort, "the port to use for HTTP")
+	f.Int("tls-port", 0, "the port to use for HTTPS, which needs --tls-private-key and --tls-public-key")
+	f.Bool("force-tls", false, "redirect HTTP traffic to HTTPS")
+	f.String("tls-private-key", "", "path to the TLS private key file")
+	f.String("tls-public-key", "", "path to the TLS public key file")
+	f.Int("grpc-port", 0, "the port to serve the gRPC API on")
+	f.Int("ssh-port", 0, "the port to serve rooms over SSH on")
+	f.String("ssh-host-key", "", "path to the SSH host key, which is generated if it doesn't exist")
+	f.String("assets-dir", "", "a directory of templates and static files to serve instead of the built-in ones")
+	f.Bool("dev-mode", false, "parse the templates for every request, for working on them")
+	f.String("data-dir", "", "a directory to keep stories and their estimates in, which is created if it doesn't exist")
+	f.String("log-level", "info", "the level of logging, such as debug, info or warn")
+	f.String("log-format", "text", "the format of the logs, text or json")
+	f.Bool("debug", false, "log additional details")
+	f.Int("outlier-steps", 0, "how many cards from the median a vote must be to be highlighted, or 0 for the lowest and highest")
+	f.Duration("kick-ban", server.DefaultKickBan, "how long a player removed with \"ban\" can't rejoin")
+	f.Duration("idle-after", server.DefaultIdleAfter, "how long a player can do nothing before being shown as idle")
+	f.Bool("auto-reveal-skip-away", false, "reveal the cards once everyone who isn't away has voted")
+	f.Int("chat-rate-limit", server.DefaultChatRateLimit, "how many chat messages and reactions a player may send every 10 seconds")
+	f.Bool("delta-updates", false, "let players receive only what changed in each game update")
+	f.Duration("room-destroy-delay", game.DefaultDestroyDelay, "how long a room stays open after the last player leaves")
+	f.Duration("drain-timeout", 0, "on SIGTERM, how long to wait for rooms to finish before shutting down")
+	f.String("otlp-endpoint", "", "the host:port of an OTLP collector to send traces to over gRPC, which turns on tracing")
+	f.Bool("otlp-insecure", false, "send traces to the OTLP collector without TLS")
+
+	f.VisitAll(func(flag *pflag.Flag) {
+		viper.BindPFlag(configKey(flag), flag)
+	})
+
+	// sibyl on its own serves, so it takes the same flags
+	rootCmd.Flags().AddFlagSet(f)
+}
+
+// configKey returns the config key a flag of serve sets.
+func configKey(flag *pflag.Flag) string {
+	return strings.Replace(flag.Name, "-", "_", -1)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(configFile); err != nil {
+		return err
+	}
+	if err := validateConfig(); err != nil {
+		return err
+	}
+	configureLogger()
+
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not set up tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Errorf("could not export traces: %v", err)
+		}
+	}()
+
+	if s, err = server.New(assets); err != nil {
+		return err
+	}
+	watchConfig(s)
+	mux := s.ServeMux()
+
+	// every port is listened on before any is served, so once /readyz answers, all of them are up
+	lis := listen("HTTP", viper.GetInt("port"))
+	if port := viper.GetInt("tls_port"); port > 0 {
+		go serveTLS(listen("HTTPS", port), mux)
+	}
+	if port := viper.GetInt("grpc_port"); port > 0 {
+		go serveGRPC(listen("gRPC", port))
+	}
+	if port := viper.GetInt("ssh_port"); port > 0 {
+		go serveSSH(listen("ssh", port))
+	}
+
+	done := make(chan bool, 1)
+	go serve(lis, mux)
+	go s.ListenForEvents(done)
+
+	<-done
+	return nil
+}
+
+// listen listens on port for the named protocol.
+func listen(name string, port int) net.Listener {
+	pstr := fmt.Sprintf(":%d", port)
+	lis, err := net.Listen("tcp", pstr)
+	if err != nil {
+		log.Fatalf("could not listen on %s: %v", pstr, err)
+	}
+
+	log.WithFields(log.Fields{"pid": os.Getpid()}).Printf("Listening for %s on %s", name, pstr)
+	return lis
+}
+
+func serve(lis net.Listener, mux *http.ServeMux) {
+	h := maybeRedirectToTLS(viper.GetInt("tls_port"), viper.GetBool("force_tls"), mux)
+	log.Fatal(http.Serve(lis, traceRequests(mux, server.LogRequests(h))))
+}
+
+func serveTLS(lis net.Listener, mux *http.ServeMux) {
+	srv := &http.Server{Handler: traceRequests(mux, server.LogRequests(mux))}
+	log.Fatal(srv.ServeTLS(lis, viper.GetString("tls_public_key"), viper.GetString("tls_private_key")))
+}
+
+func serveGRPC(lis net.Listener) {
+	log.Fatal(s.GRPCServer().Serve(lis))
+}
+
+func serveSSH(lis net.Listener) {
+	srv, err := s.SSHServer()
+	if err != nil {
+		log.Fatalf("could not start ssh server: %v", err)
+	}
+
+	log.Fatal(srv.Serve(lis))
+}
+
+// maybeRedirectToTLS is middleware for optionally redirecting the user to the TLS version based on arguments passed to the application.
+func maybeRedirectToTLS(tlsPort int, forceTLS bool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if forceTLS && tlsPort > 0 {
+			hostname := strings.Split(r.Host, ":")[0]
+			if tlsPort != 443 {
+				hostname += fmt.Sprintf(":%d", tlsPort)
+			}
+
+			url := "https://" + hostname + r.URL.String()
+			http.Redirect(w, r, url, http.StatusMovedPermanently)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
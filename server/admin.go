@@ -0,0 +1,121 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// closedReason is given to the players of a room that was closed by an administrator, as a key of the
+// i18n catalog
+const closedReason = "error.room_closed"
+
+// AdminRoom describes a room, as listed by GET /admin/rooms.
+type AdminRoom struct {
+	Room    string `json:"room"`
+	Players int    `json:"players"`
+	Round   int    `json:"round"`
+
+	// Pinned rooms stay open when they're empty, see RoomConfig
+	Pinned bool `json:"pinned"`
+}
+
+// requireAdmin only lets requests through to h if they carry the admin token, as in
+// "Authorization: Bearer <token>". The admin interface is off unless admin_token is set.
+func (s *Server) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminToken := s.settings().adminToken
+		if adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			requestLog(r).Warn("admin request with a bad token")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// adminRoomsHandler handles requests to GET /admin/rooms
+func (s *Server) adminRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.safeGames.mutex.RLock()
+	rooms := make([]AdminRoom, 0, len(s.safeGames.games))
+	for _, g := range s.safeGames.games {
+		rooms = append(rooms, AdminRoom{
+			Room:    g.Room,
+			Players: g.RegisteredClientsCount(),
+			Round:   g.Round(),
+			Pinned:  g.Pinned(),
+		})
+	}
+	s.safeGames.mutex.RUnlock()
+
+	sort.Slice(rooms, func(i, j int) bool {
+		return s.roomKey(rooms[i].Room) < s.roomKey(rooms[j].Room)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rooms)
+}
+
+// adminRoomHandler handles requests to DELETE /admin/rooms/<room>, which closes the room. A pinned room
+// opens again right away, empty and with a new token.
+func (s *Server) adminRoomHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	room := strings.TrimPrefix(r.URL.Path, "/admin/rooms/")
+	key := s.roomKey(room)
+
+	s.safeGames.mutex.Lock()
+	g, found := s.safeGames.games[key]
+	delete(s.safeGames.games, key)
+	s.safeGames.mutex.Unlock()
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	requestLog(r).WithField("room", g.Room).Info("closing room")
+	g.Close(closedReason)
+	s.openPinnedRooms(s.settings())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminConfigHandler handles requests to GET /admin/config, which shows the effective config.
+func (s *Server) adminConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.safeSettings.mutex.RLock()
+	config := s.safeSettings.config
+	s.safeSettings.mutex.RUnlock()
+
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(config)
+}
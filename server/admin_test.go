@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/game"
+)
+
+func TestAdminDisabled(t *testing.T) {
+	s := newStreamServer()
+	w := httptest.NewRecorder()
+	s.ServeMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/rooms", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminRooms(t *testing.T) {
+	s := newStreamServer()
+	s.safeSettings.settings.adminToken = "secret"
+	g, _ := game.New("Test", "", nil)
+	s.safeGames.games["test"] = g
+	g2, _ := game.New("Another", "", nil)
+	s.safeGames.games["another"] = g2
+	conn := newWsConn()
+	conn.addr = &addr{"1.2.3.4"}
+	c := NewClient(g, conn, 1, "")
+	g.RegisterClient(c)
+	mux := s.ServeMux()
+
+	adminRequest := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(http.MethodGet, "/admin/rooms", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(http.MethodGet, "/admin/rooms", "wrong").Code)
+
+	w := adminRequest(http.MethodGet, "/admin/rooms", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var rooms []AdminRoom
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rooms))
+	assert.Equal(t, []AdminRoom{{"Another", 0, 1, false}, {"Test", 1, 1, false}}, rooms)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, adminRequest(http.MethodPost, "/admin/rooms", "secret").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, adminRequest(http.MethodGet, "/admin/rooms/Test", "secret").Code)
+	assert.Equal(t, http.StatusNotFound, adminRequest(http.MethodDelete, "/admin/rooms/Missing", "secret").Code)
+
+	// the players are told why they were disconnected
+	assert.Equal(t, http.StatusNoContent, adminRequest(http.MethodDelete, "/admin/rooms/test", "secret").Code)
+	assert.Nil(t, s.getGameByRoom("Test"))
+	assert.Equal(t, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "This room has been closed."), c.closeMessage())
+}
+
+func TestAdminConfig(t *testing.T) {
+	s := newStreamServer()
+	s.safeSettings.settings.adminToken = "secret"
+	mux := s.ServeMux()
+
+	get := func() map[string]interface{} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		mux.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var config map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &config))
+		return config
+	}
+
+	assert.Equal(t, map[string]interface{}{}, get())
+
+	s.SetEffectiveConfig(map[string]interface{}{"kick_ban": "5m0s", "admin_token": "********"})
+	assert.Equal(t, map[string]interface{}{"kick_ban": "5m0s", "admin_token": "********"}, get())
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+)
+
+// pages are the templates served, each of which is rendered within template.html
+var pages = []string{"index", "room"}
+
+// overlayFS opens files from upper, or from lower when upper doesn't have them. It lets operators
+// override some of the templates and static files with their own.
+type overlayFS struct {
+	upper fs.FS
+	lower fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.upper.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.lower.Open(name)
+	}
+
+	return f, err
+}
+
+// parseTemplates parses the template of each page in the templates directory of assets.
+func parseTemplates(assets fs.FS) (map[string]*template.Template, error) {
+	layout, err := fs.ReadFile(assets, "templates/template.html")
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := template.New("").Funcs(templateFuncs).Parse(string(layout))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make(map[string]*template.Template)
+	for _, page := range pages {
+		content, err := fs.ReadFile(assets, "templates/"+page+".html")
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		if templates[page], err = t.Parse(string(content)); err != nil {
+			return nil, err
+		}
+	}
+
+	return templates, nil
+}
+
+// template returns the template of a page. In dev mode, the templates are parsed again every time, so
+// that changes to them show up right away.
+func (s *Server) template(page string) (*template.Template, error) {
+	if !s.settings().devMode {
+		return s.templates[page], nil
+	}
+
+	templates, err := parseTemplates(s.assets)
+	if err != nil {
+		return nil, err
+	}
+
+	return templates[page], nil
+}
+
+// render renders the template of a page.
+func (s *Server) render(w http.ResponseWriter, r *http.Request, page string, values interface{}) {
+	t, err := s.template(page)
+	if err != nil {
+		requestLog(r).Errorf("could not parse templates: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := t.Execute(w, values); err != nil {
+		requestLog(r).Errorf("could not render %s: %v", page, err)
+	}
+}
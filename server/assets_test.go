@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// testAssets are templates and static files which render just enough to tell them apart.
+func testAssets() fstest.MapFS {
+	return fstest.MapFS{
+		"templates/template.html": {Data: []byte(`<title>Sibyl</title>{{template "body" .}}`)},
+		"templates/index.html":    {Data: []byte(`{{define "body"}}index{{end}}`)},
+		"templates/room.html":     {Data: []byte(`{{define "body"}}room {{.Room}}{{end}}`)},
+		"static/favicon.ico":      {Data: []byte("icon")},
+		"static/app.css":          {Data: []byte("body {}")},
+	}
+}
+
+// get returns the body of a response from mux.
+func get(mux http.Handler, path string) (int, string) {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.Code, w.Body.String()
+}
+
+func TestNew(t *testing.T) {
+	s, err := New(testAssets())
+	if !assert.NoError(t, err) {
+		return
+	}
+	mux := s.ServeMux()
+
+	assert.Empty(t, s.readinessErrors())
+	_, body := get(mux, "/")
+	assert.Equal(t, "<title>Sibyl</title>index", body)
+	_, body = get(mux, "/favicon.ico")
+	assert.Equal(t, "icon", body)
+	_, body = get(mux, "/static/app.css")
+	assert.Equal(t, "body {}", body)
+
+	broken := testAssets()
+	broken["templates/index.html"] = &fstest.MapFile{Data: []byte(`{{define "body"}}`)}
+	_, err = New(broken)
+	assert.Error(t, err)
+}
+
+func TestAssetsDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "templates"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "static"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "templates", "index.html"), []byte(`{{define "body"}}branded{{end}}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "static", "favicon.ico"), []byte("branded icon"), 0644))
+
+	viper.Set("assets_dir", dir)
+	defer viper.Set("assets_dir", nil)
+
+	s, err := New(testAssets())
+	if !assert.NoError(t, err) {
+		return
+	}
+	mux := s.ServeMux()
+
+	// files in assets_dir are served instead, and the rest are still served
+	_, body := get(mux, "/")
+	assert.Equal(t, "<title>Sibyl</title>branded", body)
+	_, body = get(mux, "/favicon.ico")
+	assert.Equal(t, "branded icon", body)
+	_, body = get(mux, "/static/app.css")
+	assert.Equal(t, "body {}", body)
+
+	// templates are only parsed again in dev mode
+	index := filepath.Join(dir, "templates", "index.html")
+	assert.NoError(t, os.WriteFile(index, []byte(`{{define "body"}}rebranded{{end}}`), 0644))
+	_, body = get(mux, "/")
+	assert.Equal(t, "<title>Sibyl</title>branded", body)
+
+	s.safeSettings.settings.devMode = true
+	_, body = get(mux, "/")
+	assert.Equal(t, "<title>Sibyl</title>rebranded", body)
+
+	// a broken template fails the page and readiness, rather than the server
+	assert.NoError(t, os.WriteFile(index, []byte(`{{define "body"}}`), 0644))
+	code, _ := get(mux, "/")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Len(t, s.readinessErrors(), 1)
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/spf13/viper"
+)
+
+// Branding is how Sibyl presents itself, from the branding section of the config.
+type Branding struct {
+	// ProductName replaces "Sibyl" in the pages
+	ProductName string `mapstructure:"product_name" json:"product_name"`
+
+	LogoURL    string `mapstructure:"logo_url" json:"logo_url"`
+	FaviconURL string `mapstructure:"favicon_url" json:"favicon_url"`
+
+	// CSSURL is a stylesheet loaded after Sibyl's own, so that it can override any of it
+	CSSURL string `mapstructure:"css_url" json:"css_url"`
+
+	HeaderColor string `mapstructure:"header_color" json:"header_color"`
+	LinkColor   string `mapstructure:"link_color" json:"link_color"`
+	AccentColor string `mapstructure:"accent_color" json:"accent_color"`
+
+	// FooterLinks replace the "Built by" link in the footer, when there are any
+	FooterLinks []BrandingLink `mapstructure:"footer_links" json:"footer_links"`
+}
+
+// BrandingLink is a link in the footer.
+type BrandingLink struct {
+	Text string `mapstructure:"text" json:"text"`
+	URL  string `mapstructure:"url" json:"url"`
+}
+
+// DefaultBranding is how Sibyl looks out of the box. Colors which are empty keep those of the stylesheet.
+var DefaultBranding = Branding{
+	ProductName: "Sibyl",
+	LogoURL:     "/static/images/logo.png",
+	FaviconURL:  "/favicon.ico",
+}
+
+// colorRx matches the colors which can be given for branding, such as "#09c" or "teal"
+var colorRx = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+// ConfigBranding returns the branding in the config. Anything it doesn't set is taken from DefaultBranding.
+func ConfigBranding() (Branding, error) {
+	b := DefaultBranding
+	if err := viper.UnmarshalKey("branding", &b); err != nil {
+		return DefaultBranding, err
+	}
+
+	colors := []struct{ key, color string }{
+		{"header_color", b.HeaderColor},
+		{"link_color", b.LinkColor},
+		{"accent_color", b.AccentColor},
+	}
+	for _, c := range colors {
+		if c.color != "" && !colorRx.MatchString(c.color) {
+			return DefaultBranding, fmt.Errorf("%s must be a color such as #09c or teal", c.key)
+		}
+	}
+
+	for _, link := range b.FooterLinks {
+		if link.Text == "" || link.URL == "" {
+			return DefaultBranding, errors.New("footer_links need a text and a url")
+		}
+	}
+
+	return b, nil
+}
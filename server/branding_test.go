@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigBranding(t *testing.T) {
+	b, err := ConfigBranding()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultBranding, b)
+
+	defer viper.Set("branding", nil)
+
+	// what isn't set keeps its default
+	viper.Set("branding", map[string]interface{}{
+		"product_name": "Acme Estimates",
+		"link_color":   "#c10",
+		"footer_links": []interface{}{map[string]interface{}{"text": "Help", "url": "https://help.example.com"}},
+	})
+	b, err = ConfigBranding()
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme Estimates", b.ProductName)
+	assert.Equal(t, "#c10", b.LinkColor)
+	assert.Equal(t, DefaultBranding.LogoURL, b.LogoURL)
+	assert.Equal(t, []BrandingLink{{"Help", "https://help.example.com"}}, b.FooterLinks)
+
+	viper.Set("branding", map[string]interface{}{"accent_color": "red; background: url(x)"})
+	_, err = ConfigBranding()
+	assert.EqualError(t, err, "accent_color must be a color such as #09c or teal")
+
+	viper.Set("branding", map[string]interface{}{"footer_links": []interface{}{map[string]interface{}{"text": "Help"}}})
+	_, err = ConfigBranding()
+	assert.Error(t, err)
+}
+
+func TestBrandingTemplates(t *testing.T) {
+	s, err := New(os.DirFS(".."))
+	if !assert.NoError(t, err) {
+		return
+	}
+	mux := s.ServeMux()
+
+	code, body := get(mux, "/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "<title>Sibyl - Rapid Agile Estimations</title>")
+	assert.Contains(t, body, `<link rel="icon" href="/favicon.ico">`)
+	assert.Contains(t, body, `class="built-by"`)
+	assert.NotContains(t, body, "<style>")
+
+	s.safeSettings.settings.branding = Branding{
+		ProductName: "Acme Estimates",
+		LogoURL:     "https://cdn.example.com/logo.png",
+		FaviconURL:  "https://cdn.example.com/favicon.ico",
+		CSSURL:      "https://cdn.example.com/acme.css",
+		HeaderColor: "#003366",
+		FooterLinks: []BrandingLink{{"Help", "https://help.example.com"}, {"Privacy", "https://example.com/privacy"}},
+	}
+
+	_, body = get(mux, "/")
+	assert.Contains(t, body, "<title>Acme Estimates - Rapid Agile Estimations</title>")
+	assert.Contains(t, body, `<strong>Acme Estimates</strong>`)
+	assert.Contains(t, body, `<img src="https://cdn.example.com/logo.png"`)
+	assert.Contains(t, body, `<link rel="icon" href="https://cdn.example.com/favicon.ico">`)
+	assert.Contains(t, body, `<link rel="stylesheet" href="https://cdn.example.com/acme.css">`)
+	assert.Contains(t, body, "--header-color: #003366;")
+	assert.NotContains(t, body, "--link-color")
+	assert.Contains(t, body, `<a href="https://help.example.com">Help</a><a href="https://example.com/privacy">Privacy</a>`)
+	assert.NotContains(t, body, `class="built-by"`)
+}
@@ -1,16 +1,23 @@
 package server
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
 	"net"
 	"regexp"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 	log "github.com/sirupsen/logrus"
 	"github.com/synacor/sibyl/game"
+	"github.com/synacor/sibyl/i18n"
 	"github.com/synacor/sibyl/name"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -24,6 +31,25 @@ const (
 
 	// Send a ping out every 27 seconds. Must be less than pongWait. If pong doesn't happen with pongWait - pingPeriod, the connection will timeout
 	pingPeriod = (pongWait * 9) / 10
+
+	// The reason in a close message must fit in a control frame, which is at most 125 bytes
+	maxCloseReasonLength = 123
+
+	// DefaultIdleAfter is how long a player can go without doing anything before they are idle
+	DefaultIdleAfter = 2 * time.Minute
+
+	// DefaultChatRateLimit is how many chat messages and reactions a player may send per chatRateWindow
+	DefaultChatRateLimit = 5
+	chatRateWindow       = 10 * time.Second
+
+	// The number of messages that may be waiting to be written to a client
+	sendBufferSize = 256
+
+	// A client whose send buffer stays full for this long is disconnected
+	evictAfter = 5 * time.Second
+
+	// The reason given to a client which was disconnected for not keeping up, as a key of the i18n catalog
+	evictReason = "error.too_slow"
 )
 
 // ErrInvalidUsername is an error when the username does not match criteria
@@ -54,12 +80,87 @@ type safeIdentifier struct {
 	mu   sync.RWMutex
 }
 
+type safeCloseReason struct {
+	reason string
+	mu     sync.RWMutex
+}
+
+type safeRateLimit struct {
+	windowStart time.Time
+	count       int
+	mu          sync.Mutex
+}
+
+// clientLimits are the limits on players. A server shares its limits with all of its clients, so that
+// changing them applies to players who are already connected.
+type clientLimits struct {
+	idleAfter     time.Duration
+	chatRateLimit int
+	mu            sync.RWMutex
+}
+
+// newClientLimits returns limits on players. An idleAfter of 0 means DefaultIdleAfter.
+func newClientLimits(idleAfter time.Duration, chatRateLimit int) *clientLimits {
+	l := &clientLimits{}
+	l.set(idleAfter, chatRateLimit)
+	return l
+}
+
+func (l *clientLimits) set(idleAfter time.Duration, chatRateLimit int) {
+	if idleAfter <= 0 {
+		idleAfter = DefaultIdleAfter
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.idleAfter = idleAfter
+	l.chatRateLimit = chatRateLimit
+}
+
+func (l *clientLimits) get() (time.Duration, int) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.idleAfter, l.chatRateLimit
+}
+
+// safeQueue holds the messages waiting to be written to a client.
+type safeQueue struct {
+	messages  []interface{}
+	closed    bool
+	evicted   bool
+	fullSince time.Time
+	mu        sync.Mutex
+}
+
+type safeActivity struct {
+	lastActive time.Time
+	hidden     bool
+	mu         sync.RWMutex
+}
+
 // Client represents a user connected via websocket
 type Client struct {
-	Game           *game.Game
-	send           chan interface{}
-	Conn           WsConn
-	safeIdentifier safeIdentifier
+	Game            *game.Game
+	Conn            WsConn
+	safeQueue       safeQueue
+	wake            chan struct{}
+	safeIdentifier  safeIdentifier
+	safeCloseReason safeCloseReason
+	safeActivity    safeActivity
+	safeRateLimit   safeRateLimit
+	session         string
+	limits          *clientLimits
+	deltas          bool
+	spectator       bool
+
+	// locale is the locale of the player, which messages to them are translated to
+	locale string
+
+	// connID identifies the connection in the logs. For a web socket, it's the ID of the request.
+	connID string
+
+	// connSpan is the span of the request which opened the connection, which actions are linked to
+	connSpan trace.SpanContext
 }
 
 // NewClient instantiates a new client object.
@@ -70,15 +171,47 @@ func NewClient(game *game.Game, conn WsConn, id int, uname string) *Client {
 
 	return &Client{
 		Game: game,
-		send: make(chan interface{}, 256),
 		Conn: conn,
+		safeQueue: safeQueue{
+			messages: make([]interface{}, 0),
+		},
+		wake: make(chan struct{}, 1),
 		safeIdentifier: safeIdentifier{
 			id:   id,
 			name: uname,
 		},
+		safeActivity: safeActivity{
+			lastActive: time.Now(),
+		},
+		limits: newClientLimits(DefaultIdleAfter, DefaultChatRateLimit),
+		connID: newLogID(),
 	}
 }
 
+// Locale returns the locale of the player.
+func (c *Client) Locale() string {
+	if c.locale == "" {
+		return i18n.Default
+	}
+
+	return c.locale
+}
+
+// ConnID returns the ID of the client's connection, which is added to its log lines.
+func (c *Client) ConnID() string {
+	return c.connID
+}
+
+// logger returns a log entry for the client, so that everything that happens to it can be followed.
+func (c *Client) logger() *log.Entry {
+	return log.WithFields(log.Fields{
+		"room":      c.Game.Room,
+		"client_id": c.ID(),
+		"conn_id":   c.connID,
+		"client":    c.RemoteAddr(),
+	})
+}
+
 // SetName sets the name of the player
 func (c *Client) SetName(n string) error {
 	if !validUsernameRx.MatchString(n) || !withLetterRx.MatchString(n) {
@@ -105,15 +238,223 @@ func (c *Client) Name() string {
 	return c.safeIdentifier.name
 }
 
-// Send will send an object to the client.
+// Touch records that the player has just done something meaningful.
+func (c *Client) Touch() {
+	c.safeActivity.mu.Lock()
+	defer c.safeActivity.mu.Unlock()
+	c.safeActivity.lastActive = time.Now()
+}
+
+// SetHidden records whether the browser reported that the page is hidden, such as in a background tab.
+func (c *Client) SetHidden(hidden bool) {
+	c.safeActivity.mu.Lock()
+	defer c.safeActivity.mu.Unlock()
+	c.safeActivity.hidden = hidden
+	if !hidden {
+		c.safeActivity.lastActive = time.Now()
+	}
+}
+
+// Presence returns whether the player is active, idle or away. Players whose page is hidden are away,
+// and players who haven't done anything for a while are idle.
+func (c *Client) Presence() game.Presence {
+	c.safeActivity.mu.RLock()
+	defer c.safeActivity.mu.RUnlock()
+
+	if c.safeActivity.hidden {
+		return game.PresenceAway
+	} else if idleAfter, _ := c.limits.get(); time.Since(c.safeActivity.lastActive) > idleAfter {
+		return game.PresenceIdle
+	}
+
+	return game.PresenceActive
+}
+
+// AllowMessage returns true if the player may send another chat message or reaction, and counts it.
+func (c *Client) AllowMessage() bool {
+	c.safeRateLimit.mu.Lock()
+	defer c.safeRateLimit.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.safeRateLimit.windowStart) >= chatRateWindow {
+		c.safeRateLimit.windowStart = now
+		c.safeRateLimit.count = 0
+	}
+
+	if _, limit := c.limits.get(); c.safeRateLimit.count >= limit {
+		return false
+	}
+
+	c.safeRateLimit.count++
+	return true
+}
+
+// Session returns the browser session the client connected from, if known.
+func (c *Client) Session() string {
+	return c.session
+}
+
+// WantsDeltas returns whether the client asked for state diffs instead of full game updates.
+func (c *Client) WantsDeltas() bool {
+	return c.deltas
+}
+
+// Spectator returns whether the client asked to join as a spectator.
+func (c *Client) Spectator() bool {
+	return c.spectator
+}
+
+// SetCloseReason sets the reason given to the remote end when the connection is closed. A reason too
+// long for a close message is cut short between characters, since browsers drop a connection whose
+// close reason isn't valid UTF-8.
+func (c *Client) SetCloseReason(reason string) {
+	if len(reason) > maxCloseReasonLength {
+		n := maxCloseReasonLength
+		for n > 0 && !utf8.RuneStart(reason[n]) {
+			n--
+		}
+		reason = reason[:n]
+	}
+
+	c.safeCloseReason.mu.Lock()
+	defer c.safeCloseReason.mu.Unlock()
+	c.safeCloseReason.reason = reason
+}
+
+// closeMessage returns the payload of the close message sent when the send channel is closed.
+func (c *Client) closeMessage() []byte {
+	c.safeCloseReason.mu.RLock()
+	defer c.safeCloseReason.mu.RUnlock()
+
+	if c.safeCloseReason.reason == "" {
+		return []byte{}
+	}
+
+	return websocket.FormatCloseMessage(websocket.ClosePolicyViolation, c.safeCloseReason.reason)
+}
+
+// closeMessageReason returns the reason held by a close message, unless the close was a normal one.
+func closeMessageReason(data []byte) string {
+	if len(data) < 2 || binary.BigEndian.Uint16(data) == websocket.CloseNormalClosure {
+		return ""
+	}
+
+	return string(data[2:])
+}
+
+// Send queues an object to be written to the client. It never blocks: a full game state update
+// replaces any older one which hasn't been written yet, and if the client stops keeping up, messages
+// are dropped and the client is eventually disconnected.
 func (c *Client) Send(o interface{}) {
-	log.Println(o)
-	c.send <- o
+	c.enqueue(o, nil)
+}
+
+// SendContext is Send for a message sent as part of a trace, so that writing it is traced too.
+func (c *Client) SendContext(ctx context.Context, o interface{}) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		c.enqueue(o, nil)
+		return
+	}
+
+	c.enqueue(o, ctx)
+}
+
+// tracedMessage is a message waiting to be written, along with the trace it belongs to.
+type tracedMessage struct {
+	ctx context.Context
+	msg interface{}
+}
+
+// untraced returns a message waiting to be written, without the trace it belongs to.
+func untraced(m interface{}) interface{} {
+	if tm, ok := m.(*tracedMessage); ok {
+		return tm.msg
+	}
+
+	return m
+}
+
+func (c *Client) enqueue(o interface{}, ctx context.Context) {
+	c.safeQueue.mu.Lock()
+	if c.safeQueue.closed {
+		c.safeQueue.mu.Unlock()
+		return
+	}
+
+	messages := c.safeQueue.messages[:0]
+	for _, m := range c.safeQueue.messages {
+		if game.Supersedes(o, untraced(m)) {
+			metricUpdatesCoalesced.Add(1)
+			continue
+		}
+		messages = append(messages, m)
+	}
+	c.safeQueue.messages = messages
+
+	evict := false
+	if len(c.safeQueue.messages) >= sendBufferSize {
+		metricMessagesDropped.Add(1)
+		if c.safeQueue.fullSince.IsZero() {
+			c.safeQueue.fullSince = time.Now()
+		} else if !c.safeQueue.evicted && time.Since(c.safeQueue.fullSince) > evictAfter {
+			c.safeQueue.evicted = true
+			evict = true
+		}
+	} else if ctx != nil {
+		c.safeQueue.messages = append(c.safeQueue.messages, &tracedMessage{ctx, o})
+		c.safeQueue.fullSince = time.Time{}
+	} else {
+		c.safeQueue.messages = append(c.safeQueue.messages, o)
+		c.safeQueue.fullSince = time.Time{}
+	}
+	c.safeQueue.mu.Unlock()
+
+	if evict {
+		c.evict()
+	}
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// evict disconnects a client that isn't keeping up with its messages. The messages it hasn't been sent
+// are dropped, so that WritePump sends the close message with the reason right away, and then closes
+// the connection. That stops ReadPump, which unregisters the client from the game.
+func (c *Client) evict() {
+	metricClientsEvicted.Add(1)
+	c.logger().Warnf("evicting client, its send buffer has been full for over %s", evictAfter)
+
+	c.SetCloseReason(i18n.T(c.Locale(), evictReason))
+
+	c.safeQueue.mu.Lock()
+	c.safeQueue.messages = make([]interface{}, 0)
+	c.safeQueue.closed = true
+	c.safeQueue.mu.Unlock()
 }
 
-// CloseChannel will close the send channel
+// CloseChannel stops the client from accepting any more messages. Messages that were already queued
+// are written before the connection is closed. It is safe to call more than once.
 func (c *Client) CloseChannel() {
-	close(c.send)
+	c.safeQueue.mu.Lock()
+	c.safeQueue.closed = true
+	c.safeQueue.mu.Unlock()
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dequeue returns the messages waiting to be written, and whether the client has been closed.
+func (c *Client) dequeue() ([]interface{}, bool) {
+	c.safeQueue.mu.Lock()
+	defer c.safeQueue.mu.Unlock()
+
+	messages := c.safeQueue.messages
+	c.safeQueue.messages = make([]interface{}, 0)
+	return messages, c.safeQueue.closed
 }
 
 // RemoteAddr returns the remote address (IP + port) of the client
@@ -133,15 +474,18 @@ func (c *Client) WritePump(s *Server) {
 
 	for {
 		select {
-		case msg, ok := <-c.send:
-			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+		case <-c.wake:
+			messages, closed := c.dequeue()
+			for _, msg := range messages {
+				if err := c.write(msg); err != nil {
+					c.logger().Errorf("could not write JSON: %v", err)
+					return
+				}
 			}
 
-			if err := c.Conn.WriteJSON(msg); err != nil {
-				log.WithFields(log.Fields{"client": c.Conn.RemoteAddr().String()}).Errorf("could not write JSON: %v", err)
+			if closed {
+				c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+				c.Conn.WriteMessage(websocket.CloseMessage, c.closeMessage())
 				return
 			}
 		case <-ticker.C:
@@ -153,6 +497,31 @@ func (c *Client) WritePump(s *Server) {
 	}
 }
 
+// write writes a message to the client. Writing a message which is part of a trace is traced, which
+// shows how long slow clients take.
+func (c *Client) write(msg interface{}) error {
+	tm, traced := msg.(*tracedMessage)
+	if !traced {
+		c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return c.Conn.WriteJSON(msg)
+	}
+
+	_, span := tracer().Start(tm.ctx, "client.write", trace.WithAttributes(
+		attribute.String("sibyl.room", c.Game.Room),
+		attribute.Int("sibyl.client_id", c.ID()),
+	))
+	defer span.End()
+
+	c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+	err := c.Conn.WriteJSON(tm.msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "could not write")
+	}
+
+	return err
+}
+
 // ReadPump reads messages sent from the client.
 func (c *Client) ReadPump(s *Server) {
 	defer func() {
@@ -170,7 +539,7 @@ func (c *Client) ReadPump(s *Server) {
 		var r WsRequest
 		if err := c.Conn.ReadJSON(&r); err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-				log.WithFields(log.Fields{"client": c.Conn.RemoteAddr().String()}).Errorf("could not read JSON: %v", err)
+				c.logger().Errorf("could not read JSON: %v", err)
 			}
 			break
 		}
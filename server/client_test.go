@@ -5,25 +5,72 @@ import (
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/synacor/sibyl/game"
 )
 
-var panicError string
-
 func TestSendAndClose(t *testing.T) {
 	g, _ := game.New("Test", "", nil)
 	c := NewClient(g, nil, 1, "")
 	c.Send("Test")
-	a := <-c.send
-	assert.Equal(t, "Test", a.(string))
+	messages, closed := c.dequeue()
+	assert.Equal(t, []interface{}{"Test"}, messages)
+	assert.False(t, closed)
 
 	c.CloseChannel()
-	capturePanic(func() {
-		c.send <- true
-	})
-	assert.Equal(t, "send on closed channel", panicError)
+	c.CloseChannel()
+	c.Send(true)
+	messages, closed = c.dequeue()
+	assert.Equal(t, []interface{}{}, messages)
+	assert.True(t, closed)
+}
+
+func TestSendCoalesces(t *testing.T) {
+	g, _ := game.New("Test", "", nil)
+	c := NewClient(g, nil, 1, "")
+
+	c.Send(&stateTest{"First", true})
+	c.Send("Chat")
+	c.Send(&stateTest{"Second", true})
+	c.Send(&stateTest{"Welcome", false})
+	c.Send(&stateTest{"Third", true})
+
+	messages, _ := c.dequeue()
+	assert.Equal(t, []interface{}{"Chat", &stateTest{"Welcome", false}, &stateTest{"Third", true}}, messages)
+}
+
+func TestSendEvictsSlowClient(t *testing.T) {
+	g, _ := game.New("Test", "", nil)
+	conn := newWsConn()
+	conn.addr = &addr{"1.2.3.4"}
+	c := NewClient(g, conn, 1, "")
+
+	for i := 0; i < sendBufferSize; i++ {
+		c.Send(i)
+	}
+	assert.Equal(t, sendBufferSize, len(c.safeQueue.messages))
+
+	// the buffer is full, so this is dropped
+	c.Send("Dropped")
+	assert.Equal(t, sendBufferSize, len(c.safeQueue.messages))
+	assert.Equal(t, 0, conn.closeInvoked)
+
+	c.safeQueue.fullSince = time.Now().Add(-evictAfter - time.Second)
+	c.Send("Dropped")
+	assert.Equal(t, "Your connection is too slow.", c.safeCloseReason.reason)
+
+	c.Send("Dropped")
+	assert.Equal(t, 0, len(c.safeQueue.messages))
+
+	// the client is told why before the connection is closed
+	c.WritePump(nil)
+	assert.Nil(t, conn.writeJSON)
+	assert.Equal(t, websocket.CloseMessage, conn.writeMessageType)
+	assert.Equal(t, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Your connection is too slow."), conn.writeMessageData)
+	assert.Equal(t, 1, conn.closeInvoked)
 }
 
 func TestID(t *testing.T) {
@@ -76,7 +123,7 @@ func TestWritePump(t *testing.T) {
 	c := NewClient(g, conn, 1, "")
 
 	go func() {
-		c.send <- "Test"
+		c.Send("Test")
 		c.CloseChannel()
 	}()
 
@@ -88,6 +135,64 @@ func TestWritePump(t *testing.T) {
 	assert.Equal(t, "Test", conn.writeJSON.(string))
 }
 
+func TestCloseReason(t *testing.T) {
+	g, _ := game.New("Test", "", nil)
+	conn := newWsConn()
+	c := NewClient(g, conn, 1, "")
+	c.SetCloseReason("Removed")
+	c.CloseChannel()
+
+	c.WritePump(nil)
+
+	assert.Equal(t, websocket.CloseMessage, conn.writeMessageType)
+	assert.Equal(t, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Removed"), conn.writeMessageData)
+
+	c.SetCloseReason(strings.Repeat("a", 200))
+	assert.Equal(t, maxCloseReasonLength+2, len(c.closeMessage()))
+
+	// a character which doesn't fit is left out whole
+	c.SetCloseReason(strings.Repeat("a", maxCloseReasonLength-1) + "ü")
+	assert.Equal(t, strings.Repeat("a", maxCloseReasonLength-1), c.safeCloseReason.reason)
+	c.SetCloseReason(strings.Repeat("ü", 100))
+	assert.True(t, utf8.ValidString(c.safeCloseReason.reason))
+	assert.Equal(t, maxCloseReasonLength-1, len(c.safeCloseReason.reason))
+}
+
+func TestPresence(t *testing.T) {
+	c := NewClient(&game.Game{}, newWsConn(), 5, "")
+	assert.Equal(t, game.PresenceActive, c.Presence())
+
+	c.SetHidden(true)
+	assert.Equal(t, game.PresenceAway, c.Presence())
+
+	c.SetHidden(false)
+	c.limits.set(time.Millisecond, DefaultChatRateLimit)
+	time.Sleep(2 * time.Millisecond)
+	assert.Equal(t, game.PresenceIdle, c.Presence())
+
+	c.Touch()
+	c.limits.set(time.Minute, DefaultChatRateLimit)
+	assert.Equal(t, game.PresenceActive, c.Presence())
+}
+
+func TestAllowMessage(t *testing.T) {
+	c := NewClient(&game.Game{}, newWsConn(), 5, "")
+	c.limits.set(DefaultIdleAfter, 2)
+	assert.True(t, c.AllowMessage())
+	assert.True(t, c.AllowMessage())
+	assert.False(t, c.AllowMessage())
+
+	c.safeRateLimit.windowStart = time.Now().Add(-chatRateWindow)
+	assert.True(t, c.AllowMessage())
+}
+
+type stateTest struct {
+	Name     string
+	coalesce bool
+}
+
+func (s *stateTest) Coalesce() bool { return s.coalesce }
+
 type wsConn struct {
 	addr             *addr
 	closeInvoked     int
@@ -129,15 +234,3 @@ func (c *wsConn) WriteMessage(messageType int, data []byte) error {
 	c.writeMessageData = data
 	return nil
 }
-
-func capturePanic(fn func()) {
-	panicError = ""
-
-	defer func() {
-		if r := recover(); r != nil {
-			panicError = r.(error).Error()
-		}
-	}()
-
-	fn()
-}
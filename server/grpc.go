@@ -0,0 +1,275 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+	"github.com/synacor/sibyl/game"
+	"github.com/synacor/sibyl/sibylpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// grpcActions maps the actions of the gRPC service to the actions sent over a web socket
+var grpcActions = map[sibylpb.Action]WsRequestAction{
+	sibylpb.Action_ACTION_SELECT:     WsRequestActionSelectCard,
+	sibylpb.Action_ACTION_REVEAL:     WsRequestActionReveal,
+	sibylpb.Action_ACTION_RESET:      WsRequestActionReset,
+	sibylpb.Action_ACTION_REVOTE:     WsRequestActionRevote,
+	sibylpb.Action_ACTION_DECK:       WsRequestActionDeck,
+	sibylpb.Action_ACTION_TOPIC:      WsRequestActionTopic,
+	sibylpb.Action_ACTION_USERNAME:   WsRequestActionUsername,
+	sibylpb.Action_ACTION_KICK:       WsRequestActionKick,
+	sibylpb.Action_ACTION_MUTE:       WsRequestActionMute,
+	sibylpb.Action_ACTION_UNMUTE:     WsRequestActionUnmute,
+	sibylpb.Action_ACTION_CHAT:       WsRequestActionChat,
+	sibylpb.Action_ACTION_REACT:      WsRequestActionReact,
+	sibylpb.Action_ACTION_SYNC:       WsRequestActionSync,
+	sibylpb.Action_ACTION_VISIBILITY: WsRequestActionVisibility,
+	sibylpb.Action_ACTION_INSIGHTS:   WsRequestActionInsights,
+	sibylpb.Action_ACTION_COMMIT:     WsRequestActionCommit,
+}
+
+var unmarshalEvent = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// GRPCServer returns a gRPC server with the Sibyl service registered, which can be used with Serve.
+func (s *Server) GRPCServer() *grpc.Server {
+	gs := grpc.NewServer()
+	sibylpb.RegisterSibylServer(gs, &grpcService{s: s})
+
+	return gs
+}
+
+// grpcService implements the Sibyl gRPC service on top of the rooms of a Server.
+type grpcService struct {
+	sibylpb.UnimplementedSibylServer
+
+	s *Server
+}
+
+func (gs *grpcService) CreateRoom(ctx context.Context, req *sibylpb.CreateRoomRequest) (*sibylpb.Room, error) {
+	if !game.RoomNameIsValid(req.Room) {
+		return nil, status.Error(codes.InvalidArgument, game.RoomNameValidDescription)
+	}
+
+	if err := gs.s.createGameIfNotExists(req.Room, req.Deck); err != nil {
+		if err == game.ErrInvalidRoomName {
+			return nil, status.Error(codes.InvalidArgument, game.RoomNameValidDescription)
+		}
+
+		log.WithFields(log.Fields{"room": req.Room}).Errorf("could not create room: %v", err)
+		return nil, status.Error(codes.Internal, "could not create room")
+	}
+
+	return gs.GetRoom(ctx, &sibylpb.GetRoomRequest{Room: req.Room})
+}
+
+func (gs *grpcService) GetRoom(ctx context.Context, req *sibylpb.GetRoomRequest) (*sibylpb.Room, error) {
+	g := gs.s.getGameByRoom(req.Room)
+	if g == nil {
+		return nil, status.Error(codes.NotFound, "room not found")
+	}
+
+	return &sibylpb.Room{
+		Room:    g.Room,
+		Token:   g.Token,
+		Deck:    g.Deck().Name,
+		Topic:   g.Topic(),
+		Players: int32(g.RegisteredClientsCount()),
+	}, nil
+}
+
+func (gs *grpcService) Act(ctx context.Context, req *sibylpb.ActRequest) (*sibylpb.ActResponse, error) {
+	action, found := grpcActions[req.Action]
+	if !found {
+		return nil, status.Error(codes.InvalidArgument, "unknown action")
+	}
+
+	client, found := gs.s.streamClient(req.ClientId)
+	if !found {
+		return nil, status.Error(codes.NotFound, "client not found")
+	}
+
+	if client.Game.Room != req.Room || client.Game.Token != req.Token {
+		return nil, status.Error(codes.PermissionDenied, "token does not match for room")
+	}
+
+	gs.s.handleRequest(ctx, client, &WsRequest{
+		Action:   action,
+		Card:     int(req.Card),
+		Deck:     req.Deck,
+		Room:     req.Room,
+		Token:    req.Token,
+		Value:    req.Value,
+		PlayerID: int(req.PlayerId),
+	})
+
+	return &sibylpb.ActResponse{}, nil
+}
+
+func (gs *grpcService) Watch(req *sibylpb.WatchRequest, stream sibylpb.Sibyl_WatchServer) error {
+	g := gs.s.getGameByRoom(req.Room)
+	if g == nil {
+		return status.Error(codes.NotFound, "room not found")
+	}
+
+	if req.Token != g.Token {
+		return status.Error(codes.PermissionDenied, "token does not match for room")
+	}
+
+	conn := newGRPCConn(stream)
+	client := gs.s.configureClient(NewClient(g, conn, g.NextClientID(), req.Username))
+	client.spectator = req.Spectator
+
+	gs.s.serveStream(client, func(id string) error {
+		return stream.Send(&sibylpb.Event{Event: &sibylpb.Event_ClientId{ClientId: id}})
+	})
+
+	return nil
+}
+
+// grpcConn is a WsConn for clients watching a room over gRPC. Whatever would be written to a web socket
+// is sent on the stream as an Event, while actions arrive through Act.
+type grpcConn struct {
+	stream sibylpb.Sibyl_WatchServer
+	addr   net.Addr
+	done   chan struct{}
+	closed bool
+	mu     sync.Mutex
+}
+
+func newGRPCConn(stream sibylpb.Sibyl_WatchServer) *grpcConn {
+	c := &grpcConn{
+		stream: stream,
+		addr:   streamAddr("unknown"),
+		done:   make(chan struct{}),
+	}
+
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		c.addr = p.Addr
+	}
+
+	go func() {
+		select {
+		case <-stream.Context().Done():
+			c.Close()
+		case <-c.done:
+		}
+	}()
+
+	return c
+}
+
+// send sends an event on the stream.
+func (c *grpcConn) send(e *sibylpb.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrStreamClosed
+	}
+
+	return c.stream.Send(e)
+}
+
+// Close ends the stream. It is safe to call more than once.
+func (c *grpcConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+
+	return nil
+}
+
+// ReadJSON blocks until the stream is closed, since actions don't arrive on the stream.
+func (c *grpcConn) ReadJSON(v interface{}) error {
+	<-c.done
+	return ErrStreamClosed
+}
+
+func (c *grpcConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *grpcConn) SetPongHandler(func(appData string) error) {}
+
+func (c *grpcConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *grpcConn) SetReadLimit(limit int64) {}
+
+func (c *grpcConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// WriteJSON sends a payload from the game as an event. Payloads are encoded the same as for a web
+// socket, then decoded into the matching event.
+func (c *grpcConn) WriteJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	e, err := eventFromJSON(b)
+	if err != nil {
+		return err
+	}
+
+	return c.send(e)
+}
+
+// WriteMessage sends a close message as a closed event holding the reason. gRPC keeps the connection
+// alive on its own, so pings are not sent.
+func (c *grpcConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != websocket.CloseMessage {
+		return nil
+	}
+
+	return c.send(&sibylpb.Event{Event: &sibylpb.Event_Closed{Closed: closeMessageReason(data)}})
+}
+
+// eventFromJSON returns the event for a payload sent by the game: an error, a chat message, or an update.
+func eventFromJSON(b []byte) (*sibylpb.Event, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	if raw, found := fields["error"]; found {
+		var reason string
+		if err := json.Unmarshal(raw, &reason); err != nil {
+			return nil, err
+		}
+
+		return &sibylpb.Event{Event: &sibylpb.Event_Error{Error: reason}}, nil
+	}
+
+	if raw, found := fields["message"]; found {
+		m := &sibylpb.Message{}
+		if err := unmarshalEvent.Unmarshal(raw, m); err != nil {
+			return nil, err
+		}
+
+		return &sibylpb.Event{Event: &sibylpb.Event_Message{Message: m}}, nil
+	}
+
+	u := &sibylpb.Update{}
+	if err := unmarshalEvent.Unmarshal(b, u); err != nil {
+		return nil, err
+	}
+
+	return &sibylpb.Event{Event: &sibylpb.Event_Update{Update: u}}, nil
+}
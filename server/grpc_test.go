@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/sibylpb"
+	"github.com/synacor/sibyl/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGRPCRooms(t *testing.T) {
+	c, closer := newGRPCTestClient(t, newStreamServer())
+	defer closer()
+	ctx := context.Background()
+
+	_, err := c.GetRoom(ctx, &sibylpb.GetRoomRequest{Room: "Test"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = c.CreateRoom(ctx, &sibylpb.CreateRoomRequest{Room: "Not valid!"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	room, err := c.CreateRoom(ctx, &sibylpb.CreateRoomRequest{Room: "Test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Test", room.Room)
+	assert.NotEmpty(t, room.Token)
+	assert.NotEmpty(t, room.Deck)
+
+	// creating a room that exists returns it
+	again, err := c.CreateRoom(ctx, &sibylpb.CreateRoomRequest{Room: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, room.Token, again.Token)
+
+	got, err := c.GetRoom(ctx, &sibylpb.GetRoomRequest{Room: "Test"})
+	assert.NoError(t, err)
+	assert.Equal(t, room.Token, got.Token)
+}
+
+func TestGRPCWatch(t *testing.T) {
+	c, closer := newGRPCTestClient(t, newStreamServer())
+	defer closer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	room, err := c.CreateRoom(ctx, &sibylpb.CreateRoomRequest{Room: "Test"})
+	assert.NoError(t, err)
+
+	stream, err := c.Watch(ctx, &sibylpb.WatchRequest{Room: "Test", Token: "wrong"})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	one, err := c.Watch(ctx, &sibylpb.WatchRequest{Room: "Test", Token: room.Token, Username: "One"})
+	assert.NoError(t, err)
+	e, err := one.Recv()
+	assert.NoError(t, err)
+	id := e.GetClientId()
+	assert.NotEmpty(t, id)
+
+	e, err = one.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "One", e.GetUpdate().Username)
+	assert.Equal(t, map[int32]string{1: "One"}, e.GetUpdate().Players)
+
+	// a spectator is shown as such, and isn't waited on
+	two, err := c.Watch(ctx, &sibylpb.WatchRequest{Room: "Test", Token: room.Token, Username: "Two", Spectator: true})
+	assert.NoError(t, err)
+	two.Recv()
+	e, err = two.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{2}, e.GetUpdate().Spectators)
+	one.Recv()
+
+	_, err = c.Act(ctx, &sibylpb.ActRequest{Room: "Test", Token: room.Token, ClientId: id, Action: sibylpb.Action_ACTION_SELECT, Card: 3, Deck: room.Deck})
+	assert.NoError(t, err)
+	e, err = two.Recv()
+	assert.NoError(t, err)
+	assert.True(t, e.GetUpdate().Reveal)
+	assert.Equal(t, int32(3), e.GetUpdate().Cards[0].Card)
+	assert.Equal(t, int32(1), e.GetUpdate().Cards[0].PlayerId)
+	one.Recv()
+
+	_, err = c.Act(ctx, &sibylpb.ActRequest{Room: "Test", Token: room.Token, ClientId: id, Action: sibylpb.Action_ACTION_CHAT, Value: "Hello"})
+	assert.NoError(t, err)
+	e, err = two.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello", e.GetMessage().Text)
+	assert.Equal(t, "One", e.GetMessage().Player)
+
+	_, err = c.Act(ctx, &sibylpb.ActRequest{Room: "Test", Token: room.Token, ClientId: id, Action: sibylpb.Action_ACTION_VISIBILITY, Value: "hidden"})
+	assert.NoError(t, err)
+	e, err = two.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, map[int32]string{1: "away"}, e.GetUpdate().Presence)
+	one.Recv()
+
+	_, err = c.Act(ctx, &sibylpb.ActRequest{Room: "Test", Token: room.Token, ClientId: id, Action: sibylpb.Action_ACTION_INSIGHTS, Value: "on"})
+	assert.NoError(t, err)
+	e, err = two.Recv()
+	assert.NoError(t, err)
+	assert.True(t, e.GetUpdate().Insights)
+	one.Recv()
+
+	_, err = c.Act(ctx, &sibylpb.ActRequest{Room: "Test", Token: room.Token, ClientId: "unknown", Action: sibylpb.Action_ACTION_REVEAL})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = c.Act(ctx, &sibylpb.ActRequest{Room: "Test", Token: room.Token, ClientId: id})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// a kicked player is told why
+	_, err = c.Act(ctx, &sibylpb.ActRequest{Room: "Test", Token: room.Token, ClientId: id, Action: sibylpb.Action_ACTION_KICK, PlayerId: 2})
+	assert.NoError(t, err)
+	e, err = two.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "You have been removed from the room.", e.GetError())
+	e, err = two.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "You have been removed from the room.", e.GetClosed())
+
+	got, err := c.GetRoom(ctx, &sibylpb.GetRoomRequest{Room: "Test"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), got.Players)
+}
+
+func TestGRPCCommit(t *testing.T) {
+	s := newStreamServer()
+	var err error
+	if s.store, err = store.Open(t.TempDir()); !assert.NoError(t, err) {
+		return
+	}
+	c, closer := newGRPCTestClient(t, s)
+	defer closer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	room, _ := c.CreateRoom(ctx, &sibylpb.CreateRoomRequest{Room: "Platform"})
+	one, err := c.Watch(ctx, &sibylpb.WatchRequest{Room: "Platform", Token: room.Token, Username: "One"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	e, _ := one.Recv()
+	id := e.GetClientId()
+	one.Recv()
+
+	c.Act(ctx, &sibylpb.ActRequest{Room: "Platform", Token: room.Token, ClientId: id, Action: sibylpb.Action_ACTION_SELECT, Card: 3, Deck: room.Deck})
+	one.Recv()
+
+	_, err = c.Act(ctx, &sibylpb.ActRequest{Room: "Platform", Token: room.Token, ClientId: id, Action: sibylpb.Action_ACTION_COMMIT, Value: "PLAT-1"})
+	assert.NoError(t, err)
+	e, err = one.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "commit", e.GetMessage().Kind)
+
+	story, found := s.store.Story("PLAT-1")
+	assert.True(t, found)
+	assert.Equal(t, "3", story.Estimate)
+}
+
+func TestEventFromJSON(t *testing.T) {
+	e, err := eventFromJSON([]byte(`{"error":"Bye"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "Bye", e.GetError())
+
+	e, err = eventFromJSON([]byte(`{"message":{"kind":"chat","playerID":2,"player":"Two","text":"Hi","time":5}}`))
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(&sibylpb.Message{Kind: "chat", PlayerId: 2, Player: "Two", Text: "Hi", Time: 5}, e.GetMessage()))
+
+	e, err = eventFromJSON([]byte(`{"topic":"Topic","players":{"1":"One"},"presence":{"1":"idle"},"absent":["Bea"],"unknown":true}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "Topic", e.GetUpdate().Topic)
+	assert.Equal(t, "idle", e.GetUpdate().Presence[1])
+	assert.Equal(t, []string{"Bea"}, e.GetUpdate().Absent)
+}
+
+// newGRPCTestClient serves the gRPC service of s in memory, and returns a client for it.
+func newGRPCTestClient(t *testing.T, s *Server) (sibylpb.SibylClient, func()) {
+	lis := bufconn.Listen(1024 * 1024)
+	gs := s.GRPCServer()
+	go gs.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sibylpb.NewSibylClient(conn), func() {
+		conn.Close()
+		done := make(chan struct{})
+		go func() {
+			gs.Stop()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+	}
+}
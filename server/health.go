@@ -0,0 +1,84 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// errDraining fails the readiness check while the server is shutting down
+var errDraining = errors.New("draining")
+
+// health holds what /readyz checks.
+type health struct {
+	checks   map[string]func() error
+	draining bool
+	mutex    sync.RWMutex
+}
+
+// AddReadinessCheck adds a check to /readyz, which fails while check returns an error. Adding a check
+// with the name of another replaces it.
+func (s *Server) AddReadinessCheck(name string, check func() error) {
+	s.health.mutex.Lock()
+	defer s.health.mutex.Unlock()
+
+	s.health.checks[name] = check
+}
+
+// Drain fails the readiness check from now on, so that no new players are sent to this server.
+func (s *Server) Drain() {
+	s.health.mutex.Lock()
+	defer s.health.mutex.Unlock()
+
+	s.health.draining = true
+}
+
+// isDraining returns whether Drain was called.
+func (s *Server) isDraining() bool {
+	s.health.mutex.RLock()
+	defer s.health.mutex.RUnlock()
+
+	return s.health.draining
+}
+
+// readinessErrors runs the readiness checks, and returns what's wrong, if anything.
+func (s *Server) readinessErrors() []string {
+	s.health.mutex.RLock()
+	defer s.health.mutex.RUnlock()
+
+	var problems []string
+	if s.health.draining {
+		problems = append(problems, errDraining.Error())
+	}
+
+	for name, check := range s.health.checks {
+		if err := check(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	sort.Strings(problems)
+
+	return problems
+}
+
+// healthzHandler handles requests to /healthz, which succeed as long as the server is running.
+func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// readyzHandler handles requests to /readyz, which fail while the server shouldn't be sent new players.
+func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if problems := s.readinessErrors(); len(problems) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(problems, "\n"))
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/game"
+)
+
+func TestHealthz(t *testing.T) {
+	s := newStreamServer()
+	s.Drain()
+
+	w := httptest.NewRecorder()
+	s.ServeMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok\n", w.Body.String())
+}
+
+func TestReadyz(t *testing.T) {
+	s := newStreamServer()
+	mux := s.ServeMux()
+	readyz := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w
+	}
+
+	w := readyz()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok\n", w.Body.String())
+
+	var storeErr error
+	s.AddReadinessCheck("store", func() error { return storeErr })
+	assert.Equal(t, http.StatusOK, readyz().Code)
+
+	storeErr = errors.New("unreachable")
+	w = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "store: unreachable\n", w.Body.String())
+
+	s.Drain()
+	assert.Equal(t, "draining\nstore: unreachable\n", readyz().Body.String())
+}
+
+func TestDrain(t *testing.T) {
+	s := newStreamServer()
+	s.destroyGame = make(chan *game.Game)
+	s.safeSettings.settings.drainTimeout = time.Minute
+	g, _ := game.New("Test", "", s.destroyGame)
+	s.safeGames.games["test"] = g
+
+	sig := make(chan os.Signal)
+	done := make(chan bool, 1)
+	go s.listenForEvents(sig, done)
+
+	sig <- syscall.SIGTERM
+	// once the next signal is received, the first has been handled
+	sig <- syscall.SIGUSR1
+	assert.Equal(t, []string{"draining"}, s.readinessErrors())
+	select {
+	case <-done:
+		assert.Fail(t, "should wait for the room to finish")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// the last room finishing ends the drain
+	g.Close("Bye")
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail(t, "should have shut down")
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	s := newStreamServer()
+	s.safeSettings.settings.drainTimeout = 10 * time.Millisecond
+	g, _ := game.New("Test", "", nil)
+	s.safeGames.games["test"] = g
+
+	sig := make(chan os.Signal)
+	done := make(chan bool, 1)
+	go s.listenForEvents(sig, done)
+
+	sig <- syscall.SIGTERM
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail(t, "should have shut down")
+	}
+
+	// without a drain timeout, shut down right away
+	s = newStreamServer()
+	s.safeGames.games["test"] = g
+	done = make(chan bool, 1)
+	go s.listenForEvents(sig, done)
+
+	sig <- syscall.SIGTERM
+	<-done
+	assert.Empty(t, s.readinessErrors())
+}
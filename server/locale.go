@@ -0,0 +1,60 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/synacor/sibyl/i18n"
+)
+
+// localeCookie is the name of the cookie which keeps the locale a player chose with ?lang=
+const localeCookie = "sibyl_lang"
+
+// localeCookieAge is how long a player's choice of locale is kept
+const localeCookieAge = 365 * 24 * time.Hour
+
+// templateFuncs are the functions available to the templates
+var templateFuncs = template.FuncMap{
+	"t":       translateHTML,
+	"locales": i18n.Locales,
+}
+
+// translateHTML returns a message of the i18n catalog for a template. Messages may hold markup, since
+// the catalog is part of Sibyl, but args are escaped.
+func translateHTML(locale, key string, args ...interface{}) template.HTML {
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			args[i] = template.HTMLEscapeString(s)
+		}
+	}
+
+	return template.HTML(i18n.T(locale, key, args...))
+}
+
+// requestLocale returns the locale for a request. A locale chosen with ?lang=, or earlier with the
+// cookie it sets, is used before the Accept-Language header.
+func requestLocale(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); i18n.Supported(lang) {
+		return lang
+	}
+
+	if c, err := r.Cookie(localeCookie); err == nil && i18n.Supported(c.Value) {
+		return c.Value
+	}
+
+	return i18n.Negotiate(r.Header.Get("Accept-Language"))
+}
+
+// keepLocale remembers the locale chosen with ?lang= for the player's next visits.
+func keepLocale(w http.ResponseWriter, r *http.Request) {
+	if lang := r.URL.Query().Get("lang"); i18n.Supported(lang) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     localeCookie,
+			Value:    lang,
+			Path:     "/",
+			MaxAge:   int(localeCookieAge.Seconds()),
+			HttpOnly: true,
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLocale(t *testing.T) {
+	tests := []struct {
+		url, cookie, acceptLanguage, expected string
+	}{
+		{"/", "", "", "en"},
+		{"/", "", "de-DE,de;q=0.9,en;q=0.8", "de"},
+		{"/", "de", "en", "de"},
+		{"/", "xx", "en", "en"},
+		{"/?lang=en", "de", "de", "en"},
+		{"/?lang=xx", "", "de", "de"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, test.url, nil)
+		if test.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: localeCookie, Value: test.cookie})
+		}
+		r.Header.Set("Accept-Language", test.acceptLanguage)
+		assert.Equal(t, test.expected, requestLocale(r), "%+v", test)
+	}
+}
+
+func TestKeepLocale(t *testing.T) {
+	w := httptest.NewRecorder()
+	keepLocale(w, httptest.NewRequest(http.MethodGet, "/?lang=xx", nil))
+	assert.Empty(t, w.Result().Cookies())
+
+	w = httptest.NewRecorder()
+	keepLocale(w, httptest.NewRequest(http.MethodGet, "/?lang=de", nil))
+	if cookies := w.Result().Cookies(); assert.Len(t, cookies, 1) {
+		assert.Equal(t, localeCookie, cookies[0].Name)
+		assert.Equal(t, "de", cookies[0].Value)
+	}
+}
+
+func TestLocaleTemplates(t *testing.T) {
+	s, err := New(os.DirFS(".."))
+	if !assert.NoError(t, err) {
+		return
+	}
+	mux := s.ServeMux()
+
+	code, body := get(mux, "/?lang=de")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `<html lang="de">`)
+	assert.Contains(t, body, `placeholder="Raumnamen eingeben..."`)
+	assert.Contains(t, body, `<a href="?lang=en" lang="en">English</a>`)
+
+	assert.NoError(t, s.createGameIfNotExists("locales", ""))
+	code, body = get(mux, "/r/locales?lang=de")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "Das Thema")
+	assert.Contains(t, body, `"connected":"Verbunden."`)
+
+	// user input in messages is escaped
+	_, body = get(mux, "/?notfound=%3Cb%3E")
+	assert.NotContains(t, body, "<b>")
+}
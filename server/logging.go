@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the ID of a request. An ID given by a proxy in front of Sibyl is kept, so
+// its logs and Sibyl's can be joined.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength limits the length of request IDs given by clients
+const maxRequestIDLength = 64
+
+type requestIDKey struct{}
+
+// newLogID returns a random ID for correlating log lines, such as those of a request or connection.
+func newLogID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the ID given to a request by LogRequests, or a new ID if it has none.
+func requestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+
+	return newLogID()
+}
+
+// requestLog returns a log entry for a request, with its ID, and the ID of its trace if it's traced.
+func requestLog(r *http.Request) *log.Entry {
+	return log.WithFields(traceFields(r.Context(), log.Fields{"request_id": requestID(r), "client": r.RemoteAddr}))
+}
+
+// traceFields adds the ID of the trace of ctx to fields, if it's traced.
+func traceFields(ctx context.Context, fields log.Fields) log.Fields {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+	}
+
+	return fields
+}
+
+// LogRequests gives each request an ID, which is added to the request's log lines and returned in the
+// X-Request-Id header, and logs each request once it's done. The query isn't logged, since it may
+// hold a room's token.
+func LogRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newLogID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		log.WithFields(traceFields(r.Context(), log.Fields{
+			"request_id":  id,
+			"client":      r.RemoteAddr,
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      rec.status,
+			"bytes":       rec.bytes,
+			"duration_ms": time.Since(start).Milliseconds(),
+			"user_agent":  r.UserAgent(),
+			"referer":     r.Referer(),
+		})).Info("request")
+	})
+}
+
+// statusRecorder records the status and size of a response. It can still be hijacked and flushed, for
+// web sockets and event streams.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is not supported")
+	}
+
+	// the connection is upgraded, such as to a web socket
+	r.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/game"
+)
+
+func TestLogRequests(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	var handlerID string
+	h := LogRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerID = requestID(r)
+		http.NotFound(w, r)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing?token=secret", nil))
+	id := w.Header().Get(RequestIDHeader)
+	assert.Len(t, id, 16)
+	assert.Equal(t, id, handlerID)
+
+	e := hook.LastEntry()
+	assert.Equal(t, "request", e.Message)
+	assert.Equal(t, id, e.Data["request_id"])
+	assert.Equal(t, "/missing", e.Data["path"])
+	assert.Equal(t, http.StatusNotFound, e.Data["status"])
+	assert.Equal(t, w.Body.Len(), e.Data["bytes"])
+
+	// an ID from a proxy is kept, unless it's too long
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(RequestIDHeader, "from-proxy")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "from-proxy", w.Header().Get(RequestIDHeader))
+
+	r.Header.Set(RequestIDHeader, strings.Repeat("a", maxRequestIDLength+1))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Len(t, w.Header().Get(RequestIDHeader), 16)
+}
+
+func TestLogRequestsWebSocket(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	s := newStreamServer()
+	g, _ := game.New("Test", "", nil)
+	s.safeGames.games["test"] = g
+
+	ts := httptest.NewServer(LogRequests(s.ServeMux()))
+	defer ts.Close()
+
+	u := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?" + url.Values{"room": {"Test"}, "token": {g.Token}}.Encode()
+	conn, resp, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := resp.Header.Get(RequestIDHeader)
+
+	var update map[string]interface{}
+	assert.NoError(t, conn.ReadJSON(&update))
+
+	// the lines about the connection carry the request's ID
+	var registered *log.Entry
+	for _, e := range hook.AllEntries() {
+		if e.Message == "registered client" {
+			registered = e
+		}
+	}
+	if assert.NotNil(t, registered) {
+		assert.Equal(t, id, registered.Data["conn_id"])
+		assert.Equal(t, "Test", registered.Data["room"])
+		assert.Equal(t, 1, registered.Data["client_id"])
+	}
+
+	conn.Close()
+}
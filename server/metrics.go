@@ -0,0 +1,40 @@
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Metrics are published with expvar, and served from /debug/vars.
+var (
+	// metricUpdatesCoalesced counts the game updates that were replaced by a newer one before being sent
+	metricUpdatesCoalesced = expvar.NewInt("sibyl_updates_coalesced")
+
+	// metricMessagesDropped counts the messages that were dropped because a client's buffer was full
+	metricMessagesDropped = expvar.NewInt("sibyl_messages_dropped")
+
+	// metricClientsEvicted counts the clients that were disconnected for not keeping up
+	metricClientsEvicted = expvar.NewInt("sibyl_clients_evicted")
+)
+
+// metricsHandler handles requests to GET /debug/vars. Only Sibyl's own metrics are served, and not
+// everything expvar publishes, since that includes the command line, which may hold secrets.
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	vars := make([]string, 0)
+	expvar.Do(func(kv expvar.KeyValue) {
+		if strings.HasPrefix(kv.Key, "sibyl_") {
+			vars = append(vars, fmt.Sprintf("%q: %s", kv.Key, kv.Value))
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n%s\n}\n", strings.Join(vars, ",\n"))
+}
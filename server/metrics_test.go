@@ -0,0 +1,34 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	s := newStreamServer()
+	s.safeSettings.settings.adminToken = "secret"
+	mux := s.ServeMux()
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	mux.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// only Sibyl's metrics are served, and not the command line
+	var vars map[string]int
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &vars))
+	assert.Contains(t, vars, "sibyl_updates_coalesced")
+	assert.Contains(t, vars, "sibyl_messages_dropped")
+	assert.Contains(t, vars, "sibyl_clients_evicted")
+	assert.Len(t, vars, 3)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/synacor/sibyl/game"
+)
+
+// RoomConfig configures a room, from the rooms section of the config.
+type RoomConfig struct {
+	Name string `mapstructure:"name" json:"name"`
+
+	// Pinned rooms are opened when the server starts, and are never destroyed when they're empty
+	Pinned bool `mapstructure:"pinned" json:"pinned"`
+
+	// DestroyDelay replaces room_destroy_delay for the room, when it's set
+	DestroyDelay string `mapstructure:"destroy_delay" json:"destroy_delay,omitempty"`
+
+	// destroyDelay is DestroyDelay, parsed
+	destroyDelay time.Duration
+}
+
+// ConfigRooms returns the rooms configured in the config.
+func ConfigRooms() ([]RoomConfig, error) {
+	rooms := make([]RoomConfig, 0)
+	if err := viper.UnmarshalKey("rooms", &rooms); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for i := range rooms {
+		rc := &rooms[i]
+		if !game.RoomNameIsValid(rc.Name) {
+			return nil, fmt.Errorf("%q is not a valid room name. %s", rc.Name, game.RoomNameValidDescription)
+		}
+
+		// as with roomKey, room names are not case sensitive
+		key := strings.ToLower(rc.Name)
+		if seen[key] {
+			return nil, fmt.Errorf("%q is configured more than once", rc.Name)
+		}
+		seen[key] = true
+
+		if rc.DestroyDelay != "" {
+			d, err := time.ParseDuration(rc.DestroyDelay)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("the destroy_delay of %q must be a duration such as \"5m\"", rc.Name)
+			}
+			rc.destroyDelay = d
+		}
+	}
+
+	return rooms, nil
+}
+
+// configRooms returns the rooms configured in the config by their room key. The config is checked
+// along with the rest of it, before it's read here.
+func configRooms() map[string]RoomConfig {
+	rooms, _ := ConfigRooms()
+
+	byKey := make(map[string]RoomConfig, len(rooms))
+	for _, rc := range rooms {
+		byKey[strings.ToLower(rc.Name)] = rc
+	}
+
+	return byKey
+}
+
+// openPinnedRooms opens the pinned rooms which aren't open yet. Nothing is opened while the server
+// drains.
+func (s *Server) openPinnedRooms(set settings) {
+	if s.isDraining() {
+		return
+	}
+
+	for _, rc := range set.rooms {
+		if !rc.Pinned {
+			continue
+		}
+
+		if err := s.createGameIfNotExists(rc.Name, ""); err != nil {
+			log.WithField("room", rc.Name).Errorf("could not open pinned room: %v", err)
+		}
+	}
+}
+
+// unpinRooms lets every room be destroyed once it's empty, so that a drain isn't kept waiting on
+// pinned rooms.
+func (s *Server) unpinRooms() {
+	s.safeGames.mutex.RLock()
+	defer s.safeGames.mutex.RUnlock()
+
+	for _, g := range s.safeGames.games {
+		g.SetPinned(false)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigRooms(t *testing.T) {
+	defer viper.Set("rooms", nil)
+
+	tests := []struct {
+		rooms interface{}
+		err   string
+	}{
+		{nil, ""},
+		{[]interface{}{map[string]interface{}{"name": "Platform Planning", "pinned": true, "destroy_delay": "1h"}}, ""},
+		{[]interface{}{map[string]interface{}{"name": "bad name!"}}, `"bad name!" is not a valid room name.`},
+		{[]interface{}{map[string]interface{}{"name": "Planning"}, map[string]interface{}{"name": "planning"}}, `"planning" is configured more than once`},
+		{[]interface{}{map[string]interface{}{"name": "Planning", "destroy_delay": "-1s"}}, `the destroy_delay of "Planning" must be a duration`},
+	}
+	for _, test := range tests {
+		viper.Set("rooms", test.rooms)
+		_, err := ConfigRooms()
+		if test.err == "" {
+			assert.NoError(t, err)
+		} else if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), test.err)
+		}
+	}
+}
+
+func TestPinnedRooms(t *testing.T) {
+	viper.Set("admin_token", "secret")
+	viper.Set("rooms", []interface{}{map[string]interface{}{"name": "Platform Planning", "pinned": true, "destroy_delay": "1h"}})
+	defer func() {
+		viper.Set("admin_token", nil)
+		viper.Set("rooms", nil)
+	}()
+
+	s, err := New(os.DirFS(".."))
+	if !assert.NoError(t, err) {
+		return
+	}
+	mux := s.ServeMux()
+
+	// the pinned room is open before anyone asks for it
+	code, _ := get(mux, "/r/platform%20planning")
+	assert.Equal(t, http.StatusOK, code)
+	g := s.getGameByRoom("Platform Planning")
+	if !assert.NotNil(t, g) {
+		return
+	}
+	assert.True(t, g.Pinned())
+
+	// closing a pinned room opens it again, with a new token
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/admin/rooms/Platform%20Planning", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	mux.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, g, <-s.destroyGame)
+	g2 := s.getGameByRoom("Platform Planning")
+	if !assert.NotNil(t, g2) {
+		return
+	}
+	assert.NotEqual(t, g.Token, g2.Token)
+
+	// once it's no longer pinned, the empty room is destroyed after room_destroy_delay
+	viper.Set("rooms", nil)
+	viper.Set("room_destroy_delay", "1ms")
+	defer viper.Set("room_destroy_delay", nil)
+	s.Reload()
+	assert.False(t, g2.Pinned())
+	select {
+	case destroyed := <-s.destroyGame:
+		assert.Equal(t, g2, destroyed)
+	case <-time.After(time.Second):
+		assert.Fail(t, "should have been destroyed")
+	}
+}
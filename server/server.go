@@ -2,9 +2,15 @@
 package server
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
@@ -15,13 +21,16 @@ import (
 	"syscall"
 	"time"
 
-	rice "github.com/GeertJohan/go.rice"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/gorilla/websocket"
 	"github.com/spf13/viper"
 	"github.com/synacor/sibyl/deck"
 	"github.com/synacor/sibyl/game"
+	"github.com/synacor/sibyl/i18n"
+	"github.com/synacor/sibyl/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // WsRequestAction is a type for representing a web socket action
@@ -32,11 +41,34 @@ const (
 	WsRequestActionSelectCard WsRequestAction = "select"
 	WsRequestActionReveal                     = "reveal"
 	WsRequestActionReset                      = "reset"
+	WsRequestActionRevote                     = "revote"
 	WsRequestActionDeck                       = "deck"
 	WsRequestActionTopic                      = "topic"
 	WsRequestActionUsername                   = "username"
+	WsRequestActionKick                       = "kick"
+	WsRequestActionMute                       = "mute"
+	WsRequestActionUnmute                     = "unmute"
+	WsRequestActionVisibility                 = "visibility"
+	WsRequestActionChat                       = "chat"
+	WsRequestActionReact                      = "react"
+	WsRequestActionSync                       = "sync"
+	WsRequestActionInsights                   = "insights"
+	WsRequestActionCommit                     = "commit"
 )
 
+// sessionCookie is the name of the cookie used to identify a browser across connections
+const sessionCookie = "sibyl_session"
+
+// kickReason is the reason given to a player that was removed from a room, as a key of the i18n catalog
+const kickReason = "error.kicked"
+
+// DefaultKickBan is how long a player removed with "ban" is kept from rejoining the room
+const DefaultKickBan = 5 * time.Minute
+
+// bannedReason is given to a player that tries to rejoin a room too soon after being removed, as a key
+// of the i18n catalog
+const bannedReason = "error.banned"
+
 // WsRequest is data that was read from a web socket connection
 type WsRequest struct {
 	Action WsRequestAction `json:"action"`
@@ -45,34 +77,109 @@ type WsRequest struct {
 	Room   string          `json:"room"`
 	Token  string          `json:"token"`
 	Value  string          `json:"value"`
+
+	// PlayerID is the player the action applies to, for actions such as kick and mute
+	PlayerID int `json:"playerID"`
+}
+
+// wsError is an error sent to a client which has not been registered with a game
+type wsError struct {
+	Error string `json:"error"`
 }
 
 type safeGames struct {
 	games map[string]*game.Game
 	mutex *sync.RWMutex
+
+	// sessions holds the session of each open room of a team, see startTeamSession
+	sessions map[*game.Game]int
 }
 
 // Server is the main object that can be used to return an *http.ServeMux object.
 type Server struct {
-	staticBox   *rice.Box
-	templates   map[string]*template.Template
-	debug       bool
-	destroyGame chan *game.Game
-	safeGames   *safeGames
+	templates    map[string]*template.Template
+	destroyGame  chan *game.Game
+	safeGames    *safeGames
+	safeStreams  *safeStreams
+	safeSettings *safeSettings
+
+	// assets holds the templates and static directories, and static is the static directory
+	assets fs.FS
+	static fs.FS
+
+	// limits are shared with every client, so that Reload applies them to players already connected
+	limits *clientLimits
+
+	// store keeps the stories estimated in the rooms. It's nil unless data_dir is set.
+	store *store.Store
+
+	// sshHostKey is the path of the SSH host key, see SSHServer
+	sshHostKey string
+
+	health *health
+}
+
+// settings are the settings of a server which can change while it runs, see Reload.
+type settings struct {
+	debug bool
+
+	// devMode parses the templates for every request, see template
+	devMode bool
+
+	// outlierSteps is passed to each game, see game.SetOutlierSteps
+	outlierSteps int
+
+	// kickBan is how long a kicked player is kept from rejoining, when the facilitator asks for it
+	kickBan time.Duration
+
+	// skipAway is passed to each game, see game.SetSkipAway
+	skipAway bool
+
+	// deltaUpdates allows clients to ask for state diffs instead of full game updates
+	deltaUpdates bool
+
+	// adminToken must be given to use the admin interface, which is off when it's empty
+	adminToken string
+
+	// drainTimeout is how long to wait for rooms to finish once asked to shut down, see ListenForEvents
+	drainTimeout time.Duration
+
+	// destroyDelay is passed to each game, see game.SetDestroyDelay
+	destroyDelay time.Duration
+
+	// rooms holds the rooms of the config by their room key, which can be pinned or have their own
+	// destroyDelay
+	rooms map[string]RoomConfig
+
+	branding Branding
+}
+
+type safeSettings struct {
+	settings settings
+
+	// config is the effective config shown by the admin interface, see SetEffectiveConfig
+	config map[string]interface{}
+	mutex  sync.RWMutex
 }
 
 var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+	ReadBufferSize:    1024,
+	WriteBufferSize:   1024,
+	EnableCompression: true,
 }
 
 type indexTemplateValues struct {
+	Branding          Branding
+	Locale            string
 	RoomNameMaxLength int
 	Error             string
 	NotFoundRoom      string
 }
 
 type roomTemplateValues struct {
+	Branding          Branding
+	Locale            string
+	Messages          map[string]string
 	Token             string
 	Decks             []string
 	DecksJSON         template.JS
@@ -81,31 +188,149 @@ type roomTemplateValues struct {
 	TopicMaxLength    int
 	Username          string
 	UsernameMaxLength int
+	ChatMaxLength     int
+	Reactions         []string
+
+	// Stories shows the form to commit estimates to stories, which needs data_dir
+	Stories bool
 }
 
 func init() {
 	viper.BindEnv("debug")
+	viper.BindEnv("outlier_steps")
+	viper.BindEnv("kick_ban")
+	viper.SetDefault("kick_ban", DefaultKickBan.String())
+	viper.BindEnv("idle_after")
+	viper.SetDefault("idle_after", DefaultIdleAfter.String())
+	viper.BindEnv("auto_reveal_skip_away")
+	viper.BindEnv("chat_rate_limit")
+	viper.SetDefault("chat_rate_limit", DefaultChatRateLimit)
+	viper.BindEnv("delta_updates")
+	viper.BindEnv("ssh_host_key")
+	viper.BindEnv("admin_token")
+	viper.BindEnv("drain_timeout")
+	viper.BindEnv("assets_dir")
+	viper.BindEnv("dev_mode")
+	viper.BindEnv("data_dir")
+	viper.BindEnv("room_destroy_delay")
+	viper.SetDefault("room_destroy_delay", game.DefaultDestroyDelay.String())
 }
 
-// New returns a new *Server object
-func New(templatesBox, staticBox *rice.Box) *Server {
-	base := template.Must(template.New("").Parse(templatesBox.MustString("template.html")))
+// New returns a new *Server object, which serves the templates and static files in the templates and
+// static directories of assets. If assets_dir is set, the files in it are served instead, where it
+// has them.
+func New(assets fs.FS) (*Server, error) {
+	if dir := viper.GetString("assets_dir"); dir != "" {
+		assets = overlayFS{upper: os.DirFS(dir), lower: assets}
+	}
+
+	templates, err := parseTemplates(assets)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse templates: %v", err)
+	}
+
+	static, err := fs.Sub(assets, "static")
+	if err != nil {
+		return nil, err
+	}
+
 	c := &Server{
-		staticBox: staticBox,
+		assets:    assets,
+		static:    static,
+		templates: templates,
 		safeGames: &safeGames{
-			games: make(map[string]*game.Game),
-			mutex: &sync.RWMutex{},
+			games:    make(map[string]*game.Game),
+			mutex:    &sync.RWMutex{},
+			sessions: make(map[*game.Game]int),
 		},
-		destroyGame: make(chan *game.Game),
-
-		debug: viper.GetBool("debug"),
-		templates: map[string]*template.Template{
-			"index": template.Must(template.Must(base.Clone()).Parse(templatesBox.MustString("index.html"))),
-			"room":  template.Must(template.Must(base.Clone()).Parse(templatesBox.MustString("room.html"))),
+		safeStreams: &safeStreams{
+			clients: make(map[string]*Client),
+			mutex:   &sync.RWMutex{},
 		},
+		destroyGame:  make(chan *game.Game),
+		safeSettings: &safeSettings{settings: configSettings()},
+		limits:       newClientLimits(viper.GetDuration("idle_after"), viper.GetInt("chat_rate_limit")),
+		sshHostKey:   viper.GetString("ssh_host_key"),
+		health:       &health{checks: make(map[string]func() error)},
+	}
+
+	if dir := viper.GetString("data_dir"); dir != "" {
+		if c.store, err = store.Open(dir); err != nil {
+			return nil, fmt.Errorf("could not open data_dir: %v", err)
+		}
+		c.AddReadinessCheck("store", c.store.Err)
 	}
 
-	return c
+	c.openPinnedRooms(c.settings())
+
+	c.AddReadinessCheck("templates", func() error {
+		for _, page := range pages {
+			if t, err := c.template(page); err != nil {
+				return err
+			} else if t == nil {
+				return errors.New("not parsed")
+			}
+		}
+		return nil
+	})
+
+	return c, nil
+}
+
+// configSettings returns the settings in the config.
+func configSettings() settings {
+	// the branding is checked along with the rest of the config, before it's read here
+	branding, _ := ConfigBranding()
+
+	return settings{
+		debug:        viper.GetBool("debug"),
+		devMode:      viper.GetBool("dev_mode"),
+		outlierSteps: viper.GetInt("outlier_steps"),
+		kickBan:      viper.GetDuration("kick_ban"),
+		skipAway:     viper.GetBool("auto_reveal_skip_away"),
+		deltaUpdates: viper.GetBool("delta_updates"),
+		adminToken:   viper.GetString("admin_token"),
+		drainTimeout: viper.GetDuration("drain_timeout"),
+		destroyDelay: viper.GetDuration("room_destroy_delay"),
+		rooms:        configRooms(),
+		branding:     branding,
+	}
+}
+
+// settings returns the current settings of the server.
+func (s *Server) settings() settings {
+	s.safeSettings.mutex.RLock()
+	defer s.safeSettings.mutex.RUnlock()
+
+	return s.safeSettings.settings
+}
+
+// Reload applies the settings in the config which can change while the server runs. Open rooms and
+// connected players are given the new settings too. The ports, TLS and SSH settings are only read by
+// New.
+func (s *Server) Reload() {
+	set := configSettings()
+	s.safeSettings.mutex.Lock()
+	s.safeSettings.settings = set
+	s.safeSettings.mutex.Unlock()
+
+	s.limits.set(viper.GetDuration("idle_after"), viper.GetInt("chat_rate_limit"))
+
+	s.safeGames.mutex.RLock()
+	for _, g := range s.safeGames.games {
+		s.configureGame(g, set)
+	}
+	s.safeGames.mutex.RUnlock()
+
+	s.openPinnedRooms(set)
+}
+
+// SetEffectiveConfig sets the config shown by the admin interface. Secrets must already be hidden.
+func (s *Server) SetEffectiveConfig(config map[string]interface{}) {
+	s.safeSettings.mutex.Lock()
+	defer s.safeSettings.mutex.Unlock()
+
+	s.safeSettings.config = config
 }
 
 // ServeMux returns a mux that can be used with the listen and server methods in net/http
@@ -114,22 +339,24 @@ func (s *Server) ServeMux() *http.ServeMux {
 	m.HandleFunc("/", s.indexHandler)
 	m.HandleFunc("/r/", s.roomHandler)
 	m.HandleFunc("/ws", s.wsHandler)
+	m.HandleFunc("/sse", s.sseHandler)
+	m.HandleFunc("/sse/action", s.sseActionHandler)
 	m.HandleFunc("/create", s.createRoomHandler)
-	m.Handle("/static/", http.StripPrefix("/static/", http.FileServer(s.staticBox.HTTPBox())))
+	m.HandleFunc("/history", s.historyHandler)
+	m.HandleFunc("/admin/rooms", s.requireAdmin(s.adminRoomsHandler))
+	m.HandleFunc("/admin/rooms/", s.requireAdmin(s.adminRoomHandler))
+	m.HandleFunc("/admin/config", s.requireAdmin(s.adminConfigHandler))
+	m.HandleFunc("/admin/stories", s.requireAdmin(s.requireStore(s.adminStoriesHandler)))
+	m.HandleFunc("/admin/stories/", s.requireAdmin(s.requireStore(s.adminStoryHandler)))
+	m.HandleFunc("/admin/accuracy", s.requireAdmin(s.requireStore(s.adminAccuracyHandler)))
+	m.HandleFunc("/admin/teams", s.requireAdmin(s.requireStore(s.adminTeamsHandler)))
+	m.HandleFunc("/admin/teams/", s.requireAdmin(s.requireStore(s.adminTeamHandler)))
+	m.HandleFunc("/healthz", s.healthzHandler)
+	m.HandleFunc("/readyz", s.readyzHandler)
+	m.HandleFunc("/debug/vars", s.requireAdmin(s.metricsHandler))
+	m.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(s.static))))
 	m.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
-		file, err := s.staticBox.Open("favicon.ico")
-		if err != nil {
-			http.NotFound(w, r)
-			return
-		}
-		defer file.Close()
-
-		var modTime time.Time
-		if stat, _ := file.Stat(); stat != nil {
-			modTime = stat.ModTime()
-		}
-
-		http.ServeContent(w, r, "favicon.ico", modTime, file)
+		http.ServeFileFS(w, r, s.static, "favicon.ico")
 	})
 
 	return m
@@ -156,7 +383,7 @@ func (s *Server) createRoomHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		log.WithFields(log.Fields{"room": room}).Errorf("could not create room: %v", err)
+		requestLog(r).WithField("room", room).Errorf("could not create room: %v", err)
 		http.Redirect(w, r, "/?error", http.StatusSeeOther)
 		return
 	}
@@ -166,6 +393,25 @@ func (s *Server) createRoomHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// historyHandler handles requests to GET /history, which returns the history of a room as JSON. As with
+// the actions of a player, the room's token must be given along with its name.
+func (s *Server) historyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	g := s.getGameByRoom(r.FormValue("room"))
+	if g == nil || subtle.ConstantTimeCompare([]byte(r.FormValue("token")), []byte(g.Token)) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(g.WithContext(r.Context()).History())
+}
+
 // indexHandler handles requests to /
 func (s *Server) indexHandler(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
@@ -173,47 +419,52 @@ func (s *Server) indexHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	keepLocale(w, r)
+	locale := requestLocale(r)
 	values := indexTemplateValues{
+		Branding:          s.settings().branding,
+		Locale:            locale,
 		RoomNameMaxLength: game.RoomNameMaxLength,
 	}
 
 	r.ParseForm()
 	if _, hasInvalid := r.Form["invalid"]; hasInvalid {
-		values.Error = fmt.Sprintf("Invalid room name. %s", game.RoomNameValidDescription)
+		values.Error = i18n.T(locale, "error.invalid_room_name")
 	} else if room := r.FormValue("notfound"); room != "" {
 		// we'll present a quick create button for the user
 		values.NotFoundRoom = room
 	} else if _, hasError := r.Form["error"]; hasError {
-		values.Error = fmt.Sprintf("We could not complete your request at this time.")
+		values.Error = i18n.T(locale, "error.generic")
 	}
 
-	s.templates["index"].Execute(w, &values)
+	s.render(w, r, "index", &values)
 }
 
 // wsHandler handles requests to /ws
 func (s *Server) wsHandler(w http.ResponseWriter, r *http.Request) {
-	room := r.FormValue("room")
-	token := r.FormValue("token")
-	username := r.FormValue("username")
-
-	g := s.getGameByRoom(room)
+	g := s.gameForRequest(r)
 	if g == nil {
-		log.WithFields(log.Fields{"room": room, "client": r.RemoteAddr}).Warn("could not get game for room")
 		return
 	}
 
-	if token != g.Token {
-		log.WithFields(log.Fields{"room": room, "client": r.RemoteAddr}).Warn("token does not match for room")
+	// the upgrade response is written by the upgrader, so it doesn't have the headers already set
+	conn, err := upgrader.Upgrade(w, r, http.Header{RequestIDHeader: {requestID(r)}})
+	if err != nil {
+		requestLog(r).Errorf("could not upgrade connection: %v", err)
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Errorf("could not upgrade connection: %v", err)
+	client := s.newClient(g, conn, r)
+	if g.IsBanned(client.session) {
+		client.logger().Warn("kicked session tried to rejoin room")
+		reason := i18n.T(client.Locale(), bannedReason)
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		conn.WriteJSON(&wsError{reason})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason))
+		conn.Close()
 		return
 	}
 
-	client := NewClient(g, conn, g.NextClientID(), username)
 	g.RegisterClient(client)
 	defer func() {
 		g.UnregisterClient(client)
@@ -223,6 +474,53 @@ func (s *Server) wsHandler(w http.ResponseWriter, r *http.Request) {
 	client.ReadPump(s)
 }
 
+// gameForRequest returns the game for the room and token of a request to join it, or nil if there isn't one.
+func (s *Server) gameForRequest(r *http.Request) *game.Game {
+	room := r.FormValue("room")
+	token := r.FormValue("token")
+
+	g := s.getGameByRoom(room)
+	if g == nil {
+		requestLog(r).WithField("room", room).Warn("could not get game for room")
+		return nil
+	}
+
+	if token != g.Token {
+		requestLog(r).WithField("room", room).Warn("token does not match for room")
+		return nil
+	}
+
+	return g
+}
+
+// newClient returns a client for a request to join a game, configured for this server.
+func (s *Server) newClient(g *game.Game, conn WsConn, r *http.Request) *Client {
+	client := s.configureClient(NewClient(g, conn, g.NextClientID(), r.FormValue("username")))
+	client.session = requestSession(r)
+	client.connID = requestID(r)
+	client.locale = requestLocale(r)
+	client.connSpan = trace.SpanContextFromContext(r.Context())
+	client.deltas = s.settings().deltaUpdates && r.FormValue("delta") == "1"
+
+	return client
+}
+
+// configureClient applies the settings of this server to a new client.
+func (s *Server) configureClient(client *Client) *Client {
+	client.limits = s.limits
+
+	return client
+}
+
+// requestSession returns the browser session a request was made from, if it has one.
+func requestSession(r *http.Request) string {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
 // roomHandler handles requests to /r/
 func (s *Server) roomHandler(w http.ResponseWriter, r *http.Request) {
 	// Path looks like /r/foobar, so we want to strip off "/r/" (first 3 chars)
@@ -230,6 +528,13 @@ func (s *Server) roomHandler(w http.ResponseWriter, r *http.Request) {
 
 	var token string
 	g := s.getGameByRoom(room)
+	if t, isTeam := s.team(room); g == nil && isTeam {
+		// a team's room is always there, and opens again when someone comes back
+		if err := s.createGameIfNotExists(t.Name, ""); err != nil {
+			requestLog(r).WithField("room", room).Errorf("could not create team room: %v", err)
+		}
+		g = s.getGameByRoom(room)
+	}
 	if g == nil {
 		http.Redirect(w, r, "/?notfound="+url.QueryEscape(room), http.StatusSeeOther)
 		return
@@ -237,6 +542,12 @@ func (s *Server) roomHandler(w http.ResponseWriter, r *http.Request) {
 
 	token = g.Token
 
+	if _, err := r.Cookie(sessionCookie); err != nil {
+		if session, err := generateSession(); err == nil {
+			http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: session, Path: "/", HttpOnly: true})
+		}
+	}
+
 	deckJSON, _ := json.Marshal(deck.AllDecks)
 
 	decks := make([]string, 0, len(deck.AllDecks))
@@ -245,7 +556,12 @@ func (s *Server) roomHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	sort.Strings(decks)
 
+	keepLocale(w, r)
+	locale := requestLocale(r)
 	values := roomTemplateValues{
+		Branding:          s.settings().branding,
+		Locale:            locale,
+		Messages:          i18n.Messages(locale, "js."),
 		Token:             token,
 		Room:              g.Room,
 		URL:               r.URL.String(),
@@ -253,8 +569,11 @@ func (s *Server) roomHandler(w http.ResponseWriter, r *http.Request) {
 		DecksJSON:         template.JS(string(deckJSON)),
 		TopicMaxLength:    game.TopicMaxLength,
 		UsernameMaxLength: UsernameMaxLength,
+		ChatMaxLength:     game.ChatMaxLength,
+		Reactions:         game.Reactions,
+		Stories:           s.store != nil,
 	}
-	s.templates["room"].Execute(w, &values)
+	s.render(w, r, "room", &values)
 }
 
 func (s *Server) getGameByRoom(room string) *game.Game {
@@ -273,76 +592,223 @@ func (s *Server) createGameIfNotExists(room, defaultDeck string) error {
 		return nil
 	}
 
+	// a team's room starts with the team's deck
+	if t, found := s.team(room); found && t.Deck != "" {
+		defaultDeck = t.Deck
+	}
+
 	g, err := game.New(room, defaultDeck, s.destroyGame)
 	if err != nil {
 		return err
 	}
+	s.configureGame(g, s.settings())
 
 	log.WithFields(log.Fields{"room": g.Room, "token": g.Token}).Info("room created")
 	s.safeGames.mutex.Lock()
 	s.safeGames.games[s.roomKey(room)] = g
 	s.safeGames.mutex.Unlock()
 
+	s.startTeamSession(g)
+
 	return nil
 }
 
+// configureGame applies the settings of this server to a game, and the configuration of the team whose
+// room it is, if it's a team's.
+func (s *Server) configureGame(g *game.Game, set settings) {
+	outlierSteps, skipAway := set.outlierSteps, set.skipAway
+	destroyDelay, pinned := set.destroyDelay, false
+	var roster []string
+	insights := false
+
+	if rc, found := set.rooms[s.roomKey(g.Room)]; found {
+		if rc.DestroyDelay != "" {
+			destroyDelay = rc.destroyDelay
+		}
+		// a drain waits on pinned rooms like any other, see unpinRooms
+		pinned = rc.Pinned && !s.isDraining()
+	}
+
+	if t, found := s.team(g.Room); found {
+		if t.OutlierSteps != nil {
+			outlierSteps = *t.OutlierSteps
+		}
+		if t.SkipAway != nil {
+			skipAway = *t.SkipAway
+		}
+		roster = t.Roster
+		insights = t.Insights
+	}
+
+	g.SetOutlierSteps(outlierSteps)
+	g.SetSkipAway(skipAway)
+	g.SetDestroyDelay(destroyDelay)
+	g.SetPinned(pinned)
+
+	// players go idle without anything else happening, so the room checks on them
+	idleAfter, _ := s.limits.get()
+	g.SetPresenceInterval(idleAfter / 2)
+	g.SetRoster(roster)
+	g.SetTeamInsights(insights)
+}
+
 func (s *Server) roomKey(room string) string {
 	return strings.ToLower(room)
 }
 
+// generateSession returns a random identifier for a browser session.
+func generateSession() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
 // HandleWsRequest handles requests that came in from a web socket connection via Client
 func (s *Server) HandleWsRequest(c *Client, r *WsRequest) {
-	if s.debug {
+	s.handleRequest(context.Background(), c, r)
+}
+
+// handleRequest handles a request from a client, as part of ctx. The request is traced, and linked to
+// the trace of the request which opened the connection.
+func (s *Server) handleRequest(ctx context.Context, c *Client, r *WsRequest) {
+	opts := []trace.SpanStartOption{trace.WithAttributes(
+		attribute.String("sibyl.room", c.Game.Room),
+		attribute.Int("sibyl.client_id", c.ID()),
+		attribute.String("sibyl.action", string(r.Action)),
+	)}
+	if c.connSpan.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: c.connSpan}))
+	}
+
+	ctx, span := tracer().Start(ctx, "action "+string(r.Action), opts...)
+	defer span.End()
+
+	l := c.logger().WithField("action", r.Action)
+	if s.settings().debug {
 		b, err := json.Marshal(r)
 		if err != nil {
-			log.Errorf("could not marshal JSON: %v", err)
+			l.Errorf("could not marshal JSON: %v", err)
 		} else {
-			log.WithFields(log.Fields{"client": c.Conn.RemoteAddr().String()}).Debugf("received message: %s", string(b))
+			l.Debugf("received message: %s", string(b))
 		}
 	}
 
 	if c.Game.Room != r.Room || c.Game.Token != r.Token {
-		log.WithFields(log.Fields{"client": c.Conn.RemoteAddr().String()}).Warnf("token is stale. expected (%s, %s), got (%s, %s)", c.Game.Room, c.Game.Token, r.Room, r.Token)
+		l.Warnf("token is stale. expected (%s, %s), got (%s, %s)", c.Game.Room, c.Game.Token, r.Room, r.Token)
 		return
 	}
 
+	// there is no moderator, so anyone may kick or mute anyone else, but not themselves
+	switch r.Action {
+	case WsRequestActionKick, WsRequestActionMute, WsRequestActionUnmute:
+		if r.PlayerID == c.ID() {
+			l.Warn("client tried to kick or mute themselves")
+			return
+		}
+	}
+
+	g := c.Game.WithContext(ctx)
+	if r.Action != WsRequestActionVisibility && r.Action != WsRequestActionSync {
+		c.Touch()
+	}
+
 	switch r.Action {
 	case WsRequestActionSelectCard:
-		c.Game.AddCard(c, r.Card, r.Deck)
+		g.AddCard(c, r.Card, r.Deck)
 	case WsRequestActionReveal:
-		c.Game.Reveal()
+		g.Reveal()
 	case WsRequestActionReset:
-		c.Game.Reset()
+		g.Reset()
+	case WsRequestActionRevote:
+		g.Revote()
 	case WsRequestActionDeck:
 		d, found := deck.AllDecks[r.Deck]
 		if found {
-			c.Game.SetDeck(d)
+			g.SetDeck(d)
 		}
 	case WsRequestActionTopic:
-		c.Game.SetTopic(r.Value)
+		g.SetTopic(r.Value)
 	case WsRequestActionUsername:
 		c.SetName(r.Value)
-		c.Game.SendUpdate()
+		g.SendUpdate()
+	case WsRequestActionKick:
+		var banFor time.Duration
+		if r.Value == "ban" {
+			banFor = s.settings().kickBan
+		}
+		g.Kick(r.PlayerID, kickReason, banFor)
+	case WsRequestActionMute:
+		g.Mute(r.PlayerID)
+	case WsRequestActionUnmute:
+		g.Unmute(r.PlayerID)
+	case WsRequestActionVisibility:
+		c.SetHidden(r.Value == "hidden")
+		g.PresenceChanged()
+	case WsRequestActionChat, WsRequestActionReact:
+		if !c.AllowMessage() {
+			l.Warn("client is sending messages too quickly")
+			return
+		}
+
+		if r.Action == WsRequestActionChat {
+			g.Chat(c, r.Value)
+		} else {
+			g.React(c, r.Value)
+		}
+	case WsRequestActionSync:
+		g.Resync(c)
+	case WsRequestActionInsights:
+		g.SetInsights(r.Value == "on")
+	case WsRequestActionCommit:
+		s.commitEstimate(g, c, r.Value)
 	default:
-		log.Errorf("unknown action received via ws: %s", r.Action)
+		l.Error("unknown action received")
 	}
 }
 
 // ListenForEvents will listen for various events like when to destroy a game, and when to disconnect the server.
+//
+// On SIGTERM, the server drains first if drain_timeout is set: readiness fails, so no new players are
+// sent here, and the server waits for its rooms to finish for up to drain_timeout. SIGINT, or a second
+// SIGTERM, shuts down right away.
 func (s *Server) ListenForEvents(done chan bool) {
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT, syscall.SIGUSR1)
 
+	s.listenForEvents(sig, done)
+}
+
+func (s *Server) listenForEvents(sig <-chan os.Signal, done chan bool) {
+	// drained fires once the drain times out. It is nil until the server drains.
+	var drained <-chan time.Time
+
 	for {
 		select {
 		case game := <-s.destroyGame:
 			roomKey := s.roomKey(game.Room)
 			s.safeGames.mutex.Lock()
-			if _, ok := s.safeGames.games[roomKey]; ok {
+			// the room may have been closed already, and another room created with its name
+			if g, ok := s.safeGames.games[roomKey]; ok && g == game {
 				delete(s.safeGames.games, roomKey)
 				log.WithFields(log.Fields{"room": game.Room, "token": game.Token}).Info("room destroyed")
 			}
+			remaining := len(s.safeGames.games)
 			s.safeGames.mutex.Unlock()
+
+			s.endTeamSession(game)
+
+			if drained != nil && remaining == 0 {
+				log.Printf("All rooms finished. Shut down.")
+				done <- true
+				return
+			}
+		case <-drained:
+			log.Printf("Drain timed out. Shut down.")
+			done <- true
+			return
 		case theSig := <-sig:
 			if theSig == syscall.SIGUSR1 {
 				s.safeGames.mutex.RLock()
@@ -361,6 +827,12 @@ func (s *Server) ListenForEvents(done chan bool) {
 					log.WithFields(log.Fields{"room": key, "clients": s.safeGames.games[key].RegisteredClientsCount()}).Infof("room #%d", i+1)
 				}
 				s.safeGames.mutex.RUnlock()
+			} else if theSig == syscall.SIGTERM && drained == nil && s.settings().drainTimeout > 0 && s.roomCount() > 0 {
+				timeout := s.settings().drainTimeout
+				log.WithFields(log.Fields{"timeout": timeout}).Info("draining")
+				s.Drain()
+				s.unpinRooms()
+				drained = time.After(timeout)
 			} else {
 				log.Printf("Shut down.")
 				done <- true
@@ -369,3 +841,11 @@ func (s *Server) ListenForEvents(done chan bool) {
 		}
 	}
 }
+
+// roomCount returns how many rooms are open.
+func (s *Server) roomCount() int {
+	s.safeGames.mutex.RLock()
+	defer s.safeGames.mutex.RUnlock()
+
+	return len(s.safeGames.games)
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/game"
+)
+
+func TestReload(t *testing.T) {
+	s := newStreamServer()
+	g, _ := game.New("Test", "", nil)
+	s.safeGames.games["test"] = g
+	c := s.configureClient(NewClient(g, newWsConn(), 1, ""))
+
+	values := map[string]interface{}{
+		"kick_ban":        "10m",
+		"admin_token":     "secret",
+		"idle_after":      "30s",
+		"chat_rate_limit": 1,
+	}
+	for key, value := range values {
+		viper.Set(key, value)
+	}
+	defer func() {
+		for key := range values {
+			viper.Set(key, nil)
+		}
+	}()
+
+	s.Reload()
+	assert.Equal(t, 10*time.Minute, s.settings().kickBan)
+	assert.Equal(t, "secret", s.settings().adminToken)
+
+	// players who are already connected get the new limits
+	idleAfter, chatRateLimit := c.limits.get()
+	assert.Equal(t, 30*time.Second, idleAfter)
+	assert.Equal(t, 1, chatRateLimit)
+	assert.True(t, c.AllowMessage())
+	assert.False(t, c.AllowMessage())
+}
+
+func TestHistoryHandler(t *testing.T) {
+	s := newStreamServer()
+	g, _ := game.New("Test", "", nil)
+	s.safeGames.games["test"] = g
+	conn := newWsConn()
+	conn.addr = &addr{"1.2.3.4"}
+	c1, c2 := NewClient(g, conn, 1, "Alex"), NewClient(g, conn, 2, "Bea")
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+	g.AddCard(c1, 3, g.Deck().Name)
+	g.AddCard(c2, 1, g.Deck().Name)
+
+	history := func(query string) (int, game.History) {
+		w := httptest.NewRecorder()
+		s.historyHandler(w, httptest.NewRequest(http.MethodGet, "/history?"+query, nil))
+
+		var h game.History
+		json.NewDecoder(w.Body).Decode(&h)
+		return w.Code, h
+	}
+
+	code, _ := history("room=Test&token=bad")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, h := history("room=test&token=" + url.QueryEscape(g.Token))
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, h.Rounds, 1) {
+		assert.Equal(t, []game.Vote{{PlayerID: 1, Player: "Alex", Card: "3"}, {PlayerID: 2, Player: "Bea", Card: "1"}}, h.Rounds[0].Votes)
+	}
+	assert.Nil(t, h.Insights)
+
+	s.handleRequest(context.Background(), c1, &WsRequest{Action: WsRequestActionInsights, Value: "on", Room: g.Room, Token: g.Token})
+	_, h = history("room=test&token=" + url.QueryEscape(g.Token))
+	assert.Equal(t, []*game.PlayerInsight{
+		{PlayerID: 1, Player: "Alex", Rounds: 1, FromMedian: 1, FromFinal: 1},
+		{PlayerID: 2, Player: "Bea", Rounds: 1, FromMedian: -1, FromFinal: -1},
+	}, h.Insights)
+}
+
+func TestKickRequest(t *testing.T) {
+	s := newStreamServer()
+	g, _ := game.New("Test", "", nil)
+	conn := newWsConn()
+	conn.addr = &addr{"1.2.3.4"}
+	c1, c2 := NewClient(g, conn, 1, "Alex"), NewClient(g, conn, 2, "Bea")
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+
+	request := func(action WsRequestAction, playerID int) {
+		s.handleRequest(context.Background(), c1, &WsRequest{Action: action, PlayerID: playerID, Room: g.Room, Token: g.Token})
+	}
+
+	// nobody can kick or mute themselves, so Bea's vote doesn't reveal the cards
+	request(WsRequestActionMute, 1)
+	request(WsRequestActionKick, 1)
+	assert.Equal(t, 2, g.RegisteredClientsCount())
+	g.AddCard(c2, 1, g.Deck().Name)
+	_, revealed := g.Estimate()
+	assert.False(t, revealed)
+
+	request(WsRequestActionKick, 2)
+	assert.Equal(t, 1, g.RegisteredClientsCount())
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/synacor/sibyl/i18n"
+)
+
+// ErrStreamClosed is returned when reading from or writing to an event stream which has been closed
+var ErrStreamClosed = errors.New("server: event stream closed")
+
+// Some proxies hold on to the start of a response until they have seen enough of it. The stream
+// starts with a comment of this size so that the first events aren't held back.
+const sseStreamPadding = 2048
+
+// safeStreams holds the clients which act separately from their connection, by the ID they act with.
+type safeStreams struct {
+	clients map[string]*Client
+	mutex   *sync.RWMutex
+}
+
+// sseConn is a WsConn for clients which can't use web sockets. Messages are written to the client as
+// Server-Sent Events, while actions arrive as separate POST requests to /sse/action.
+type sseConn struct {
+	w       io.Writer
+	flusher http.Flusher
+	addr    streamAddr
+	done    chan struct{}
+	closed  bool
+	mu      sync.Mutex
+}
+
+// streamAddr is the remote address of a stream.
+type streamAddr string
+
+func (a streamAddr) Network() string {
+	return "tcp"
+}
+
+func (a streamAddr) String() string {
+	return string(a)
+}
+
+func newSSEConn(w http.ResponseWriter, r *http.Request) (*sseConn, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("server: streaming is not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	c := &sseConn{
+		w:       w,
+		flusher: flusher,
+		addr:    streamAddr(r.RemoteAddr),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		select {
+		case <-r.Context().Done():
+			c.Close()
+		case <-c.done:
+		}
+	}()
+
+	return c, c.write(":" + strings.Repeat(" ", sseStreamPadding) + "\n\n")
+}
+
+// write writes raw event data to the stream and flushes it.
+func (c *sseConn) write(s string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrStreamClosed
+	}
+
+	if _, err := io.WriteString(c.w, s); err != nil {
+		return err
+	}
+
+	c.flusher.Flush()
+	return nil
+}
+
+// writeEvent writes an event with the given name and data.
+func (c *sseConn) writeEvent(event, data string) error {
+	var b strings.Builder
+	if event != "" {
+		b.WriteString("event: " + event + "\n")
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	return c.write(b.String())
+}
+
+// Close closes the stream. It is safe to call more than once.
+func (c *sseConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+
+	return nil
+}
+
+// ReadJSON blocks until the stream is closed, since nothing is read from an event stream.
+func (c *sseConn) ReadJSON(v interface{}) error {
+	<-c.done
+	return ErrStreamClosed
+}
+
+func (c *sseConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *sseConn) SetPongHandler(func(appData string) error) {}
+
+func (c *sseConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *sseConn) SetReadLimit(limit int64) {}
+
+func (c *sseConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *sseConn) WriteJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.writeEvent("", string(b))
+}
+
+// WriteMessage writes a ping as a comment, which keeps proxies from timing out the stream, and a
+// close message as a "close" event holding the reason.
+func (c *sseConn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case websocket.PingMessage:
+		return c.write(": ping\n\n")
+	case websocket.CloseMessage:
+		return c.writeEvent("close", closeMessageReason(data))
+	}
+
+	return fmt.Errorf("server: message type %d can't be written to an event stream", messageType)
+}
+
+// sseHandler handles requests to /sse. It is the fallback for clients that can't connect to /ws.
+func (s *Server) sseHandler(w http.ResponseWriter, r *http.Request) {
+	g := s.gameForRequest(r)
+	if g == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	conn, err := newSSEConn(w, r)
+	if err != nil {
+		requestLog(r).Errorf("could not start event stream: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	client := s.newClient(g, conn, r)
+	if g.IsBanned(client.session) {
+		client.logger().Warn("kicked session tried to rejoin room")
+		reason := i18n.T(client.Locale(), bannedReason)
+		conn.WriteJSON(&wsError{reason})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason))
+		conn.Close()
+		return
+	}
+
+	// actions are posted with the stream id, so the client needs it before anything else
+	s.serveStream(client, func(id string) error {
+		return conn.writeEvent("id", id)
+	})
+}
+
+// serveStream serves a client whose actions arrive separately from its connection, such as an event
+// stream. The client is given an ID by sendID, which its actions must then carry. It returns once the
+// client has left.
+func (s *Server) serveStream(client *Client, sendID func(id string) error) {
+	id, err := generateSession()
+	if err != nil {
+		client.logger().Errorf("could not generate stream id: %v", err)
+		client.Conn.Close()
+		return
+	}
+
+	if err := sendID(id); err != nil {
+		client.Conn.Close()
+		return
+	}
+
+	s.safeStreams.mutex.Lock()
+	s.safeStreams.clients[id] = client
+	s.safeStreams.mutex.Unlock()
+
+	client.Game.RegisterClient(client)
+
+	written := make(chan struct{})
+	go func() {
+		client.WritePump(s)
+		close(written)
+	}()
+	client.ReadPump(s)
+
+	s.safeStreams.mutex.Lock()
+	delete(s.safeStreams.clients, id)
+	s.safeStreams.mutex.Unlock()
+
+	client.Game.UnregisterClient(client)
+	client.CloseChannel()
+
+	// nothing may be written to the stream once it has been served
+	<-written
+}
+
+// streamClient returns the client of a stream with the given ID.
+func (s *Server) streamClient(id string) (*Client, bool) {
+	s.safeStreams.mutex.RLock()
+	defer s.safeStreams.mutex.RUnlock()
+
+	client, found := s.safeStreams.clients[id]
+	return client, found
+}
+
+// sseActionHandler handles requests to POST /sse/action, which carry the actions of a client connected to /sse.
+func (s *Server) sseActionHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.ToUpper(r.Method) != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	client, found := s.streamClient(r.FormValue("id"))
+	if !found || client.session != requestSession(r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req WsRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, readLimit)).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	s.handleRequest(r.Context(), client, &req)
+	w.WriteHeader(http.StatusNoContent)
+}
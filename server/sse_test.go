@@ -0,0 +1,186 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/game"
+)
+
+func TestSSEConn(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/sse", nil)
+	c, err := newSSEConn(w, r)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, r.RemoteAddr, c.RemoteAddr().String())
+	w.Body.Reset()
+
+	assert.NoError(t, c.WriteJSON(map[string]string{"topic": "Topic"}))
+	assert.Equal(t, "data: {\"topic\":\"Topic\"}\n\n", w.Body.String())
+	w.Body.Reset()
+
+	assert.NoError(t, c.WriteMessage(websocket.PingMessage, []byte{}))
+	assert.Equal(t, ": ping\n\n", w.Body.String())
+	w.Body.Reset()
+
+	assert.NoError(t, c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Bye")))
+	assert.Equal(t, "event: close\ndata: Bye\n\n", w.Body.String())
+	w.Body.Reset()
+
+	read := make(chan error)
+	go func() {
+		read <- c.ReadJSON(nil)
+	}()
+
+	c.Close()
+	c.Close()
+	assert.Equal(t, ErrStreamClosed, <-read)
+	assert.Equal(t, ErrStreamClosed, c.WriteJSON("Test"))
+	assert.Equal(t, "", w.Body.String())
+}
+
+func TestSSEHandler(t *testing.T) {
+	s := newStreamServer()
+	g, _ := game.New("Test", "", nil)
+	s.safeGames.games["test"] = g
+
+	ts := httptest.NewServer(s.ServeMux())
+	defer ts.Close()
+
+	// a wrong token doesn't start a stream
+	resp, err := http.Get(ts.URL + "/sse?room=Test&token=wrong")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Get(ts.URL + "/sse?room=Test&token=" + url.QueryEscape(g.Token) + "&username=One")
+	assert.NoError(t, err)
+	events := readEvents(resp)
+
+	e := <-events
+	assert.Equal(t, "id", e.event)
+	id := e.data
+
+	var u map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte((<-events).data), &u))
+	assert.Equal(t, "One", u["username"])
+	assert.Equal(t, 1, g.RegisteredClientsCount())
+
+	// actions are posted separately
+	action := `{"action":"topic","room":"Test","token":"` + g.Token + `","value":"New Topic"}`
+	post, err := http.Post(ts.URL+"/sse/action?id="+url.QueryEscape(id), "application/json", strings.NewReader(action))
+	assert.NoError(t, err)
+	post.Body.Close()
+	assert.Equal(t, http.StatusNoContent, post.StatusCode)
+
+	assert.NoError(t, json.Unmarshal([]byte((<-events).data), &u))
+	assert.Equal(t, "New Topic", u["topic"])
+
+	post, err = http.Post(ts.URL+"/sse/action?id=unknown", "application/json", strings.NewReader(action))
+	assert.NoError(t, err)
+	post.Body.Close()
+	assert.Equal(t, http.StatusNotFound, post.StatusCode)
+
+	post, err = http.Get(ts.URL + "/sse/action?id=" + url.QueryEscape(id))
+	assert.NoError(t, err)
+	post.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, post.StatusCode)
+
+	// leaving the stream leaves the game
+	resp.Body.Close()
+	for i := 0; i < 100 && g.RegisteredClientsCount() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 0, g.RegisteredClientsCount())
+
+	s.safeStreams.mutex.RLock()
+	assert.Equal(t, 0, len(s.safeStreams.clients))
+	s.safeStreams.mutex.RUnlock()
+}
+
+func TestSSEHandlerKick(t *testing.T) {
+	s := newStreamServer()
+	g, _ := game.New("Test", "", nil)
+	s.safeGames.games["test"] = g
+
+	ts := httptest.NewServer(s.ServeMux())
+	defer ts.Close()
+
+	// the player is told why in their own language
+	r, _ := http.NewRequest(http.MethodGet, ts.URL+"/sse?room=Test&token="+url.QueryEscape(g.Token), nil)
+	r.Header.Set("Accept-Language", "de-DE, de;q=0.9")
+	resp, err := http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	events := readEvents(resp)
+	<-events
+	<-events
+
+	g.Kick(1, kickReason, 0)
+
+	var last sseEvent
+	for e := range events {
+		last = e
+	}
+	assert.Equal(t, sseEvent{"close", "Du wurdest aus dem Raum entfernt."}, last)
+}
+
+// newStreamServer returns a server with just enough set up to serve event streams.
+func newStreamServer() *Server {
+	return &Server{
+		safeGames: &safeGames{
+			games:    make(map[string]*game.Game),
+			mutex:    &sync.RWMutex{},
+			sessions: make(map[*game.Game]int),
+		},
+		safeStreams: &safeStreams{
+			clients: make(map[string]*Client),
+			mutex:   &sync.RWMutex{},
+		},
+		safeSettings: &safeSettings{settings: settings{destroyDelay: game.DefaultDestroyDelay}},
+		limits:       newClientLimits(DefaultIdleAfter, DefaultChatRateLimit),
+		health:       &health{checks: make(map[string]func() error)},
+	}
+}
+
+type sseEvent struct {
+	event string
+	data  string
+}
+
+// readEvents reads the events of a stream until it ends.
+func readEvents(resp *http.Response) <-chan sseEvent {
+	events := make(chan sseEvent)
+	go func() {
+		defer close(events)
+
+		var e sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if e.data != "" || e.event != "" {
+					events <- e
+				}
+				e = sseEvent{}
+			case strings.HasPrefix(line, "event: "):
+				e.event = line[len("event: "):]
+			case strings.HasPrefix(line, "data: "):
+				e.data = line[len("data: "):]
+			}
+		}
+	}()
+
+	return events
+}
@@ -0,0 +1,307 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+	"github.com/synacor/sibyl/i18n"
+	"github.com/synacor/sibyl/roomstate"
+	"github.com/synacor/sibyl/tui"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// SSHServer returns an SSH server which drops each session into the room named by its user, such as
+// `ssh -p 2222 Planning@sibyl.example.com`. The session's command, if any, is the name to join with.
+func (s *Server) SSHServer() (*ssh.Server, error) {
+	signer, err := loadHostKey(s.sshHostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &ssh.Server{
+		Handler: s.sshHandler,
+
+		// keys only tell players apart, so any key is let in, and so is a client without one, with a
+		// keyboard-interactive login that asks nothing
+		PublicKeyHandler: func(ctx ssh.Context, key ssh.PublicKey) bool {
+			return true
+		},
+		KeyboardInteractiveHandler: func(ctx ssh.Context, challenger gossh.KeyboardInteractiveChallenge) bool {
+			return true
+		},
+	}
+	srv.AddHostKey(signer)
+
+	return srv, nil
+}
+
+// loadHostKey loads the host key from the file at path, or generates one. A generated key is saved to
+// path, unless path is empty, in which case clients will see a new host key whenever sibyl restarts.
+func loadHostKey(path string) (gossh.Signer, error) {
+	if path != "" {
+		if b, err := os.ReadFile(path); err == nil {
+			return gossh.ParsePrivateKey(b)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		log.Warn("no ssh_host_key configured, using a temporary host key")
+		return signer, nil
+	}
+
+	block, err := gossh.MarshalPrivateKey(key, "sibyl")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{"path": path}).Info("generated ssh host key")
+	return signer, nil
+}
+
+// sshHandler runs a session: it joins the room as a player and shows it as a text UI.
+func (s *Server) sshHandler(sess ssh.Session) {
+	room := sess.User()
+	g := s.getGameByRoom(room)
+	if g == nil {
+		fmt.Fprintf(sess, "The room %q does not exist. Create it in your browser first.\n", room)
+		sess.Exit(1)
+		return
+	}
+
+	if _, _, isPty := sess.Pty(); !isPty {
+		fmt.Fprintln(sess, "Sibyl needs a terminal. Try ssh -t.")
+		sess.Exit(1)
+		return
+	}
+
+	var username string
+	if cmd := sess.Command(); len(cmd) > 0 {
+		username = cmd[0]
+	}
+
+	conn := newSSHConn(sess.RemoteAddr())
+	c := s.configureClient(NewClient(g, conn, g.NextClientID(), username))
+	c.session = sshSession(sess)
+	if g.IsBanned(c.session) {
+		c.logger().Warn("kicked session tried to rejoin room")
+		fmt.Fprintf(sess, "%s\n", i18n.T(c.Locale(), bannedReason))
+		sess.Exit(1)
+		return
+	}
+
+	g.RegisterClient(c)
+
+	written := make(chan struct{})
+	go func() {
+		c.WritePump(s)
+		close(written)
+	}()
+
+	err := tui.Run(g.Room, &sshRoom{s: s, c: c}, conn.updates, sess, sess)
+
+	g.UnregisterClient(c)
+	c.CloseChannel()
+	<-written
+	conn.Close()
+
+	if err != nil {
+		return
+	}
+
+	if reason := conn.Reason(); reason != "" {
+		fmt.Fprintf(sess, "\r\n%s\r\n", reason)
+		sess.Exit(1)
+		return
+	}
+
+	sess.Exit(0)
+}
+
+// sshSession returns the session of an SSH connection, which is the fingerprint of the player's key.
+// A player who logged in without a key only has the connection's own session ID, so they can be
+// removed, but not kept out.
+func sshSession(sess ssh.Session) string {
+	if key := sess.PublicKey(); key != nil {
+		return "ssh:" + gossh.FingerprintSHA256(key)
+	}
+
+	return "ssh:" + sess.Context().SessionID()
+}
+
+// sshRoom carries out the actions of a session's player, through the same dispatch as a web socket.
+type sshRoom struct {
+	s *Server
+	c *Client
+}
+
+func (r *sshRoom) do(action WsRequestAction, card int, deck string) error {
+	r.s.HandleWsRequest(r.c, &WsRequest{
+		Action: action,
+		Card:   card,
+		Deck:   deck,
+		Room:   r.c.Game.Room,
+		Token:  r.c.Game.Token,
+	})
+
+	return nil
+}
+
+func (r *sshRoom) SelectDeckCard(card int, deck string) error {
+	return r.do(WsRequestActionSelectCard, card, deck)
+}
+
+func (r *sshRoom) Reveal() error {
+	return r.do(WsRequestActionReveal, 0, "")
+}
+
+func (r *sshRoom) Reset() error {
+	return r.do(WsRequestActionReset, 0, "")
+}
+
+func (r *sshRoom) Revote() error {
+	return r.do(WsRequestActionRevote, 0, "")
+}
+
+// sshConn is a WsConn for SSH sessions. Game updates are decoded into the state shown by the text UI,
+// while actions are carried out directly by sshRoom.
+type sshConn struct {
+	addr    net.Addr
+	updates chan roomstate.State
+	done    chan struct{}
+	reason  string
+	closed  bool
+	mu      sync.Mutex
+}
+
+func newSSHConn(addr net.Addr) *sshConn {
+	return &sshConn{
+		addr:    addr,
+		updates: make(chan roomstate.State, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// Reason returns why the session was disconnected by the game, if it was.
+func (c *sshConn) Reason() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.reason
+}
+
+// Close stops the updates. It is safe to call more than once.
+func (c *sshConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+		close(c.updates)
+	}
+
+	return nil
+}
+
+// ReadJSON blocks until the session ends, since actions don't arrive through the connection.
+func (c *sshConn) ReadJSON(v interface{}) error {
+	<-c.done
+	return io.EOF
+}
+
+func (c *sshConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *sshConn) SetPongHandler(func(appData string) error) {}
+
+func (c *sshConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *sshConn) SetReadLimit(limit int64) {}
+
+func (c *sshConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// WriteJSON decodes a payload from the game. Updates replace any update the text UI hasn't shown
+// yet, and errors are kept as the reason the session ends. Chat messages aren't shown.
+func (c *sshConn) WriteJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var p struct {
+		roomstate.State
+		Message json.RawMessage `json:"message"`
+		Error   string          `json:"error"`
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrStreamClosed
+	}
+
+	switch {
+	case p.Error != "":
+		c.reason = p.Error
+	case p.Message != nil:
+	default:
+		select {
+		case <-c.updates:
+		default:
+		}
+		c.updates <- p.State
+	}
+
+	return nil
+}
+
+// WriteMessage ends the session on a close message, keeping its reason. Pings are ignored.
+func (c *sshConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != websocket.CloseMessage {
+		return nil
+	}
+
+	c.mu.Lock()
+	if reason := closeMessageReason(data); reason != "" {
+		c.reason = reason
+	}
+	c.mu.Unlock()
+
+	return c.Close()
+}
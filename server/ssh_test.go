@@ -0,0 +1,208 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/game"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestLoadHostKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "host_key")
+
+	generated, err := loadHostKey(path)
+	assert.NoError(t, err)
+
+	loaded, err := loadHostKey(path)
+	assert.NoError(t, err)
+	assert.Equal(t, generated.PublicKey().Marshal(), loaded.PublicKey().Marshal())
+
+	temporary, err := loadHostKey("")
+	assert.NoError(t, err)
+	assert.NotEqual(t, generated.PublicKey().Marshal(), temporary.PublicKey().Marshal())
+}
+
+func TestSSHHandler(t *testing.T) {
+	s := newStreamServer()
+	g, _ := game.New("Test", "", nil)
+	s.safeGames.games["test"] = g
+	addr := serveSSHTest(t, s)
+
+	// a room that doesn't exist
+	sess, out := newSSHTestSession(t, addr, "Missing")
+	assert.NoError(t, sess.Start(""))
+	err := sess.Wait()
+	assert.Equal(t, 1, err.(*gossh.ExitError).ExitStatus())
+	assert.Contains(t, out.String(), "does not exist")
+
+	sess, out = newSSHTestSession(t, addr, "Test")
+	in, _ := sess.StdinPipe()
+	assert.NoError(t, sess.Start("Pat"))
+
+	waitForOutput(t, out, "Pat (you)")
+	assert.Equal(t, 1, g.RegisteredClientsCount())
+
+	// the only player voting reveals the cards
+	in.Write([]byte(" "))
+	waitForOutput(t, out, "Results")
+
+	in.Write([]byte("q"))
+	assert.NoError(t, sess.Wait())
+	assert.Equal(t, 0, g.RegisteredClientsCount())
+}
+
+func TestSSHHandlerKick(t *testing.T) {
+	s := newStreamServer()
+	g, _ := game.New("Test", "", nil)
+	s.safeGames.games["test"] = g
+	addr := serveSSHTest(t, s)
+
+	sess, out := newSSHTestSession(t, addr, "Test")
+	sess.StdinPipe()
+	assert.NoError(t, sess.Start("Pat"))
+	waitForOutput(t, out, "Pat (you)")
+
+	g.Kick(1, kickReason, 0)
+	err := sess.Wait()
+	assert.Equal(t, 1, err.(*gossh.ExitError).ExitStatus())
+	assert.Contains(t, out.String(), "You have been removed from the room.")
+}
+
+func TestSSHHandlerBanned(t *testing.T) {
+	s := newStreamServer()
+	g, _ := game.New("Test", "", nil)
+	s.safeGames.games["test"] = g
+	addr := serveSSHTest(t, s)
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	signer, _ := gossh.NewSignerFromKey(key)
+	withKey := gossh.PublicKeys(signer)
+
+	sess, out := newSSHTestSession(t, addr, "Test", withKey)
+	sess.StdinPipe()
+	assert.NoError(t, sess.Start("Pat"))
+	waitForOutput(t, out, "Pat (you)")
+
+	g.Kick(1, kickReason, time.Minute)
+	assert.Error(t, sess.Wait())
+	assert.True(t, g.IsBanned("ssh:"+gossh.FingerprintSHA256(signer.PublicKey())))
+
+	// rejoining with the same key is refused
+	sess, out = newSSHTestSession(t, addr, "Test", withKey)
+	assert.NoError(t, sess.Start("Pat"))
+	err := sess.Wait()
+	assert.Equal(t, 1, err.(*gossh.ExitError).ExitStatus())
+	assert.Contains(t, out.String(), "You were removed from this room")
+	assert.Equal(t, 0, g.RegisteredClientsCount())
+
+	// while someone else from the same host may still join
+	sess, out = newSSHTestSession(t, addr, "Test")
+	sess.StdinPipe()
+	assert.NoError(t, sess.Start("Bea"))
+	waitForOutput(t, out, "Bea (you)")
+	assert.Equal(t, 1, g.RegisteredClientsCount())
+}
+
+func TestSSHRoomSelectDeckCard(t *testing.T) {
+	s := newStreamServer()
+	g, _ := game.New("Test", "", nil)
+	conn := newSSHConn(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	c := NewClient(g, conn, 1, "Pat")
+	g.RegisterClient(c)
+	r := &sshRoom{s: s, c: c}
+
+	// a card of the deck the text UI showed, which the room has since switched from, isn't played
+	assert.NoError(t, r.SelectDeckCard(1, "T-Shirt Sizes"))
+	_, revealed := g.Estimate()
+	assert.False(t, revealed)
+
+	assert.NoError(t, r.SelectDeckCard(1, g.Deck().Name))
+	_, revealed = g.Estimate()
+	assert.True(t, revealed)
+}
+
+func serveSSHTest(t *testing.T, s *Server) string {
+	srv, err := s.SSHServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(func() { srv.Close() })
+
+	return lis.Addr().String()
+}
+
+// newSSHTestSession starts a session with a terminal in room. Without auth, it logs in without a key.
+func newSSHTestSession(t *testing.T, addr, room string, auth ...gossh.AuthMethod) (*gossh.Session, *syncBuffer) {
+	if len(auth) == 0 {
+		auth = []gossh.AuthMethod{gossh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+			return nil, nil
+		})}
+	}
+
+	conn, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            room,
+		Auth:            auth,
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	sess, err := conn.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sess.RequestPty("xterm", 40, 80, gossh.TerminalModes{}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &syncBuffer{}
+	sess.Stdout = out
+	return sess, out
+}
+
+func waitForOutput(t *testing.T, out *syncBuffer, s string) {
+	for i := 0; i < 100; i++ {
+		if strings.Contains(out.String(), s) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("%q was never shown", s)
+}
+
+// syncBuffer is a bytes.Buffer which may be written and read from different goroutines.
+type syncBuffer struct {
+	b  bytes.Buffer
+	mu sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
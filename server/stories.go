@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synacor/sibyl/game"
+	"github.com/synacor/sibyl/store"
+)
+
+// actualRequest is the body of PUT /admin/stories/<key>
+type actualRequest struct {
+	Actual *float64 `json:"actual"`
+}
+
+// commitEstimate commits the estimate of the revealed round to the story with the key, and tells the
+// room. The team of the story is the room it was estimated in.
+func (s *Server) commitEstimate(g *game.Game, c *Client, key string) {
+	l := c.logger().WithField("story", key)
+	if s.store == nil {
+		l.Warn("client committed an estimate, but there is no data_dir to keep it in")
+		return
+	}
+
+	e, found := g.Estimate()
+	if !found {
+		l.Warn("client committed an estimate before one was revealed")
+		return
+	}
+
+	story, err := s.store.CommitEstimate(store.Story{
+		Key:      key,
+		Team:     g.Room,
+		Room:     g.Room,
+		Topic:    e.Topic,
+		Deck:     e.Deck,
+		Estimate: e.Card,
+	})
+	if err == store.ErrInvalidStoryKey {
+		l.Warn("client committed an estimate to an invalid story key")
+		return
+	} else if err != nil {
+		l.Errorf("could not save the stories: %v", err)
+	}
+
+	l.WithField("estimate", story.Estimate).Info("committed estimate")
+	g.AnnounceCommit(c, story.Key, story.Estimate)
+}
+
+// requireStore only lets requests through to h if there is a data_dir to keep stories and teams in.
+func (s *Server) requireStore(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.store == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// adminStoriesHandler handles requests to GET /admin/stories
+func (s *Server) adminStoriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.store.Stories())
+}
+
+// adminStoryHandler handles requests to GET /admin/stories/<key>, and to PUT /admin/stories/<key>,
+// which sets the actual effort of the story from a body such as {"actual": 8}.
+func (s *Server) adminStoryHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/admin/stories/")
+
+	var story store.Story
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		var found bool
+		if story, found = s.store.Story(key); !found {
+			err = store.ErrStoryNotFound
+		}
+	case http.MethodPut:
+		var req actualRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, readLimit)).Decode(&req); err != nil || req.Actual == nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		story, err = s.store.SetActual(key, *req.Actual)
+		if err == nil {
+			requestLog(r).WithFields(log.Fields{"story": key, "actual": *req.Actual}).Info("set actual effort")
+		}
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch err {
+	case nil:
+	case store.ErrStoryNotFound:
+		http.NotFound(w, r)
+		return
+	case store.ErrInvalidActual:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		// the actual effort is kept, and saved with the next change
+		requestLog(r).Errorf("could not save the stories: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(story)
+}
+
+// adminAccuracyHandler handles requests to GET /admin/accuracy, which compares the estimates of the
+// stories with their actual effort, per deck and per team.
+func (s *Server) adminAccuracyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.store.Report())
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/game"
+	"github.com/synacor/sibyl/store"
+)
+
+func TestStories(t *testing.T) {
+	s := newStreamServer()
+	s.safeSettings.settings.adminToken = "secret"
+	g, _ := game.New("Platform", "", nil)
+	s.safeGames.games["platform"] = g
+	conn := newWsConn()
+	conn.addr = &addr{"1.2.3.4"}
+	c1, c2 := NewClient(g, conn, 1, "Alex"), NewClient(g, conn, 2, "Bea")
+	g.RegisterClient(c1)
+	g.RegisterClient(c2)
+	mux := s.ServeMux()
+
+	adminRequest := func(method, path string, body io.Reader) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, body)
+		r.Header.Set("Authorization", "Bearer secret")
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	commit := func(key string) {
+		s.handleRequest(context.Background(), c1, &WsRequest{Action: WsRequestActionCommit, Value: key, Room: g.Room, Token: g.Token})
+	}
+
+	// without a data_dir, there are no stories
+	assert.Equal(t, http.StatusNotFound, adminRequest(http.MethodGet, "/admin/stories", nil).Code)
+	g.AddCard(c1, 3, g.Deck().Name)
+	g.AddCard(c2, 5, g.Deck().Name)
+	commit("PLAT-1")
+
+	var err error
+	s.store, err = store.Open(t.TempDir())
+	if !assert.NoError(t, err) {
+		return
+	}
+	commit("not a key")
+	commit("PLAT-1")
+
+	w := adminRequest(http.MethodGet, "/admin/stories", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stories []store.Story
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stories))
+	if assert.Len(t, stories, 1) {
+		assert.Equal(t, "PLAT-1", stories[0].Key)
+		assert.Equal(t, "Platform", stories[0].Team)
+		assert.Equal(t, "Platform Estimation Session", stories[0].Topic)
+		assert.Equal(t, "8", stories[0].Estimate)
+	}
+
+	assert.Equal(t, http.StatusNotFound, adminRequest(http.MethodGet, "/admin/stories/PLAT-2", nil).Code)
+	assert.Equal(t, http.StatusNotFound, adminRequest(http.MethodPut, "/admin/stories/PLAT-2", strings.NewReader(`{"actual": 5}`)).Code)
+	assert.Equal(t, http.StatusBadRequest, adminRequest(http.MethodPut, "/admin/stories/PLAT-1", strings.NewReader(`{}`)).Code)
+	assert.Equal(t, http.StatusBadRequest, adminRequest(http.MethodPut, "/admin/stories/PLAT-1", strings.NewReader(`{"actual": -1}`)).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, adminRequest(http.MethodDelete, "/admin/stories/PLAT-1", nil).Code)
+
+	w = adminRequest(http.MethodPut, "/admin/stories/PLAT-1", strings.NewReader(`{"actual": 13}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = adminRequest(http.MethodGet, "/admin/stories/PLAT-1", nil)
+	var story store.Story
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &story))
+	assert.Equal(t, 13.0, *story.Actual)
+
+	w = adminRequest(http.MethodGet, "/admin/accuracy", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report store.Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	if assert.Len(t, report.Teams, 1) {
+		assert.Equal(t, "Platform", report.Teams[0].Team)
+		assert.Equal(t, []store.CalibrationPoint{{Estimate: "8", Stories: 1, Mean: 13, Median: 13, Min: 13, Max: 13}}, report.Teams[0].Points)
+	}
+}
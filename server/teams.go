@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/synacor/sibyl/game"
+	"github.com/synacor/sibyl/store"
+)
+
+// team returns the team whose room it is, if there's a data_dir and the room is a team's.
+func (s *Server) team(room string) (store.Team, bool) {
+	if s.store == nil {
+		return store.Team{}, false
+	}
+
+	return s.store.Team(room)
+}
+
+// startTeamSession starts a session of the team whose room the game is, if it's a team's, and keeps
+// each round revealed in it.
+func (s *Server) startTeamSession(g *game.Game) {
+	t, found := s.team(g.Room)
+	if !found {
+		return
+	}
+
+	s.safeGames.mutex.Lock()
+	_, started := s.safeGames.sessions[g]
+	s.safeGames.mutex.Unlock()
+	if started {
+		return
+	}
+
+	l := log.WithFields(log.Fields{"room": g.Room, "team": t.Name})
+	id, err := s.store.StartSession(t.Name)
+	if err == store.ErrTeamNotFound {
+		return
+	} else if err != nil {
+		// the session is kept, and saved with the next change
+		l.Errorf("could not save the team: %v", err)
+	}
+	s.safeGames.mutex.Lock()
+	s.safeGames.sessions[g] = id
+	s.safeGames.mutex.Unlock()
+	l.WithField("session", id).Info("team session started")
+
+	g.SetOnReveal(func(r game.HistoryRound) {
+		if err := s.store.AddRound(t.Name, id, r); err != nil && err != store.ErrTeamNotFound {
+			l.Errorf("could not save the team: %v", err)
+		}
+	})
+}
+
+// endTeamSession ends the session of the team whose room the game was, if it was a team's.
+func (s *Server) endTeamSession(g *game.Game) {
+	s.safeGames.mutex.Lock()
+	id, found := s.safeGames.sessions[g]
+	delete(s.safeGames.sessions, g)
+	s.safeGames.mutex.Unlock()
+
+	if !found {
+		return
+	}
+
+	l := log.WithFields(log.Fields{"room": g.Room, "session": id})
+	if err := s.store.EndSession(g.Room, id); err != nil && err != store.ErrTeamNotFound {
+		l.Errorf("could not save the team: %v", err)
+		return
+	}
+	l.Info("team session ended")
+}
+
+// adminTeamsHandler handles requests to GET /admin/teams, which lists the teams without their sessions.
+func (s *Server) adminTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	teams := s.store.Teams()
+	for i := range teams {
+		teams[i].Sessions = nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(teams)
+}
+
+// adminTeamHandler handles requests to GET /admin/teams/<name>, which includes the team's sessions, to
+// PUT /admin/teams/<name>, which creates or configures the team from a body such as
+// {"roster": ["Alex", "Bea"], "deck": "Fibonacci"}, and to DELETE /admin/teams/<name>. The team's
+// room, if it's open, picks up the change right away.
+func (s *Server) adminTeamHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/admin/teams/")
+
+	var team store.Team
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		var found bool
+		if team, found = s.store.Team(name); !found {
+			err = store.ErrTeamNotFound
+		}
+	case http.MethodPut:
+		if err := json.NewDecoder(io.LimitReader(r.Body, readLimit)).Decode(&team); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		team.Name = name
+
+		team, err = s.store.SaveTeam(team)
+		if errors.Is(err, store.ErrInvalidTeam) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requestLog(r).WithField("team", team.Name).Info("saved team")
+	case http.MethodDelete:
+		if err = s.store.DeleteTeam(name); err == nil {
+			requestLog(r).WithField("team", name).Info("deleted team")
+		}
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut+", "+http.MethodDelete)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// a team which could not be deleted is still there, so its room keeps its session. A team which
+	// could not be saved is kept all the same, and its room picks up the change.
+	deleted := r.Method == http.MethodDelete && err == nil
+	if (r.Method == http.MethodPut && err != store.ErrTeamNotFound) || deleted {
+		if g := s.getGameByRoom(name); g != nil {
+			if r.Method == http.MethodDelete {
+				s.safeGames.mutex.Lock()
+				delete(s.safeGames.sessions, g)
+				s.safeGames.mutex.Unlock()
+				g.SetOnReveal(nil)
+			} else {
+				s.startTeamSession(g)
+			}
+			s.configureGame(g, s.settings())
+		}
+	}
+
+	switch {
+	case err == nil:
+	case err == store.ErrTeamNotFound:
+		http.NotFound(w, r)
+		return
+	case r.Method == http.MethodDelete:
+		// the team is still there, as if it had never been deleted
+		requestLog(r).Errorf("could not delete the team: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	default:
+		// the team is kept, and saved with the next change
+		requestLog(r).Errorf("could not save the team: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(team)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/game"
+	"github.com/synacor/sibyl/store"
+)
+
+func TestTeams(t *testing.T) {
+	viper.Set("data_dir", t.TempDir())
+	viper.Set("admin_token", "secret")
+	defer func() {
+		viper.Set("data_dir", nil)
+		viper.Set("admin_token", nil)
+	}()
+
+	s, err := New(os.DirFS(".."))
+	if !assert.NoError(t, err) {
+		return
+	}
+	mux := s.ServeMux()
+
+	adminRequest := func(method, path string, body io.Reader) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, body)
+		r.Header.Set("Authorization", "Bearer secret")
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	// a room which isn't a team's doesn't exist until it's created
+	code, _ := get(mux, "/r/Platform")
+	assert.Equal(t, http.StatusSeeOther, code)
+
+	assert.Equal(t, http.StatusBadRequest, adminRequest(http.MethodPut, "/admin/teams/Platform", strings.NewReader(`{"deck": "Tarot"}`)).Code)
+	assert.Equal(t, http.StatusBadRequest, adminRequest(http.MethodPut, "/admin/teams/Platform", strings.NewReader(`{`)).Code)
+	w := adminRequest(http.MethodPut, "/admin/teams/Platform", strings.NewReader(`{"roster": ["Alex", "Bea"], "deck": "Fibonacci"}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// the team's room opens with its configuration
+	code, _ = get(mux, "/r/platform")
+	assert.Equal(t, http.StatusOK, code)
+	g := s.getGameByRoom("Platform")
+	if !assert.NotNil(t, g) {
+		return
+	}
+	assert.Equal(t, "Platform", g.Room)
+	assert.Equal(t, "Fibonacci", g.Deck().Name)
+
+	// the team turns insights on for its open room, and off again
+	adminRequest(http.MethodPut, "/admin/teams/Platform", strings.NewReader(`{"roster": ["Alex", "Bea"], "deck": "Fibonacci", "insights": true}`))
+	assert.NotNil(t, g.History().Insights)
+	adminRequest(http.MethodPut, "/admin/teams/Platform", strings.NewReader(`{"roster": ["Alex", "Bea"], "deck": "Fibonacci"}`))
+	assert.Nil(t, g.History().Insights)
+
+	// Cy isn't on the roster, and Bea isn't here, so the cards are revealed once Alex has voted
+	conn := newWsConn()
+	conn.addr = &addr{"1.2.3.4"}
+	alex, cy := NewClient(g, conn, 1, "alex"), NewClient(g, conn, 2, "Cy")
+	g.RegisterClient(alex)
+	g.RegisterClient(cy)
+	g.AddCard(alex, 3, g.Deck().Name)
+	assert.Len(t, g.History().Rounds, 1)
+
+	s.endTeamSession(g)
+
+	var team store.Team
+	w = adminRequest(http.MethodGet, "/admin/teams/platform", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &team))
+	if assert.Len(t, team.Sessions, 1) {
+		assert.NotNil(t, team.Sessions[0].Ended)
+		if assert.Len(t, team.Sessions[0].Rounds, 1) {
+			assert.Equal(t, []game.Vote{{PlayerID: 1, Player: "alex", Card: "3"}}, team.Sessions[0].Rounds[0].Votes)
+		}
+	}
+
+	var teams []store.Team
+	w = adminRequest(http.MethodGet, "/admin/teams", nil)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &teams))
+	if assert.Len(t, teams, 1) {
+		assert.Equal(t, "Platform", teams[0].Name)
+		assert.Nil(t, teams[0].Sessions)
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, adminRequest(http.MethodPost, "/admin/teams/Platform", nil).Code)
+	assert.Equal(t, http.StatusNoContent, adminRequest(http.MethodDelete, "/admin/teams/Platform", nil).Code)
+	assert.Equal(t, http.StatusNotFound, adminRequest(http.MethodDelete, "/admin/teams/Platform", nil).Code)
+	assert.Equal(t, http.StatusNotFound, adminRequest(http.MethodGet, "/admin/teams/Platform", nil).Code)
+}
+
+func TestDeleteTeamError(t *testing.T) {
+	dir := t.TempDir()
+	viper.Set("data_dir", dir)
+	viper.Set("admin_token", "secret")
+	defer func() {
+		viper.Set("data_dir", nil)
+		viper.Set("admin_token", nil)
+	}()
+
+	s, err := New(os.DirFS(".."))
+	if !assert.NoError(t, err) {
+		return
+	}
+	mux := s.ServeMux()
+
+	adminRequest := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(`{"insights": true}`))
+		r.Header.Set("Authorization", "Bearer secret")
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, adminRequest(http.MethodPut, "/admin/teams/Platform").Code)
+	get(mux, "/r/Platform")
+	g := s.getGameByRoom("Platform")
+	if !assert.NotNil(t, g) {
+		return
+	}
+
+	// a directory in place of the team's file can't be removed
+	file := filepath.Join(dir, "teams", "platform.json")
+	assert.NoError(t, os.Remove(file))
+	assert.NoError(t, os.MkdirAll(filepath.Join(file, "keep"), 0755))
+
+	assert.Equal(t, http.StatusInternalServerError, adminRequest(http.MethodDelete, "/admin/teams/Platform").Code)
+	s.safeGames.mutex.Lock()
+	_, started := s.safeGames.sessions[g]
+	s.safeGames.mutex.Unlock()
+	assert.True(t, started)
+	assert.NotNil(t, g.History().Insights)
+	assert.Equal(t, http.StatusOK, adminRequest(http.MethodGet, "/admin/teams/Platform").Code)
+}
@@ -0,0 +1,14 @@
+package server
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of this package
+const instrumentationName = "github.com/synacor/sibyl/server"
+
+// tracer returns the tracer of this package, from the tracer provider in use.
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
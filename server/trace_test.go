@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/game"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans records the spans ended for the rest of a test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	return sr
+}
+
+// spansNamed returns the spans ended with the name.
+func spansNamed(sr *tracetest.SpanRecorder, name string) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, s := range sr.Ended() {
+		if s.Name() == name {
+			spans = append(spans, s)
+		}
+	}
+
+	return spans
+}
+
+func TestHandleRequestTrace(t *testing.T) {
+	sr := recordSpans(t)
+
+	s := newStreamServer()
+	g, _ := game.New("Test", "", nil)
+	conn := newWsConn()
+	conn.addr = &addr{"1.2.3.4"}
+	c := NewClient(g, conn, 1, "")
+	g.RegisterClient(c)
+	c.dequeue()
+
+	_, connSpan := otel.Tracer("test").Start(context.Background(), "GET /ws")
+	connSpan.End()
+	c.connSpan = connSpan.SpanContext()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "POST /events/action")
+	s.handleRequest(ctx, c, &WsRequest{Action: WsRequestActionReveal, Room: g.Room, Token: g.Token})
+	parent.End()
+
+	actions := spansNamed(sr, "action reveal")
+	if !assert.Len(t, actions, 1) {
+		return
+	}
+	action := actions[0]
+	assert.Equal(t, parent.SpanContext().SpanID(), action.Parent().SpanID())
+	assert.Contains(t, action.Attributes(), attribute.String("sibyl.room", "Test"))
+	assert.Contains(t, action.Attributes(), attribute.Int("sibyl.client_id", 1))
+	if assert.Len(t, action.Links(), 1) {
+		assert.Equal(t, connSpan.SpanContext(), action.Links()[0].SpanContext)
+	}
+
+	reveal := spansNamed(sr, "game.Reveal")
+	if assert.Len(t, reveal, 1) {
+		assert.Equal(t, action.SpanContext().SpanID(), reveal[0].Parent().SpanID())
+	}
+
+	// writing the update is part of the trace, once the client gets to it
+	messages, _ := c.dequeue()
+	assert.Len(t, messages, 1)
+	for _, m := range messages {
+		assert.NoError(t, c.write(m))
+	}
+
+	writes := spansNamed(sr, "client.write")
+	broadcast := spansNamed(sr, "game.broadcast")
+	if assert.Len(t, writes, 1) && assert.NotEmpty(t, broadcast) {
+		assert.Equal(t, broadcast[len(broadcast)-1].SpanContext().SpanID(), writes[0].Parent().SpanID())
+		assert.Equal(t, action.SpanContext().TraceID(), writes[0].SpanContext().TraceID())
+	}
+	assert.Equal(t, untraced(messages[0]), conn.writeJSON)
+}
+
+func TestSendContextWithoutTrace(t *testing.T) {
+	g, _ := game.New("Test", "", nil)
+	c := NewClient(g, nil, 1, "")
+	c.SendContext(context.Background(), "Test")
+	messages, _ := c.dequeue()
+	assert.Equal(t, []interface{}{"Test"}, messages)
+}
@@ -0,0 +1,1178 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: sibyl.proto
+
+package sibylpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Action mirrors the actions a browser sends over its web socket.
+type Action int32
+
+const (
+	Action_ACTION_UNSPECIFIED Action = 0
+	Action_ACTION_SELECT      Action = 1
+	Action_ACTION_REVEAL      Action = 2
+	Action_ACTION_RESET       Action = 3
+	Action_ACTION_REVOTE      Action = 4
+	Action_ACTION_DECK        Action = 5
+	Action_ACTION_TOPIC       Action = 6
+	Action_ACTION_USERNAME    Action = 7
+	Action_ACTION_KICK        Action = 8
+	Action_ACTION_MUTE        Action = 9
+	Action_ACTION_UNMUTE      Action = 10
+	Action_ACTION_CHAT        Action = 11
+	Action_ACTION_REACT       Action = 12
+	Action_ACTION_SYNC        Action = 13
+	// ACTION_VISIBILITY tells the room whether the player is looking, with a value of "hidden" or "visible".
+	Action_ACTION_VISIBILITY Action = 14
+	// ACTION_INSIGHTS turns the room's insights on or off, with a value of "on" or "off".
+	Action_ACTION_INSIGHTS Action = 15
+	// ACTION_COMMIT commits the estimate of the revealed round to the story whose key is the value.
+	Action_ACTION_COMMIT Action = 16
+)
+
+// Enum value maps for Action.
+var (
+	Action_name = map[int32]string{
+		0:  "ACTION_UNSPECIFIED",
+		1:  "ACTION_SELECT",
+		2:  "ACTION_REVEAL",
+		3:  "ACTION_RESET",
+		4:  "ACTION_REVOTE",
+		5:  "ACTION_DECK",
+		6:  "ACTION_TOPIC",
+		7:  "ACTION_USERNAME",
+		8:  "ACTION_KICK",
+		9:  "ACTION_MUTE",
+		10: "ACTION_UNMUTE",
+		11: "ACTION_CHAT",
+		12: "ACTION_REACT",
+		13: "ACTION_SYNC",
+		14: "ACTION_VISIBILITY",
+		15: "ACTION_INSIGHTS",
+		16: "ACTION_COMMIT",
+	}
+	Action_value = map[string]int32{
+		"ACTION_UNSPECIFIED": 0,
+		"ACTION_SELECT":      1,
+		"ACTION_REVEAL":      2,
+		"ACTION_RESET":       3,
+		"ACTION_REVOTE":      4,
+		"ACTION_DECK":        5,
+		"ACTION_TOPIC":       6,
+		"ACTION_USERNAME":    7,
+		"ACTION_KICK":        8,
+		"ACTION_MUTE":        9,
+		"ACTION_UNMUTE":      10,
+		"ACTION_CHAT":        11,
+		"ACTION_REACT":       12,
+		"ACTION_SYNC":        13,
+		"ACTION_VISIBILITY":  14,
+		"ACTION_INSIGHTS":    15,
+		"ACTION_COMMIT":      16,
+	}
+)
+
+func (x Action) Enum() *Action {
+	p := new(Action)
+	*p = x
+	return p
+}
+
+func (x Action) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Action) Descriptor() protoreflect.EnumDescriptor {
+	return file_sibyl_proto_enumTypes[0].Descriptor()
+}
+
+func (Action) Type() protoreflect.EnumType {
+	return &file_sibyl_proto_enumTypes[0]
+}
+
+func (x Action) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Action.Descriptor instead.
+func (Action) EnumDescriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{0}
+}
+
+type CreateRoomRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Deck          string                 `protobuf:"bytes,2,opt,name=deck,proto3" json:"deck,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateRoomRequest) Reset() {
+	*x = CreateRoomRequest{}
+	mi := &file_sibyl_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateRoomRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateRoomRequest) ProtoMessage() {}
+
+func (x *CreateRoomRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sibyl_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateRoomRequest.ProtoReflect.Descriptor instead.
+func (*CreateRoomRequest) Descriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CreateRoomRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *CreateRoomRequest) GetDeck() string {
+	if x != nil {
+		return x.Deck
+	}
+	return ""
+}
+
+type GetRoomRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRoomRequest) Reset() {
+	*x = GetRoomRequest{}
+	mi := &file_sibyl_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRoomRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRoomRequest) ProtoMessage() {}
+
+func (x *GetRoomRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sibyl_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRoomRequest.ProtoReflect.Descriptor instead.
+func (*GetRoomRequest) Descriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetRoomRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+type Room struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	Deck          string                 `protobuf:"bytes,3,opt,name=deck,proto3" json:"deck,omitempty"`
+	Topic         string                 `protobuf:"bytes,4,opt,name=topic,proto3" json:"topic,omitempty"`
+	Players       int32                  `protobuf:"varint,5,opt,name=players,proto3" json:"players,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Room) Reset() {
+	*x = Room{}
+	mi := &file_sibyl_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Room) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Room) ProtoMessage() {}
+
+func (x *Room) ProtoReflect() protoreflect.Message {
+	mi := &file_sibyl_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Room.ProtoReflect.Descriptor instead.
+func (*Room) Descriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Room) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *Room) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *Room) GetDeck() string {
+	if x != nil {
+		return x.Deck
+	}
+	return ""
+}
+
+func (x *Room) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *Room) GetPlayers() int32 {
+	if x != nil {
+		return x.Players
+	}
+	return 0
+}
+
+type ActRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	ClientId      string                 `protobuf:"bytes,3,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Action        Action                 `protobuf:"varint,4,opt,name=action,proto3,enum=sibyl.v1.Action" json:"action,omitempty"`
+	Card          int32                  `protobuf:"varint,5,opt,name=card,proto3" json:"card,omitempty"`
+	Deck          string                 `protobuf:"bytes,6,opt,name=deck,proto3" json:"deck,omitempty"`
+	Value         string                 `protobuf:"bytes,7,opt,name=value,proto3" json:"value,omitempty"`
+	PlayerId      int32                  `protobuf:"varint,8,opt,name=player_id,json=playerId,proto3" json:"player_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ActRequest) Reset() {
+	*x = ActRequest{}
+	mi := &file_sibyl_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActRequest) ProtoMessage() {}
+
+func (x *ActRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sibyl_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActRequest.ProtoReflect.Descriptor instead.
+func (*ActRequest) Descriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ActRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ActRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *ActRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *ActRequest) GetAction() Action {
+	if x != nil {
+		return x.Action
+	}
+	return Action_ACTION_UNSPECIFIED
+}
+
+func (x *ActRequest) GetCard() int32 {
+	if x != nil {
+		return x.Card
+	}
+	return 0
+}
+
+func (x *ActRequest) GetDeck() string {
+	if x != nil {
+		return x.Deck
+	}
+	return ""
+}
+
+func (x *ActRequest) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *ActRequest) GetPlayerId() int32 {
+	if x != nil {
+		return x.PlayerId
+	}
+	return 0
+}
+
+type ActResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ActResponse) Reset() {
+	*x = ActResponse{}
+	mi := &file_sibyl_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActResponse) ProtoMessage() {}
+
+func (x *ActResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sibyl_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActResponse.ProtoReflect.Descriptor instead.
+func (*ActResponse) Descriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{4}
+}
+
+type WatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	Username      string                 `protobuf:"bytes,3,opt,name=username,proto3" json:"username,omitempty"`
+	Spectator     bool                   `protobuf:"varint,4,opt,name=spectator,proto3" json:"spectator,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_sibyl_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sibyl_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WatchRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetSpectator() bool {
+	if x != nil {
+		return x.Spectator
+	}
+	return false
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*Event_ClientId
+	//	*Event_Update
+	//	*Event_Message
+	//	*Event_Error
+	//	*Event_Closed
+	Event         isEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_sibyl_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_sibyl_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Event) GetEvent() isEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *Event) GetClientId() string {
+	if x != nil {
+		if x, ok := x.Event.(*Event_ClientId); ok {
+			return x.ClientId
+		}
+	}
+	return ""
+}
+
+func (x *Event) GetUpdate() *Update {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Update); ok {
+			return x.Update
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetMessage() *Message {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Message); ok {
+			return x.Message
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetError() string {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Error); ok {
+			return x.Error
+		}
+	}
+	return ""
+}
+
+func (x *Event) GetClosed() string {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Closed); ok {
+			return x.Closed
+		}
+	}
+	return ""
+}
+
+type isEvent_Event interface {
+	isEvent_Event()
+}
+
+type Event_ClientId struct {
+	ClientId string `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3,oneof"`
+}
+
+type Event_Update struct {
+	Update *Update `protobuf:"bytes,2,opt,name=update,proto3,oneof"`
+}
+
+type Event_Message struct {
+	Message *Message `protobuf:"bytes,3,opt,name=message,proto3,oneof"`
+}
+
+type Event_Error struct {
+	Error string `protobuf:"bytes,4,opt,name=error,proto3,oneof"`
+}
+
+type Event_Closed struct {
+	Closed string `protobuf:"bytes,5,opt,name=closed,proto3,oneof"`
+}
+
+func (*Event_ClientId) isEvent_Event() {}
+
+func (*Event_Update) isEvent_Event() {}
+
+func (*Event_Message) isEvent_Event() {}
+
+func (*Event_Error) isEvent_Event() {}
+
+func (*Event_Closed) isEvent_Event() {}
+
+// Update holds the state of a room, the same as the updates sent to browsers.
+type Update struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Topic      string                 `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Players    map[int32]string       `protobuf:"bytes,2,rep,name=players,proto3" json:"players,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Cards      []*Card                `protobuf:"bytes,3,rep,name=cards,proto3" json:"cards,omitempty"`
+	Deck       string                 `protobuf:"bytes,4,opt,name=deck,proto3" json:"deck,omitempty"`
+	Reveal     bool                   `protobuf:"varint,5,opt,name=reveal,proto3" json:"reveal,omitempty"`
+	Reset_     bool                   `protobuf:"varint,6,opt,name=reset,proto3" json:"reset,omitempty"`
+	Username   string                 `protobuf:"bytes,7,opt,name=username,proto3" json:"username,omitempty"`
+	Elapsed    int32                  `protobuf:"varint,8,opt,name=elapsed,proto3" json:"elapsed,omitempty"`
+	Round      int32                  `protobuf:"varint,9,opt,name=round,proto3" json:"round,omitempty"`
+	Rounds     []*Round               `protobuf:"bytes,10,rep,name=rounds,proto3" json:"rounds,omitempty"`
+	Seq        int32                  `protobuf:"varint,11,opt,name=seq,proto3" json:"seq,omitempty"`
+	Spectators []int32                `protobuf:"varint,12,rep,packed,name=spectators,proto3" json:"spectators,omitempty"`
+	Presence   map[int32]string       `protobuf:"bytes,13,rep,name=presence,proto3" json:"presence,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Messages   []*Message             `protobuf:"bytes,14,rep,name=messages,proto3" json:"messages,omitempty"`
+	// insights is whether the room's history includes a summary of how each player votes.
+	Insights bool `protobuf:"varint,15,opt,name=insights,proto3" json:"insights,omitempty"`
+	// absent holds the names of the players on the roster of a team's room who aren't in it.
+	Absent        []string `protobuf:"bytes,16,rep,name=absent,proto3" json:"absent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Update) Reset() {
+	*x = Update{}
+	mi := &file_sibyl_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Update) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Update) ProtoMessage() {}
+
+func (x *Update) ProtoReflect() protoreflect.Message {
+	mi := &file_sibyl_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Update.ProtoReflect.Descriptor instead.
+func (*Update) Descriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Update) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *Update) GetPlayers() map[int32]string {
+	if x != nil {
+		return x.Players
+	}
+	return nil
+}
+
+func (x *Update) GetCards() []*Card {
+	if x != nil {
+		return x.Cards
+	}
+	return nil
+}
+
+func (x *Update) GetDeck() string {
+	if x != nil {
+		return x.Deck
+	}
+	return ""
+}
+
+func (x *Update) GetReveal() bool {
+	if x != nil {
+		return x.Reveal
+	}
+	return false
+}
+
+func (x *Update) GetReset_() bool {
+	if x != nil {
+		return x.Reset_
+	}
+	return false
+}
+
+func (x *Update) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Update) GetElapsed() int32 {
+	if x != nil {
+		return x.Elapsed
+	}
+	return 0
+}
+
+func (x *Update) GetRound() int32 {
+	if x != nil {
+		return x.Round
+	}
+	return 0
+}
+
+func (x *Update) GetRounds() []*Round {
+	if x != nil {
+		return x.Rounds
+	}
+	return nil
+}
+
+func (x *Update) GetSeq() int32 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Update) GetSpectators() []int32 {
+	if x != nil {
+		return x.Spectators
+	}
+	return nil
+}
+
+func (x *Update) GetPresence() map[int32]string {
+	if x != nil {
+		return x.Presence
+	}
+	return nil
+}
+
+func (x *Update) GetMessages() []*Message {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *Update) GetInsights() bool {
+	if x != nil {
+		return x.Insights
+	}
+	return false
+}
+
+func (x *Update) GetAbsent() []string {
+	if x != nil {
+		return x.Absent
+	}
+	return nil
+}
+
+type Card struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Card          int32                  `protobuf:"varint,1,opt,name=card,proto3" json:"card,omitempty"`
+	PlayerId      int32                  `protobuf:"varint,2,opt,name=player_id,json=playerID,proto3" json:"player_id,omitempty"`
+	Player        string                 `protobuf:"bytes,3,opt,name=player,proto3" json:"player,omitempty"`
+	Outlier       bool                   `protobuf:"varint,4,opt,name=outlier,proto3" json:"outlier,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Card) Reset() {
+	*x = Card{}
+	mi := &file_sibyl_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Card) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Card) ProtoMessage() {}
+
+func (x *Card) ProtoReflect() protoreflect.Message {
+	mi := &file_sibyl_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Card.ProtoReflect.Descriptor instead.
+func (*Card) Descriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Card) GetCard() int32 {
+	if x != nil {
+		return x.Card
+	}
+	return 0
+}
+
+func (x *Card) GetPlayerId() int32 {
+	if x != nil {
+		return x.PlayerId
+	}
+	return 0
+}
+
+func (x *Card) GetPlayer() string {
+	if x != nil {
+		return x.Player
+	}
+	return ""
+}
+
+func (x *Card) GetOutlier() bool {
+	if x != nil {
+		return x.Outlier
+	}
+	return false
+}
+
+type Round struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Round         int32                  `protobuf:"varint,1,opt,name=round,proto3" json:"round,omitempty"`
+	Cards         []*Card                `protobuf:"bytes,2,rep,name=cards,proto3" json:"cards,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Round) Reset() {
+	*x = Round{}
+	mi := &file_sibyl_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Round) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Round) ProtoMessage() {}
+
+func (x *Round) ProtoReflect() protoreflect.Message {
+	mi := &file_sibyl_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Round.ProtoReflect.Descriptor instead.
+func (*Round) Descriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Round) GetRound() int32 {
+	if x != nil {
+		return x.Round
+	}
+	return 0
+}
+
+func (x *Round) GetCards() []*Card {
+	if x != nil {
+		return x.Cards
+	}
+	return nil
+}
+
+type Message struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	PlayerId      int32                  `protobuf:"varint,2,opt,name=player_id,json=playerID,proto3" json:"player_id,omitempty"`
+	Player        string                 `protobuf:"bytes,3,opt,name=player,proto3" json:"player,omitempty"`
+	Text          string                 `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`
+	Time          int64                  `protobuf:"varint,5,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Message) Reset() {
+	*x = Message{}
+	mi := &file_sibyl_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Message) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Message) ProtoMessage() {}
+
+func (x *Message) ProtoReflect() protoreflect.Message {
+	mi := &file_sibyl_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Message.ProtoReflect.Descriptor instead.
+func (*Message) Descriptor() ([]byte, []int) {
+	return file_sibyl_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Message) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Message) GetPlayerId() int32 {
+	if x != nil {
+		return x.PlayerId
+	}
+	return 0
+}
+
+func (x *Message) GetPlayer() string {
+	if x != nil {
+		return x.Player
+	}
+	return ""
+}
+
+func (x *Message) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Message) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+var File_sibyl_proto protoreflect.FileDescriptor
+
+const file_sibyl_proto_rawDesc = "" +
+	"\n" +
+	"\vsibyl.proto\x12\bsibyl.v1\";\n" +
+	"\x11CreateRoomRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x12\n" +
+	"\x04deck\x18\x02 \x01(\tR\x04deck\"$\n" +
+	"\x0eGetRoomRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\"t\n" +
+	"\x04Room\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\x12\x12\n" +
+	"\x04deck\x18\x03 \x01(\tR\x04deck\x12\x14\n" +
+	"\x05topic\x18\x04 \x01(\tR\x05topic\x12\x18\n" +
+	"\aplayers\x18\x05 \x01(\x05R\aplayers\"\xd8\x01\n" +
+	"\n" +
+	"ActRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\x12\x1b\n" +
+	"\tclient_id\x18\x03 \x01(\tR\bclientId\x12(\n" +
+	"\x06action\x18\x04 \x01(\x0e2\x10.sibyl.v1.ActionR\x06action\x12\x12\n" +
+	"\x04card\x18\x05 \x01(\x05R\x04card\x12\x12\n" +
+	"\x04deck\x18\x06 \x01(\tR\x04deck\x12\x14\n" +
+	"\x05value\x18\a \x01(\tR\x05value\x12\x1b\n" +
+	"\tplayer_id\x18\b \x01(\x05R\bplayerId\"\r\n" +
+	"\vActResponse\"r\n" +
+	"\fWatchRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\x12\x1a\n" +
+	"\busername\x18\x03 \x01(\tR\busername\x12\x1c\n" +
+	"\tspectator\x18\x04 \x01(\bR\tspectator\"\xbc\x01\n" +
+	"\x05Event\x12\x1d\n" +
+	"\tclient_id\x18\x01 \x01(\tH\x00R\bclientId\x12*\n" +
+	"\x06update\x18\x02 \x01(\v2\x10.sibyl.v1.UpdateH\x00R\x06update\x12-\n" +
+	"\amessage\x18\x03 \x01(\v2\x11.sibyl.v1.MessageH\x00R\amessage\x12\x16\n" +
+	"\x05error\x18\x04 \x01(\tH\x00R\x05error\x12\x18\n" +
+	"\x06closed\x18\x05 \x01(\tH\x00R\x06closedB\a\n" +
+	"\x05event\"\xfe\x04\n" +
+	"\x06Update\x12\x14\n" +
+	"\x05topic\x18\x01 \x01(\tR\x05topic\x127\n" +
+	"\aplayers\x18\x02 \x03(\v2\x1d.sibyl.v1.Update.PlayersEntryR\aplayers\x12$\n" +
+	"\x05cards\x18\x03 \x03(\v2\x0e.sibyl.v1.CardR\x05cards\x12\x12\n" +
+	"\x04deck\x18\x04 \x01(\tR\x04deck\x12\x16\n" +
+	"\x06reveal\x18\x05 \x01(\bR\x06reveal\x12\x14\n" +
+	"\x05reset\x18\x06 \x01(\bR\x05reset\x12\x1a\n" +
+	"\busername\x18\a \x01(\tR\busername\x12\x18\n" +
+	"\aelapsed\x18\b \x01(\x05R\aelapsed\x12\x14\n" +
+	"\x05round\x18\t \x01(\x05R\x05round\x12'\n" +
+	"\x06rounds\x18\n" +
+	" \x03(\v2\x0f.sibyl.v1.RoundR\x06rounds\x12\x10\n" +
+	"\x03seq\x18\v \x01(\x05R\x03seq\x12\x1e\n" +
+	"\n" +
+	"spectators\x18\f \x03(\x05R\n" +
+	"spectators\x12:\n" +
+	"\bpresence\x18\r \x03(\v2\x1e.sibyl.v1.Update.PresenceEntryR\bpresence\x12-\n" +
+	"\bmessages\x18\x0e \x03(\v2\x11.sibyl.v1.MessageR\bmessages\x12\x1a\n" +
+	"\binsights\x18\x0f \x01(\bR\binsights\x12\x16\n" +
+	"\x06absent\x18\x10 \x03(\tR\x06absent\x1a:\n" +
+	"\fPlayersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x05R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a;\n" +
+	"\rPresenceEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x05R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"i\n" +
+	"\x04Card\x12\x12\n" +
+	"\x04card\x18\x01 \x01(\x05R\x04card\x12\x1b\n" +
+	"\tplayer_id\x18\x02 \x01(\x05R\bplayerID\x12\x16\n" +
+	"\x06player\x18\x03 \x01(\tR\x06player\x12\x18\n" +
+	"\aoutlier\x18\x04 \x01(\bR\aoutlier\"C\n" +
+	"\x05Round\x12\x14\n" +
+	"\x05round\x18\x01 \x01(\x05R\x05round\x12$\n" +
+	"\x05cards\x18\x02 \x03(\v2\x0e.sibyl.v1.CardR\x05cards\"z\n" +
+	"\aMessage\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x1b\n" +
+	"\tplayer_id\x18\x02 \x01(\x05R\bplayerID\x12\x16\n" +
+	"\x06player\x18\x03 \x01(\tR\x06player\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\x12\x12\n" +
+	"\x04time\x18\x05 \x01(\x03R\x04time*\xcb\x02\n" +
+	"\x06Action\x12\x16\n" +
+	"\x12ACTION_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rACTION_SELECT\x10\x01\x12\x11\n" +
+	"\rACTION_REVEAL\x10\x02\x12\x10\n" +
+	"\fACTION_RESET\x10\x03\x12\x11\n" +
+	"\rACTION_REVOTE\x10\x04\x12\x0f\n" +
+	"\vACTION_DECK\x10\x05\x12\x10\n" +
+	"\fACTION_TOPIC\x10\x06\x12\x13\n" +
+	"\x0fACTION_USERNAME\x10\a\x12\x0f\n" +
+	"\vACTION_KICK\x10\b\x12\x0f\n" +
+	"\vACTION_MUTE\x10\t\x12\x11\n" +
+	"\rACTION_UNMUTE\x10\n" +
+	"\x12\x0f\n" +
+	"\vACTION_CHAT\x10\v\x12\x10\n" +
+	"\fACTION_REACT\x10\f\x12\x0f\n" +
+	"\vACTION_SYNC\x10\r\x12\x15\n" +
+	"\x11ACTION_VISIBILITY\x10\x0e\x12\x13\n" +
+	"\x0fACTION_INSIGHTS\x10\x0f\x12\x11\n" +
+	"\rACTION_COMMIT\x10\x102\xdf\x01\n" +
+	"\x05Sibyl\x129\n" +
+	"\n" +
+	"CreateRoom\x12\x1b.sibyl.v1.CreateRoomRequest\x1a\x0e.sibyl.v1.Room\x123\n" +
+	"\aGetRoom\x12\x18.sibyl.v1.GetRoomRequest\x1a\x0e.sibyl.v1.Room\x122\n" +
+	"\x03Act\x12\x14.sibyl.v1.ActRequest\x1a\x15.sibyl.v1.ActResponse\x122\n" +
+	"\x05Watch\x12\x16.sibyl.v1.WatchRequest\x1a\x0f.sibyl.v1.Event0\x01B\"Z github.com/synacor/sibyl/sibylpbb\x06proto3"
+
+var (
+	file_sibyl_proto_rawDescOnce sync.Once
+	file_sibyl_proto_rawDescData []byte
+)
+
+func file_sibyl_proto_rawDescGZIP() []byte {
+	file_sibyl_proto_rawDescOnce.Do(func() {
+		file_sibyl_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sibyl_proto_rawDesc), len(file_sibyl_proto_rawDesc)))
+	})
+	return file_sibyl_proto_rawDescData
+}
+
+var file_sibyl_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_sibyl_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_sibyl_proto_goTypes = []any{
+	(Action)(0),               // 0: sibyl.v1.Action
+	(*CreateRoomRequest)(nil), // 1: sibyl.v1.CreateRoomRequest
+	(*GetRoomRequest)(nil),    // 2: sibyl.v1.GetRoomRequest
+	(*Room)(nil),              // 3: sibyl.v1.Room
+	(*ActRequest)(nil),        // 4: sibyl.v1.ActRequest
+	(*ActResponse)(nil),       // 5: sibyl.v1.ActResponse
+	(*WatchRequest)(nil),      // 6: sibyl.v1.WatchRequest
+	(*Event)(nil),             // 7: sibyl.v1.Event
+	(*Update)(nil),            // 8: sibyl.v1.Update
+	(*Card)(nil),              // 9: sibyl.v1.Card
+	(*Round)(nil),             // 10: sibyl.v1.Round
+	(*Message)(nil),           // 11: sibyl.v1.Message
+	nil,                       // 12: sibyl.v1.Update.PlayersEntry
+	nil,                       // 13: sibyl.v1.Update.PresenceEntry
+}
+var file_sibyl_proto_depIdxs = []int32{
+	0,  // 0: sibyl.v1.ActRequest.action:type_name -> sibyl.v1.Action
+	8,  // 1: sibyl.v1.Event.update:type_name -> sibyl.v1.Update
+	11, // 2: sibyl.v1.Event.message:type_name -> sibyl.v1.Message
+	12, // 3: sibyl.v1.Update.players:type_name -> sibyl.v1.Update.PlayersEntry
+	9,  // 4: sibyl.v1.Update.cards:type_name -> sibyl.v1.Card
+	10, // 5: sibyl.v1.Update.rounds:type_name -> sibyl.v1.Round
+	13, // 6: sibyl.v1.Update.presence:type_name -> sibyl.v1.Update.PresenceEntry
+	11, // 7: sibyl.v1.Update.messages:type_name -> sibyl.v1.Message
+	9,  // 8: sibyl.v1.Round.cards:type_name -> sibyl.v1.Card
+	1,  // 9: sibyl.v1.Sibyl.CreateRoom:input_type -> sibyl.v1.CreateRoomRequest
+	2,  // 10: sibyl.v1.Sibyl.GetRoom:input_type -> sibyl.v1.GetRoomRequest
+	4,  // 11: sibyl.v1.Sibyl.Act:input_type -> sibyl.v1.ActRequest
+	6,  // 12: sibyl.v1.Sibyl.Watch:input_type -> sibyl.v1.WatchRequest
+	3,  // 13: sibyl.v1.Sibyl.CreateRoom:output_type -> sibyl.v1.Room
+	3,  // 14: sibyl.v1.Sibyl.GetRoom:output_type -> sibyl.v1.Room
+	5,  // 15: sibyl.v1.Sibyl.Act:output_type -> sibyl.v1.ActResponse
+	7,  // 16: sibyl.v1.Sibyl.Watch:output_type -> sibyl.v1.Event
+	13, // [13:17] is the sub-list for method output_type
+	9,  // [9:13] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_sibyl_proto_init() }
+func file_sibyl_proto_init() {
+	if File_sibyl_proto != nil {
+		return
+	}
+	file_sibyl_proto_msgTypes[6].OneofWrappers = []any{
+		(*Event_ClientId)(nil),
+		(*Event_Update)(nil),
+		(*Event_Message)(nil),
+		(*Event_Error)(nil),
+		(*Event_Closed)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sibyl_proto_rawDesc), len(file_sibyl_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sibyl_proto_goTypes,
+		DependencyIndexes: file_sibyl_proto_depIdxs,
+		EnumInfos:         file_sibyl_proto_enumTypes,
+		MessageInfos:      file_sibyl_proto_msgTypes,
+	}.Build()
+	File_sibyl_proto = out.File
+	file_sibyl_proto_goTypes = nil
+	file_sibyl_proto_depIdxs = nil
+}
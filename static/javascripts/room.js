@@ -1,11 +1,22 @@
+// t translates key using the messages the server rendered into SibylConfig, replacing each %s with
+// the next argument. It falls back to the key itself.
+var t = function(key) {
+    var messages = (window["SibylConfig"] && SibylConfig.Messages) || {},
+        args = Array.prototype.slice.call(arguments, 1)
+
+    return (messages[key] || key).replace(/%s/g, function() {
+        return args.length ? String(args.shift()) : "%s"
+    })
+}
+
 var Sibyl = function() {
     this.inReveal = false
 
     if (!window["SibylConfig"] || !SibylConfig.Token) {
-        this.addToConsole("Could not create room.")
+        this.addToConsole(t("could_not_create_room"))
         return
-    } else if (!("WebSocket" in window)) {
-        this.addToConsole("Your browser does not support Web Sockets.")
+    } else if (!("WebSocket" in window) && !("EventSource" in window)) {
+        this.addToConsole(t("no_websockets"))
         return
     }
 
@@ -25,7 +36,11 @@ var Sibyl = function() {
         this.removeItem("username")
     }
 
-    this.connectToWebSocket()
+    if ("WebSocket" in window) {
+        this.connectToWebSocket()
+    } else {
+        this.connectToEventSource()
+    }
     this.setupBindings()
 
     setInterval(this.updateElapsed.bind(this), 20)
@@ -69,7 +84,7 @@ Sibyl.prototype.setupBindings = function() {
         $("#copy-url").click(function(e) {
         var x = e.clientX + document.body.scrollLeft,
             y = e.clientY + document.body.scrollTop,
-            ok, msg = "link copied!",
+            ok, msg = t("link_copied"),
             $copydiv,
             $input = $("<input>").css({
                 position: "absolute",
@@ -81,7 +96,7 @@ Sibyl.prototype.setupBindings = function() {
         try {
             document.execCommand("copy")
         } catch(e) {
-                msg = "browser does not support copy command"
+                msg = t("no_copy")
         }
 
         $input.remove()
@@ -104,11 +119,24 @@ Sibyl.prototype.setupBindings = function() {
         return false;
     })
 
+    $("#revote").click(function() {
+        if (self.inReveal) {
+            self.send("revote")
+        }
+
+        return false;
+    })
+
     $("#reset").click(function() {
         self.send("reset")
         return false;
     })
 
+    $("#insights").click(function() {
+        self.send("insights", { value: self.insights ? "off" : "on" })
+        return false;
+    })
+
     $(".decks a").click(function() {
         self.send("deck", { deck: $(this).attr("data-name") })
         return false
@@ -159,6 +187,35 @@ Sibyl.prototype.setupBindings = function() {
         textToInput("topic", $topic, self.topic, "topic-edit", SibylConfig.TopicMaxLength)
     })
 
+    $("#chat").submit(function() {
+        var $text = $("#chat-text")
+        if ($text.val().match(/\w/)) {
+            self.send("chat", { value: $text.val() })
+        }
+
+        $text.val("")
+        return false
+    })
+
+    $("#commit").submit(function() {
+        var $key = $("#commit-key")
+        if ($key.val().match(/\w/)) {
+            self.send("commit", { value: $.trim($key.val()) })
+        }
+
+        $key.val("")
+        return false
+    })
+
+    $(".reactions a").click(function() {
+        self.send("react", { value: $(this).attr("data-reaction") })
+        return false
+    })
+
+    $(document).on("visibilitychange", function() {
+        self.sendVisibility()
+    })
+
     $(window).on("beforeunload", function() {
         self.disconnect()
     })
@@ -190,11 +247,19 @@ Sibyl.prototype.updateBoard = function(data) {
     $topic.text(this.topic)
 
     this.inReveal = data.reveal
+    $("#commit").toggle(this.inReveal)
 
     if (data.reset) {
         $myHand.find("a").removeClass("chosen")
     }
 
+    if (data.messages) {
+        $("#messages").html("")
+        for (i = 0; i < data.messages.length; i++) {
+            this.addMessage(data.messages[i])
+        }
+    }
+
     if ( !this.deck || this.deck != data.deck ) {
         this.deck = data.deck
         this.storeItem("deck", this.deck)
@@ -223,10 +288,19 @@ Sibyl.prototype.updateBoard = function(data) {
 
     $cards.html("")
 
-    var playerIDsToCards = {}
+    var playerIDsToCards = {},
+        outliers = {},
+        spectators = {},
+        presence = data.presence || {}
+    n = (data.spectators || []).length
+    for (i = 0; i < n; i++) {
+        spectators[data.spectators[i]] = true
+    }
+
     n = data.cards.length
     for (i = 0; i < n; i++) {
         playerIDsToCards[data.cards[i].playerID] = data.cards[i].card
+        outliers[data.cards[i].playerID] = data.cards[i].outlier
     }
 
     var playerIDs = []
@@ -253,6 +327,9 @@ Sibyl.prototype.updateBoard = function(data) {
 
             if (this.inReveal) {
                 $span.addClass("card-flipped")
+                if (outliers[playerID]) {
+                    $div.addClass("outlier")
+                }
             } else {
                 $span.addClass("card-facedown")
             }
@@ -261,26 +338,128 @@ Sibyl.prototype.updateBoard = function(data) {
 
             $span = $("<span>").addClass("player-name").text(data.players[playerID])
             $div.append($span)
+        } else if (spectators[playerID]) {
+            $div.addClass("spectator")
+            $div.append($("<span>").addClass("card").addClass("card-blank").addClass("card-spectator").html("&ndash;"))
+            $div.append($("<span>").addClass("player-name").text(data.players[playerID]))
         } else {
             $div = $("<div>").addClass("card")
             $div.append($("<span>").addClass("card").addClass("card-blank").html("?"))
             $div.append($("<span>").addClass("player-name").text(data.players[playerID]))
         }
 
+        if (presence[playerID]) {
+            $div.addClass(presence[playerID]).attr("title", t("player_" + presence[playerID], data.players[playerID]))
+        }
+
+        $div.append(this.playerActions(parseInt(playerID, 10), !!spectators[playerID]))
         $cards.append($div)
     }
+
+    $("#absent").text(data.absent && data.absent.length ? t("absent", data.absent.join(", ")) : "")
+
+    this.updateRounds(data)
+    this.updateInsights(data)
+}
+
+Sibyl.prototype.playerActions = function(playerID, isSpectator) {
+    var self = this,
+        $actions = $("<span>").addClass("player-actions"),
+        action = function(text, title, name, value) {
+            return $("<a>").attr("href", "#").attr("title", title).text(text).click(function() {
+                self.send(name, { playerID: playerID, value: value })
+                return false
+            })
+        }
+
+    if (isSpectator) {
+        $actions.append(action(t("unmute"), t("unmute_title"), "unmute"))
+    } else {
+        $actions.append(action(t("mute"), t("mute_title"), "mute"))
+    }
+    $actions.append(action(t("kick"), t("kick_title"), "kick"))
+    $actions.append(action(t("ban"), t("ban_title"), "kick", "ban"))
+
+    return $actions
+}
+
+Sibyl.prototype.updateRounds = function(data) {
+    var i, j, round, card, names,
+        $rounds = $("#rounds"),
+        cards = SibylConfig.Decks[this.deck].cards
+
+    $rounds.html("")
+    if (!data.rounds || data.rounds.length == 0) {
+        return
+    }
+
+    for (i = 0; i < data.rounds.length; i++) {
+        round = data.rounds[i]
+        names = []
+        for (j = 0; j < round.cards.length; j++) {
+            card = round.cards[j]
+            names.push($("<span>").text(card.player + ": " + cards[card.card]).toggleClass("outlier", card.outlier).prop("outerHTML"))
+        }
+
+        $rounds.append($("<p>").html("<strong>" + t("round", round.round) + "</strong> " + names.join(", ")))
+    }
+
+    $rounds.append($("<p>").html("<strong>" + t("round", data.round) + "</strong> " + t("current")))
+}
+
+Sibyl.prototype.updateInsights = function(data) {
+    var self = this,
+        $summary = $("#insights-summary"),
+        refresh = data.insights && (!this.insights || (data.reveal && !this.insightsRevealed))
+
+    this.insights = data.insights
+    this.insightsRevealed = data.reveal
+    $("#insights").text(data.insights ? t("hide_insights") : t("show_insights"))
+
+    if (!data.insights) {
+        $summary.html("")
+        return
+    }
+
+    // the summary only changes once a round is revealed
+    if (!refresh) {
+        return
+    }
+
+    $.getJSON("/history", { room: this.room, token: this.token }, function(history) {
+        var i, insight, text,
+            insights = history.insights || []
+
+        $summary.html("")
+        for (i = 0; i < insights.length; i++) {
+            insight = insights[i]
+            if (insight.fromMedian > 0) {
+                text = t("insight_above", insight.player, insight.fromMedian, insight.rounds)
+            } else if (insight.fromMedian < 0) {
+                text = t("insight_below", insight.player, -insight.fromMedian, insight.rounds)
+            } else {
+                text = t("insight_at", insight.player, insight.rounds)
+            }
+
+            $summary.append($("<p>").text(text))
+        }
+    })
 }
 
 Sibyl.prototype.connectToWebSocket = function(isRetry) {
     var self = this,
-        url = (window.location.protocol == "https:" ? "wss://" : "ws://") + window.location.host + "/ws?room=" + encodeURIComponent(this.room) + "&token=" + encodeURIComponent(this.token) + "&username=" + encodeURIComponent(this.username),
+        url = (window.location.protocol == "https:" ? "wss://" : "ws://") + window.location.host + "/ws?" + this.connectQuery(),
         conn = new WebSocket(url),
         isOpen = false
 
     conn.onopen = function(evt) {
         isOpen = true
         isRetry = false
-        self.addToConsole("Connected.")
+        self.connected = true
+        self.addToConsole(t("connected"))
+        if (document.hidden) {
+            self.sendVisibility()
+        }
         setTimeout(function() {
             if (isOpen) {
                 self.showGame()
@@ -288,17 +467,25 @@ Sibyl.prototype.connectToWebSocket = function(isRetry) {
         }, 250);
     }
     conn.onerror = function(evt) {
-        self.addToConsole("Error. Lost connection.")
+        self.addToConsole(t("error_lost_connection"))
         self.showConsole()
     }
     conn.onclose = function(evt) {
         var now
 
         isOpen = false
+
+        // the web socket never connected, which happens behind some proxies. try an event stream instead
+        if (!self.connected && "EventSource" in window) {
+            self.addToConsole(t("trying_another_way"))
+            self.connectToEventSource()
+            return
+        }
+
         if (isRetry) {
-            self.addToConsole("Server may be offline.")
+            self.addToConsole(t("server_offline"))
         } else {
-            self.addToConsole("Server disconnected.")
+            self.addToConsole(t("server_disconnected"))
         }
 
         self.showConsole()
@@ -306,13 +493,13 @@ Sibyl.prototype.connectToWebSocket = function(isRetry) {
         if (!isRetry) {
             now = new Date().getTime() / 1000
             if ( now - self.lastConnectAttempt < 10 ) {
-                self.addToConsole('Having an issue? Try using https: <a href="https://' + window.location.host + window.location.pathname + '">https://' + window.location.host + window.location.pathname + '</a>')
+                self.addToConsole(t("try_https") + ' <a href="https://' + window.location.host + window.location.pathname + '">https://' + window.location.host + window.location.pathname + '</a>')
                 return
             }
             self.lastConnectAttempt = now
 
             setTimeout(function() {
-                self.addToConsole("Attempting to reconnect...")
+                self.addToConsole(t("reconnecting"))
 
                 setTimeout(function() {
                     self.connectToWebSocket(true)
@@ -321,21 +508,123 @@ Sibyl.prototype.connectToWebSocket = function(isRetry) {
         }
     }
     conn.onmessage = function(evt) {
-        var data = JSON.parse(evt.data)
-        if (data.error) {
-            self.disconnect()
-            self.addToConsole(data.error)
-            self.showConsole()
-        } else {
-            self.updateBoard(data)
+        self.handleMessage(JSON.parse(evt.data))
+    }
+
+    this.conn = conn
+}
+
+Sibyl.prototype.connectToEventSource = function() {
+    var self = this,
+        source = new EventSource("/sse?" + this.connectQuery()),
+        conn = {
+            id: null,
+            readyState: 0,
+            send: function(data) {
+                $.ajax({
+                    url: "/sse/action?id=" + encodeURIComponent(conn.id),
+                    type: "POST",
+                    contentType: "application/json",
+                    data: data
+                })
+            },
+            close: function() {
+                conn.readyState = 3
+                source.close()
+                conn.onclose()
+            },
+            onclose: function() { }
         }
+
+    // the stream starts with an id, which is sent along with every action
+    source.addEventListener("id", function(evt) {
+        conn.id = evt.data
+        conn.readyState = 1
+        self.connected = true
+        self.addToConsole(t("connected"))
+        if (document.hidden) {
+            self.sendVisibility()
+        }
+        self.showGame()
+    })
+    source.addEventListener("close", function(evt) {
+        conn.readyState = 3
+        source.close()
+        self.addToConsole(evt.data || t("server_disconnected"))
+        self.showConsole()
+    })
+    source.onmessage = function(evt) {
+        self.handleMessage(JSON.parse(evt.data))
+    }
+    source.onerror = function() {
+        // the browser reconnects on its own, which starts a new stream with a new id
+        conn.readyState = 0
+        self.addToConsole(t("lost_connection_reconnecting"))
+        self.showConsole()
     }
 
     this.conn = conn
 }
 
+Sibyl.prototype.connectQuery = function() {
+    return "room=" + encodeURIComponent(this.room) + "&token=" + encodeURIComponent(this.token) + "&username=" + encodeURIComponent(this.username) + "&delta=1"
+}
+
+Sibyl.prototype.handleMessage = function(data) {
+    if (data.error) {
+        this.disconnect()
+        this.addToConsole(data.error)
+        this.showConsole()
+    } else if (data.message) {
+        this.addMessage(data.message)
+    } else if (data.delta) {
+        this.applyDelta(data)
+    } else {
+        this.state = data
+        this.syncing = false
+        this.updateBoard(data)
+        delete data.messages
+        data.reset = false
+    }
+}
+
+Sibyl.prototype.applyDelta = function(delta) {
+    var key
+
+    // a delta only makes sense on top of the update right before it. if one went missing, ask for everything again
+    if (!this.state || delta.seq != this.state.seq + 1) {
+        if (!this.syncing) {
+            this.syncing = true
+            this.send("sync")
+        }
+        return
+    }
+
+    for (key in delta) {
+        if (delta.hasOwnProperty(key) && key != "delta") {
+            this.state[key] = delta[key]
+        }
+    }
+
+    this.updateBoard(this.state)
+    this.state.reset = false
+}
+
+Sibyl.prototype.addMessage = function(message) {
+    var $messages = $("#messages"),
+        $p = $("<p>").addClass(message.kind),
+        time = new Date(message.time * 1000),
+        minutes = time.getMinutes()
+
+    $p.append($("<span>").addClass("time").text(time.getHours() + ":" + (minutes < 10 ? "0" + minutes : minutes)))
+    $p.append($("<span>").addClass("player").text(message.player))
+    $p.append($("<span>").addClass("text").text(message.text))
+    $messages.append($p)
+    $messages.scrollTop($messages.prop("scrollHeight"))
+}
+
 Sibyl.prototype.disconnect = function() {
-    this.addToConsole("Disconnected.")
+    this.addToConsole(t("disconnected"))
     this.conn.onclose = function() { }
     this.conn.close(1000, "closing ok")
 }
@@ -360,12 +649,19 @@ Sibyl.prototype.send = function(action, opts) {
         action: action,
         card: opts.card || null,
         deck: opts.deck || null,
+        playerID: opts.playerID || null,
         room: this.room,
         token: this.token,
         value: opts.value || null
     }))
 }
 
+Sibyl.prototype.sendVisibility = function() {
+    if (this.conn && this.conn.readyState == 1) {
+        this.send("visibility", { value: document.hidden ? "hidden" : "visible" })
+    }
+}
+
 Sibyl.prototype.getItem = function(key) {
 	var value = null
 
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/synacor/sibyl/deck"
+)
+
+// Report compares the estimates of the stories whose actual effort is known with that effort.
+type Report struct {
+	// Decks has a calibration for each deck, over every team
+	Decks []Calibration `json:"decks"`
+
+	// Teams has a calibration for each deck each team used
+	Teams []Calibration `json:"teams"`
+}
+
+// Calibration shows how well the estimates of a deck predicted the actual effort. Plotted with the
+// estimate along one axis and the actual effort along the other, it's a calibration chart.
+type Calibration struct {
+	Team    string `json:"team,omitempty"`
+	Deck    string `json:"deck"`
+	Stories int    `json:"stories"`
+
+	// Ratio is the average of the actual effort divided by the estimate, for estimates which are
+	// numbers above 0. Above 1, the work took more than estimated. It's nil for decks such as T-shirt
+	// sizes.
+	Ratio *float64 `json:"ratio"`
+
+	// Points has the actual effort of the stories given each estimate, in the order of the deck
+	Points []CalibrationPoint `json:"points"`
+}
+
+// CalibrationPoint is the actual effort of the stories given the same estimate.
+type CalibrationPoint struct {
+	Estimate string  `json:"estimate"`
+	Stories  int     `json:"stories"`
+	Mean     float64 `json:"mean"`
+	Median   float64 `json:"median"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+}
+
+// Report returns the calibrations of the stories whose actual effort is known. Stories estimated with
+// a card such as "?" are left out.
+func (s *Store) Report() Report {
+	byDeck := make(map[string][]Story)
+	byTeam := make(map[[2]string][]Story)
+	for _, story := range s.Stories() {
+		if story.Actual == nil || !deck.IsEstimateCard(story.Estimate) {
+			continue
+		}
+
+		byDeck[story.Deck] = append(byDeck[story.Deck], story)
+		key := [2]string{story.Team, story.Deck}
+		byTeam[key] = append(byTeam[key], story)
+	}
+
+	r := Report{
+		Decks: make([]Calibration, 0, len(byDeck)),
+		Teams: make([]Calibration, 0, len(byTeam)),
+	}
+	for d, stories := range byDeck {
+		r.Decks = append(r.Decks, calibrate("", d, stories))
+	}
+	for key, stories := range byTeam {
+		r.Teams = append(r.Teams, calibrate(key[0], key[1], stories))
+	}
+
+	for _, calibrations := range [][]Calibration{r.Decks, r.Teams} {
+		sort.Slice(calibrations, func(i, j int) bool {
+			if calibrations[i].Team != calibrations[j].Team {
+				return calibrations[i].Team < calibrations[j].Team
+			}
+			return calibrations[i].Deck < calibrations[j].Deck
+		})
+	}
+
+	return r
+}
+
+// calibrate returns the calibration of stories, which were all estimated with the same deck.
+func calibrate(team, deckName string, stories []Story) Calibration {
+	c := Calibration{
+		Team:    team,
+		Deck:    deckName,
+		Stories: len(stories),
+		Points:  make([]CalibrationPoint, 0),
+	}
+
+	actuals := make(map[string][]float64)
+	var ratio float64
+	var numeric int
+	for _, story := range stories {
+		actuals[story.Estimate] = append(actuals[story.Estimate], *story.Actual)
+
+		if estimate, err := strconv.ParseFloat(story.Estimate, 64); err == nil && estimate > 0 {
+			ratio += *story.Actual / estimate
+			numeric++
+		}
+	}
+
+	if numeric > 0 {
+		ratio = round(ratio / float64(numeric))
+		c.Ratio = &ratio
+	}
+
+	for estimate, values := range actuals {
+		sort.Float64s(values)
+
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+
+		p := CalibrationPoint{
+			Estimate: estimate,
+			Stories:  len(values),
+			Mean:     round(sum / float64(len(values))),
+			Min:      values[0],
+			Max:      values[len(values)-1],
+		}
+		if n := len(values); n%2 == 0 {
+			p.Median = round((values[n/2-1] + values[n/2]) / 2)
+		} else {
+			p.Median = values[n/2]
+		}
+
+		c.Points = append(c.Points, p)
+	}
+
+	order := cardOrder(deckName)
+	sort.Slice(c.Points, func(i, j int) bool {
+		oi, iFound := order[c.Points[i].Estimate]
+		oj, jFound := order[c.Points[j].Estimate]
+		if iFound && jFound {
+			return oi < oj
+		} else if iFound != jFound {
+			// cards a deck no longer has go last
+			return iFound
+		}
+
+		return c.Points[i].Estimate < c.Points[j].Estimate
+	})
+
+	return c
+}
+
+// cardOrder returns the position of each card of the named deck.
+func cardOrder(name string) map[string]int {
+	order := make(map[string]int)
+	if d, found := deck.AllDecks[name]; found {
+		for i, card := range d.Cards {
+			order[card] = i
+		}
+	}
+
+	return order
+}
+
+// round rounds to two decimals, which keeps the report readable.
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	s, _ := Open(t.TempDir())
+	assert.Equal(t, Report{Decks: []Calibration{}, Teams: []Calibration{}}, s.Report())
+
+	stories := []struct {
+		key, team, deck, estimate string
+		actual                    float64
+	}{
+		{"A-1", "Web", "Fibonacci", "5", 10},
+		{"A-2", "Web", "Fibonacci", "5", 6},
+		{"A-3", "Web", "Fibonacci", "13", 13},
+		{"A-4", "Web", "Fibonacci", "2", 1},
+		{"B-1", "Platform", "Fibonacci", "5", 5},
+		{"B-2", "Platform", "T-Shirt Sizes", "M", 3},
+		{"B-3", "Platform", "T-Shirt Sizes", "S", 1},
+		{"B-4", "Platform", "Fibonacci", "?", 20},
+	}
+	for _, story := range stories {
+		s.CommitEstimate(Story{Key: story.key, Team: story.team, Deck: story.deck, Estimate: story.estimate})
+		s.SetActual(story.key, story.actual)
+	}
+
+	// without its actual effort, a story isn't in the report
+	s.CommitEstimate(Story{Key: "A-5", Team: "Web", Deck: "Fibonacci", Estimate: "8"})
+
+	ratio := func(f float64) *float64 { return &f }
+
+	r := s.Report()
+	assert.Equal(t, []Calibration{
+		{
+			Deck:    "Fibonacci",
+			Stories: 5,
+			Ratio:   ratio(1.14),
+			Points: []CalibrationPoint{
+				{Estimate: "2", Stories: 1, Mean: 1, Median: 1, Min: 1, Max: 1},
+				{Estimate: "5", Stories: 3, Mean: 7, Median: 6, Min: 5, Max: 10},
+				{Estimate: "13", Stories: 1, Mean: 13, Median: 13, Min: 13, Max: 13},
+			},
+		},
+		{
+			Deck:    "T-Shirt Sizes",
+			Stories: 2,
+			Points: []CalibrationPoint{
+				{Estimate: "S", Stories: 1, Mean: 1, Median: 1, Min: 1, Max: 1},
+				{Estimate: "M", Stories: 1, Mean: 3, Median: 3, Min: 3, Max: 3},
+			},
+		},
+	}, r.Decks)
+
+	if assert.Len(t, r.Teams, 3) {
+		assert.Equal(t, "Platform", r.Teams[0].Team)
+		assert.Equal(t, "Fibonacci", r.Teams[0].Deck)
+		assert.Equal(t, ratio(1), r.Teams[0].Ratio)
+		assert.Equal(t, "Platform", r.Teams[1].Team)
+		assert.Equal(t, "T-Shirt Sizes", r.Teams[1].Deck)
+		assert.Equal(t, "Web", r.Teams[2].Team)
+		assert.Equal(t, 4, r.Teams[2].Stories)
+		assert.Equal(t, ratio(1.18), r.Teams[2].Ratio)
+	}
+}
@@ -0,0 +1,104 @@
+// Package store keeps what Sibyl needs to remember across restarts, as JSON files in a data directory.
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// storiesFile is the file of the data directory which holds the stories
+const storiesFile = "stories.json"
+
+// teamsDir is the directory of the data directory which holds a file for each team
+const teamsDir = "teams"
+
+// Store is a data directory. It's safe to use from multiple goroutines.
+type Store struct {
+	dir string
+
+	mu      sync.Mutex
+	stories map[string]*Story
+	teams   map[string]*Team
+
+	// err is the error of the last save, which is cleared by the next save that works
+	err error
+}
+
+// Open opens the data directory, creating it if it doesn't exist.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, teamsDir), 0755); err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		dir:     dir,
+		stories: make(map[string]*Story),
+		teams:   make(map[string]*Team),
+	}
+	if err := s.load(storiesFile, &s.stories); err != nil {
+		return nil, err
+	}
+	if err := s.loadTeams(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Err returns why the last change could not be saved, or nil if it was.
+func (s *Store) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// load reads a file of the data directory into v. A file which doesn't exist yet leaves v as it is.
+func (s *Store) load(name string, v interface{}) error {
+	b, err := os.ReadFile(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	return nil
+}
+
+// save writes v to a file of the data directory. The file is replaced in one go, so it's never left
+// half written. The caller must hold mu.
+func (s *Store) save(name string, v interface{}) error {
+	s.err = s.write(name, v)
+	return s.err
+}
+
+func (s *Store) write(name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, name)
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	s, err := Open(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.DirExists(t, dir)
+	assert.Empty(t, s.Stories())
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, storiesFile), []byte("{"), 0644))
+	_, err = Open(dir)
+	assert.Error(t, err)
+
+	_, err = Open(filepath.Join(dir, storiesFile))
+	assert.Error(t, err)
+}
+
+func TestStories(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir)
+
+	_, err := s.CommitEstimate(Story{Key: "bad key"})
+	assert.Equal(t, ErrInvalidStoryKey, err)
+
+	story, err := s.CommitEstimate(Story{Key: "PLAT-1", Team: "Platform", Room: "Platform", Topic: "Login", Deck: "Fibonacci", Estimate: "5"})
+	assert.NoError(t, err)
+	assert.False(t, story.EstimatedAt.IsZero())
+	assert.Nil(t, story.Actual)
+
+	_, err = s.SetActual("PLAT-2", 3)
+	assert.Equal(t, ErrStoryNotFound, err)
+	_, err = s.SetActual("PLAT-1", -1)
+	assert.Equal(t, ErrInvalidActual, err)
+
+	story, err = s.SetActual("PLAT-1", 8)
+	assert.NoError(t, err)
+	assert.Equal(t, 8.0, *story.Actual)
+
+	// estimating a story again keeps its actual effort
+	story, err = s.CommitEstimate(Story{Key: "PLAT-1", Team: "Platform", Deck: "Fibonacci", Estimate: "8"})
+	assert.NoError(t, err)
+	assert.Equal(t, "8", story.Estimate)
+	assert.Equal(t, 8.0, *story.Actual)
+
+	s.CommitEstimate(Story{Key: "#42", Team: "Web", Deck: "Hours", Estimate: "4"})
+
+	// the stories are still there after a restart
+	s, err = Open(dir)
+	assert.NoError(t, err)
+	stories := s.Stories()
+	if assert.Len(t, stories, 2) {
+		assert.Equal(t, "#42", stories[0].Key)
+		assert.Equal(t, "PLAT-1", stories[1].Key)
+		assert.Equal(t, 8.0, *stories[1].Actual)
+	}
+
+	story, found := s.Story("PLAT-1")
+	assert.True(t, found)
+	assert.Equal(t, "Platform", story.Team)
+	_, found = s.Story("PLAT-2")
+	assert.False(t, found)
+}
+
+func TestSaveError(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	s, _ := Open(dir)
+	assert.NoError(t, s.Err())
+
+	assert.NoError(t, os.RemoveAll(dir))
+	_, err := s.CommitEstimate(Story{Key: "PLAT-1", Estimate: "5"})
+	assert.Error(t, err)
+	assert.Equal(t, err, s.Err())
+
+	// the story is kept, and saved once the directory is back
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	_, err = s.SetActual("PLAT-1", 3)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Err())
+	assert.FileExists(t, filepath.Join(dir, storiesFile))
+}
+
+func TestStoryKeyIsValid(t *testing.T) {
+	for _, key := range []string{"PLAT-123", "#42", "42", "proj:7", "Ärger_1.2"} {
+		assert.True(t, StoryKeyIsValid(key), key)
+	}
+
+	for _, key := range []string{"", "-1", "PLAT 1", "a/b", "<b>", string(make([]byte, 65))} {
+		assert.False(t, StoryKeyIsValid(key), key)
+	}
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// ErrStoryNotFound is returned when there is no story with a key
+var ErrStoryNotFound = errors.New("store: story not found")
+
+// ErrInvalidStoryKey is returned when a story key isn't valid, see StoryKeyIsValid
+var ErrInvalidStoryKey = errors.New("store: invalid story key")
+
+// ErrInvalidActual is returned when the actual effort of a story is negative or not a number
+var ErrInvalidActual = errors.New("store: actual effort must be a number of at least 0")
+
+// storyKeyRx matches the keys of issue trackers, such as "PLAT-123" or "#42"
+var storyKeyRx = regexp.MustCompile(`^[\p{L}\p{N}#][\p{L}\p{N}_.:#-]{0,63}$`)
+
+// Story is a story estimated with Sibyl, keyed by the story's key in an issue tracker. Once the story is
+// done, the effort it actually took can be added, to see how the estimate held up.
+type Story struct {
+	Key string `json:"key"`
+
+	// Team is who estimated the story, which is the name of the room it was estimated in. A team space
+	// has a room of the same name, see Team.
+	Team  string `json:"team"`
+	Room  string `json:"room"`
+	Topic string `json:"topic"`
+
+	// Deck is the name of the deck the story was estimated with, and Estimate the card committed
+	Deck        string    `json:"deck"`
+	Estimate    string    `json:"estimate"`
+	EstimatedAt time.Time `json:"estimatedAt"`
+
+	// Actual is the effort the story took, in whatever unit the team uses. It's nil until it's known.
+	Actual   *float64   `json:"actual"`
+	ActualAt *time.Time `json:"actualAt,omitempty"`
+}
+
+// StoryKeyIsValid validates a story key. Keys are 1-64 letters, numbers, and the characters "_.:#-",
+// starting with a letter, number or "#".
+func StoryKeyIsValid(key string) bool {
+	return storyKeyRx.MatchString(key)
+}
+
+// CommitEstimate keeps the estimate of a story. Committing a story again replaces its estimate, but
+// keeps its actual effort. The story is kept even if it can't be saved, in which case it's saved with
+// the next change.
+func (s *Store) CommitEstimate(story Story) (Story, error) {
+	if !StoryKeyIsValid(story.Key) {
+		return Story{}, ErrInvalidStoryKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	story.EstimatedAt = time.Now().UTC()
+	story.Actual, story.ActualAt = nil, nil
+	if old, found := s.stories[story.Key]; found {
+		story.Actual, story.ActualAt = old.Actual, old.ActualAt
+	}
+	s.stories[story.Key] = &story
+
+	return story, s.save(storiesFile, s.stories)
+}
+
+// SetActual sets the effort a story actually took.
+func (s *Store) SetActual(key string, actual float64) (Story, error) {
+	if actual < 0 || math.IsNaN(actual) || math.IsInf(actual, 0) {
+		return Story{}, ErrInvalidActual
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	story, found := s.stories[key]
+	if !found {
+		return Story{}, ErrStoryNotFound
+	}
+
+	now := time.Now().UTC()
+	story.Actual, story.ActualAt = &actual, &now
+
+	return *story, s.save(storiesFile, s.stories)
+}
+
+// Story returns the story with the key.
+func (s *Store) Story(key string) (Story, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if story, found := s.stories[key]; found {
+		return *story, true
+	}
+
+	return Story{}, false
+}
+
+// Stories returns every story, sorted by key.
+func (s *Store) Stories() []Story {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stories := make([]Story, 0, len(s.stories))
+	for _, story := range s.stories {
+		stories = append(stories, *story)
+	}
+	sort.Slice(stories, func(i, j int) bool {
+		return stories[i].Key < stories[j].Key
+	})
+
+	return stories
+}
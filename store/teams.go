@@ -0,0 +1,285 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/synacor/sibyl/deck"
+	"github.com/synacor/sibyl/game"
+)
+
+// ErrTeamNotFound is returned when there is no team with a name
+var ErrTeamNotFound = errors.New("store: team not found")
+
+// ErrInvalidTeam is returned when a team can't be saved as it is. The error wrapping it says why.
+var ErrInvalidTeam = errors.New("store: invalid team")
+
+// ErrSessionNotFound is returned when a team has no session with an ID
+var ErrSessionNotFound = errors.New("store: session not found")
+
+// Team is a team space: a room which picks up the team's configuration whenever it's opened, and keeps
+// the history of every session held in it.
+type Team struct {
+	// Name is the name of the team, which is also the name of its room
+	Name string `json:"name"`
+
+	// Roster holds the names of the players expected to vote, see game.SetRoster
+	Roster []string `json:"roster"`
+
+	// Deck is the deck the room starts with. Empty starts with the server's default.
+	Deck string `json:"deck"`
+
+	// OutlierSteps and SkipAway override the server's outlier_steps and auto_reveal_skip_away when set
+	OutlierSteps *int  `json:"outlierSteps"`
+	SkipAway     *bool `json:"skipAway"`
+
+	// Insights turns on the room's insights, see game.SetInsights
+	Insights bool `json:"insights"`
+
+	// Sessions holds every time the room was opened, from the first. Each is kept in its own file, see
+	// sessionFile, so that keeping a round doesn't write the whole history of the team.
+	Sessions []Session `json:"sessions,omitempty"`
+}
+
+// Session is the time a team's room was open, and the rounds revealed in it.
+type Session struct {
+	ID      int                 `json:"id"`
+	Started time.Time           `json:"started"`
+	Ended   *time.Time          `json:"ended,omitempty"`
+	Rounds  []game.HistoryRound `json:"rounds"`
+}
+
+// teamKey returns the key of a team, which is not case sensitive, as with rooms.
+func teamKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// teamFile returns the file of the data directory which holds the configuration of a team.
+func teamFile(name string) string {
+	return filepath.Join(teamsDir, url.PathEscape(teamKey(name))+".json")
+}
+
+// sessionsDir returns the directory of the data directory which holds the sessions of a team.
+func sessionsDir(name string) string {
+	return filepath.Join(teamsDir, url.PathEscape(teamKey(name)))
+}
+
+// sessionFile returns the file of the data directory which holds a session of a team.
+func sessionFile(name string, id int) string {
+	return filepath.Join(sessionsDir(name), strconv.Itoa(id)+".json")
+}
+
+// loadTeams reads the file of each team, and the files of its sessions.
+func (s *Store) loadTeams() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, teamsDir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		var t Team
+		if err := s.load(filepath.Join(teamsDir, filepath.Base(path)), &t); err != nil {
+			return err
+		}
+
+		sessions, err := filepath.Glob(filepath.Join(s.dir, sessionsDir(t.Name), "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, path := range sessions {
+			var sess Session
+			if err := s.load(filepath.Join(sessionsDir(t.Name), filepath.Base(path)), &sess); err != nil {
+				return err
+			}
+			t.Sessions = append(t.Sessions, sess)
+		}
+		sort.Slice(t.Sessions, func(i, j int) bool {
+			return t.Sessions[i].ID < t.Sessions[j].ID
+		})
+
+		s.teams[teamKey(t.Name)] = &t
+	}
+
+	return nil
+}
+
+// validate checks the team, and tidies up its roster.
+func (t *Team) validate() error {
+	if !game.RoomNameIsValid(t.Name) {
+		return fmt.Errorf("%w: name is invalid. %s", ErrInvalidTeam, game.RoomNameValidDescription)
+	}
+
+	if _, found := deck.AllDecks[t.Deck]; t.Deck != "" && !found {
+		return fmt.Errorf("%w: there is no deck %q", ErrInvalidTeam, t.Deck)
+	}
+
+	if t.OutlierSteps != nil && *t.OutlierSteps < 0 {
+		return fmt.Errorf("%w: outlierSteps must be at least 0", ErrInvalidTeam)
+	}
+
+	roster := make([]string, 0, len(t.Roster))
+	seen := make(map[string]bool)
+	for _, name := range t.Roster {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		roster = append(roster, name)
+	}
+	t.Roster = roster
+
+	return nil
+}
+
+// copy returns a copy of the team which later changes to the team don't touch.
+func (t *Team) copy() Team {
+	c := *t
+	c.Sessions = append([]Session(nil), t.Sessions...)
+	return c
+}
+
+// Teams returns every team, sorted by name.
+func (s *Store) Teams() []Team {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	teams := make([]Team, 0, len(s.teams))
+	for _, t := range s.teams {
+		teams = append(teams, t.copy())
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		return teamKey(teams[i].Name) < teamKey(teams[j].Name)
+	})
+
+	return teams
+}
+
+// Team returns the team with the name, which is not case sensitive.
+func (s *Store) Team(name string) (Team, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, found := s.teams[teamKey(name)]; found {
+		return t.copy(), true
+	}
+
+	return Team{}, false
+}
+
+// SaveTeam creates a team, or changes the configuration of one. The sessions of a team are kept, and
+// can't be changed. A team which isn't valid returns an error wrapping ErrInvalidTeam.
+func (s *Store) SaveTeam(t Team) (Team, error) {
+	if err := t.validate(); err != nil {
+		return Team{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.Sessions = nil
+	config := t
+	if old, found := s.teams[teamKey(t.Name)]; found {
+		t.Sessions = old.Sessions
+	}
+	s.teams[teamKey(t.Name)] = &t
+
+	return t.copy(), s.save(teamFile(t.Name), &config)
+}
+
+// DeleteTeam deletes a team, along with the history of its sessions.
+func (s *Store) DeleteTeam(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.teams[teamKey(name)]; !found {
+		return ErrTeamNotFound
+	}
+
+	// the sessions go first, so that a team which can't be deleted is still there, rather than leaving
+	// its sessions behind for a new team of the same name
+	if err := os.RemoveAll(filepath.Join(s.dir, sessionsDir(name))); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.dir, teamFile(name))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(s.teams, teamKey(name))
+
+	return nil
+}
+
+// StartSession starts a session of a team, and returns its ID.
+func (s *Store) StartSession(team string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, found := s.teams[teamKey(team)]
+	if !found {
+		return 0, ErrTeamNotFound
+	}
+
+	id := 1
+	if n := len(t.Sessions); n > 0 {
+		id = t.Sessions[n-1].ID + 1
+	}
+	t.Sessions = append(t.Sessions, Session{
+		ID:      id,
+		Started: time.Now().UTC(),
+		Rounds:  make([]game.HistoryRound, 0),
+	})
+
+	return id, s.saveSession(team, &t.Sessions[len(t.Sessions)-1])
+}
+
+// AddRound keeps a round revealed in a session of a team.
+func (s *Store) AddRound(team string, session int, r game.HistoryRound) error {
+	return s.updateSession(team, session, func(sess *Session) {
+		sess.Rounds = append(sess.Rounds, r)
+	})
+}
+
+// EndSession records that a session of a team ended.
+func (s *Store) EndSession(team string, session int) error {
+	return s.updateSession(team, session, func(sess *Session) {
+		now := time.Now().UTC()
+		sess.Ended = &now
+	})
+}
+
+// updateSession changes a session of a team with fn, and saves the session.
+func (s *Store) updateSession(team string, session int, fn func(*Session)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, found := s.teams[teamKey(team)]
+	if !found {
+		return ErrTeamNotFound
+	}
+
+	for i := range t.Sessions {
+		if t.Sessions[i].ID == session {
+			fn(&t.Sessions[i])
+			return s.saveSession(team, &t.Sessions[i])
+		}
+	}
+
+	return ErrSessionNotFound
+}
+
+// saveSession writes the file of a session of a team. The caller must hold mu.
+func (s *Store) saveSession(team string, sess *Session) error {
+	if err := os.MkdirAll(filepath.Join(s.dir, sessionsDir(team)), 0755); err != nil {
+		s.err = err
+		return err
+	}
+
+	return s.save(sessionFile(team, sess.ID), sess)
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/game"
+)
+
+func TestTeams(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir)
+
+	_, err := s.SaveTeam(Team{Name: "bad name!"})
+	assert.True(t, errors.Is(err, ErrInvalidTeam))
+	_, err = s.SaveTeam(Team{Name: "Platform", Deck: "Tarot"})
+	assert.True(t, errors.Is(err, ErrInvalidTeam))
+	steps := -1
+	_, err = s.SaveTeam(Team{Name: "Platform", OutlierSteps: &steps})
+	assert.True(t, errors.Is(err, ErrInvalidTeam))
+
+	team, err := s.SaveTeam(Team{Name: "Platform", Roster: []string{" Alex ", "Bea", "alex", ""}, Deck: "Fibonacci"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Alex", "Bea"}, team.Roster)
+
+	_, err = s.StartSession("Web")
+	assert.Equal(t, ErrTeamNotFound, err)
+	id, err := s.StartSession("platform")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, id)
+	assert.NoError(t, s.AddRound("Platform", id, game.HistoryRound{Estimate: 1, Round: 1, Votes: []game.Vote{{PlayerID: 1, Player: "Alex", Card: "5"}}}))
+	assert.Equal(t, ErrSessionNotFound, s.AddRound("Platform", 2, game.HistoryRound{}))
+	assert.NoError(t, s.EndSession("Platform", id))
+	id, _ = s.StartSession("Platform")
+	assert.Equal(t, 2, id)
+
+	// changing the team keeps its sessions
+	_, err = s.SaveTeam(Team{Name: "Platform", Roster: []string{"Alex"}, Sessions: []Session{{ID: 9}}})
+	assert.NoError(t, err)
+
+	// a round only writes the file of its session
+	b, err := os.ReadFile(filepath.Join(dir, "teams", "platform.json"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "sessions")
+	assert.FileExists(t, filepath.Join(dir, "teams", "platform", "1.json"))
+
+	// the teams are read back from the data directory
+	s, err = Open(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	team, found := s.Team("PLATFORM")
+	assert.True(t, found)
+	assert.Equal(t, []string{"Alex"}, team.Roster)
+	if assert.Len(t, team.Sessions, 2) {
+		assert.NotNil(t, team.Sessions[0].Ended)
+		assert.Nil(t, team.Sessions[1].Ended)
+		assert.Equal(t, []game.Vote{{PlayerID: 1, Player: "Alex", Card: "5"}}, team.Sessions[0].Rounds[0].Votes)
+	}
+
+	s.SaveTeam(Team{Name: "Web"})
+	teams := s.Teams()
+	if assert.Len(t, teams, 2) {
+		assert.Equal(t, "Platform", teams[0].Name)
+		assert.Equal(t, "Web", teams[1].Name)
+	}
+
+	assert.NoError(t, s.DeleteTeam("web"))
+	assert.Equal(t, ErrTeamNotFound, s.DeleteTeam("Web"))
+	s, _ = Open(dir)
+	assert.Len(t, s.Teams(), 1)
+
+	// deleting a team deletes its sessions, so a team of the same name starts afresh
+	assert.NoError(t, s.DeleteTeam("Platform"))
+	assert.NoDirExists(t, filepath.Join(dir, "teams", "platform"))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// serviceName names Sibyl in traces
+const serviceName = "sibyl"
+
+// setupTracing exports traces over OTLP to otlp_endpoint, if it's set. The returned function flushes
+// the spans not yet exported, and must be called before exiting.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	endpoint := viper.GetString("otlp_endpoint")
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if viper.GetBool("otlp_insecure") {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return startTracing(sdktrace.WithBatcher(exporter)), nil
+}
+
+// startTracing traces with a tracer provider which processes spans with processor, and takes part in
+// the traces of requests which carry a W3C traceparent header.
+func startTracing(processor sdktrace.TracerProviderOption) func(context.Context) error {
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(currentVersion()),
+	)
+
+	tp := sdktrace.NewTracerProvider(processor, sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp.Shutdown
+}
+
+// traceRequests traces the requests handled by h. Spans are named after the method and the pattern of
+// mux which the request matches, such as "GET /ws", so that rooms don't each get their own name.
+func traceRequests(mux *http.ServeMux, h http.Handler) http.Handler {
+	return otelhttp.NewHandler(h, "http", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return r.Method + " " + pattern
+	}))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/server"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetupTracingWithoutEndpoint(t *testing.T) {
+	shutdown, err := setupTracing(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestTraceRequests(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	sr := tracetest.NewSpanRecorder()
+	prev, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	shutdown := startTracing(sdktrace.WithSpanProcessor(sr))
+	defer func() {
+		shutdown(context.Background())
+		otel.SetTracerProvider(prev)
+		otel.SetTextMapPropagator(prevPropagator)
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/room/", func(w http.ResponseWriter, r *http.Request) {})
+	h := traceRequests(mux, server.LogRequests(mux))
+
+	// the trace of the caller is continued
+	r := httptest.NewRequest(http.MethodGet, "/room/Test", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := sr.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "GET /room/", spans[0].Name())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+		assert.Equal(t, "sibyl", resourceValue(spans[0], "service.name"))
+	}
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", hook.LastEntry().Data["trace_id"])
+}
+
+// resourceValue returns the value of a resource attribute of a span.
+func resourceValue(span sdktrace.ReadOnlySpan, key string) string {
+	for _, kv := range span.Resource().Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.AsString()
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,393 @@
+// Package tui renders a Sibyl room as text, and lets a player vote with the keyboard.
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/synacor/sibyl/deck"
+	"github.com/synacor/sibyl/roomstate"
+)
+
+// Terminal escape sequences
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	reverse     = "\x1b[7m"
+	bold        = "\x1b[1m"
+	reset       = "\x1b[0m"
+)
+
+// Keys, as read from a terminal in raw mode
+const (
+	keyCtrlC      = 3
+	keyEnter      = '\r'
+	keyEscape     = 27
+	keyRightArrow = "\x1b[C"
+	keyLeftArrow  = "\x1b[D"
+)
+
+// escapeWait is how long to wait for the rest of an escape sequence, such as an arrow key, which can
+// arrive over more than one read from a slow connection
+const escapeWait = 50 * time.Millisecond
+
+// Room is what the player can do in a room.
+type Room interface {
+	// SelectDeckCard plays the card at the given index of the named deck, which is the one the player
+	// chose from. The room refuses it if it has switched decks since.
+	SelectDeckCard(card int, deck string) error
+	Reveal() error
+	Reset() error
+	Revote() error
+}
+
+// Stats summarizes the cards of a revealed round.
+type Stats struct {
+	Votes int
+
+	// Average is the average of the numeric cards. It is only set if HasAverage is.
+	Average    float64
+	HasAverage bool
+
+	// Lowest and Highest are the lowest and highest estimates, which are empty if there were none.
+	Lowest  string
+	Highest string
+
+	// Consensus is true if everyone who gave an estimate agreed.
+	Consensus bool
+
+	// Counts holds how many times each card was played, in the order of the deck.
+	Counts []CardCount
+}
+
+// CardCount is how many times a card was played.
+type CardCount struct {
+	Card  string
+	Count int
+}
+
+// NewStats returns the stats for the cards of a round.
+func NewStats(cards []roomstate.Card, d *deck.Deck) Stats {
+	s := Stats{Votes: len(cards)}
+
+	counts := make(map[int]int)
+	lowest, highest := -1, -1
+	var sum float64
+	var numeric int
+	for _, c := range cards {
+		counts[c.Card]++
+		if !d.IsEstimate(c.Card) {
+			continue
+		}
+
+		if lowest == -1 || c.Card < lowest {
+			lowest = c.Card
+		}
+		if c.Card > highest {
+			highest = c.Card
+		}
+
+		if f, err := strconv.ParseFloat(d.Cards[c.Card], 64); err == nil {
+			sum += f
+			numeric++
+		}
+	}
+
+	if lowest != -1 {
+		s.Lowest, s.Highest = d.Cards[lowest], d.Cards[highest]
+		s.Consensus = lowest == highest
+	}
+
+	if numeric > 0 {
+		s.Average = sum / float64(numeric)
+		s.HasAverage = true
+	}
+
+	indexes := make([]int, 0, len(counts))
+	for i := range counts {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		card, err := d.GetCard(i)
+		if err != nil {
+			continue
+		}
+		s.Counts = append(s.Counts, CardCount{card, counts[i]})
+	}
+
+	return s
+}
+
+// View is what the player sees: the state of the room, and the card they are pointing at.
+type View struct {
+	Title  string
+	State  roomstate.State
+	Cursor int
+
+	// Chosen is the card the player voted for in this round, or -1
+	Chosen int
+
+	// Status is a line shown at the bottom, such as an error
+	Status string
+}
+
+// NewView returns a view of a room that hasn't been heard from yet.
+func NewView(title string) *View {
+	return &View{Title: title, Chosen: -1}
+}
+
+// Deck returns the deck of the room, or the default deck if the room's deck is unknown.
+func (v *View) Deck() *deck.Deck {
+	if d, found := deck.AllDecks[v.State.Deck]; found {
+		return d
+	}
+
+	return deck.ModifiedFibonacci
+}
+
+// Update replaces the state of the room.
+func (v *View) Update(s roomstate.State) {
+	if s.Reset || s.Round != v.State.Round || s.Deck != v.State.Deck {
+		v.Chosen = -1
+	}
+
+	v.State = s
+	if n := len(v.Deck().Cards); v.Cursor >= n {
+		v.Cursor = n - 1
+	}
+}
+
+// Render returns the view as text. Lines end with "\r\n", since a terminal in raw mode doesn't return
+// to the start of the line on its own.
+func (v *View) Render() string {
+	var b strings.Builder
+	line := func(format string, a ...interface{}) {
+		fmt.Fprintf(&b, format, a...)
+		b.WriteString("\r\n")
+	}
+
+	s := v.State
+	d := v.Deck()
+
+	line("%sSibyl · %s%s · round %d · %s", bold, v.Title, reset, s.Round, d.Name)
+	if s.Topic != "" {
+		line("Topic: %s", s.Topic)
+	} else {
+		line("Topic: (none)")
+	}
+	line("")
+
+	spectators := make(map[int]bool)
+	for _, id := range s.Spectators {
+		spectators[id] = true
+	}
+
+	cards := make(map[int]roomstate.Card)
+	for _, c := range s.Cards {
+		cards[c.PlayerID] = c
+	}
+
+	ids := make([]int, 0, len(s.Players))
+	for id := range s.Players {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return strings.ToLower(s.Players[ids[i]]) < strings.ToLower(s.Players[ids[j]])
+	})
+
+	line("Players")
+	for _, id := range ids {
+		name := s.Players[id]
+		if name == s.Username {
+			name += " (you)"
+		}
+
+		status := "waiting"
+		if spectators[id] {
+			status = "spectating"
+		} else if c, voted := cards[id]; voted {
+			status = "voted"
+			if s.Revealed {
+				status, _ = d.GetCard(c.Card)
+				if c.Outlier {
+					status += " !"
+				}
+			}
+		}
+		if p := s.Presence[id]; p != "" {
+			status += " (" + p + ")"
+		}
+
+		line("  %-28s %s", name, status)
+	}
+	line("")
+
+	var hand strings.Builder
+	for i, card := range d.Cards {
+		label := " " + card + " "
+		if i == v.Chosen {
+			label = "[" + card + "]"
+		}
+
+		if i == v.Cursor {
+			hand.WriteString(reverse + label + reset)
+		} else {
+			hand.WriteString(label)
+		}
+		hand.WriteString(" ")
+	}
+	line("%s", hand.String())
+	line("")
+
+	if s.Revealed {
+		st := NewStats(s.Cards, d)
+		var parts []string
+		if st.HasAverage {
+			parts = append(parts, fmt.Sprintf("average %.1f", st.Average))
+		}
+		if st.Lowest != "" {
+			parts = append(parts, "lowest "+st.Lowest, "highest "+st.Highest)
+		}
+		if st.Consensus {
+			parts = append(parts, "consensus!")
+		}
+		line("%sResults%s: %d votes · %s", bold, reset, st.Votes, strings.Join(parts, " · "))
+
+		var counts []string
+		for _, c := range st.Counts {
+			counts = append(counts, fmt.Sprintf("%s×%d", c.Card, c.Count))
+		}
+		line("  %s", strings.Join(counts, "  "))
+		line("")
+	}
+
+	line("←/→ choose · enter vote · r reveal · v re-vote · n new round · q quit")
+	if v.Status != "" {
+		line("%s", v.Status)
+	}
+
+	return b.String()
+}
+
+// HandleKey acts on a key press. It returns false once the player wants to quit.
+func (v *View) HandleKey(key string, room Room) bool {
+	var err error
+	n := len(v.Deck().Cards)
+
+	switch key {
+	case "q", string(rune(keyCtrlC)):
+		return false
+	case keyLeftArrow, "h":
+		v.Cursor = (v.Cursor + n - 1) % n
+	case keyRightArrow, "l":
+		v.Cursor = (v.Cursor + 1) % n
+	case string(rune(keyEnter)), " ":
+		if err = room.SelectDeckCard(v.Cursor, v.Deck().Name); err == nil {
+			v.Chosen = v.Cursor
+		}
+	case "r":
+		err = room.Reveal()
+	case "v":
+		err = room.Revote()
+	case "n":
+		err = room.Reset()
+	}
+
+	v.Status = ""
+	if err != nil {
+		v.Status = "Error: " + err.Error()
+	}
+
+	return true
+}
+
+// Run shows the room on out, and acts on the keys read from in, until the player quits or updates is
+// closed. The terminal should be in raw mode.
+func Run(title string, room Room, updates <-chan roomstate.State, in io.Reader, out io.Writer) error {
+	v := NewView(title)
+	keys := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go readKeys(in, keys, done)
+
+	fmt.Fprint(out, clearScreen+"Connecting...\r\n")
+	for {
+		select {
+		case s, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			v.Update(s)
+		case key, ok := <-keys:
+			if !ok || !v.HandleKey(key, room) {
+				return nil
+			}
+		}
+
+		if _, err := io.WriteString(out, clearScreen+v.Render()); err != nil {
+			return err
+		}
+	}
+}
+
+// readKeys sends each key read from in, with arrow keys as a single key. It closes keys once in ends,
+// and stops once done is closed.
+func readKeys(in io.Reader, keys chan<- string, done <-chan struct{}) {
+	defer close(keys)
+
+	bytes := make(chan byte)
+	go readBytes(in, bytes, done)
+
+	for b := range bytes {
+		key := string(rune(b))
+
+		// an arrow key is escape and two more bytes, which may not have arrived yet
+		for b == keyEscape && len(key) < len(keyLeftArrow) {
+			next, ok := waitForByte(bytes)
+			if !ok {
+				break
+			}
+			key += string(rune(next))
+		}
+
+		select {
+		case keys <- key:
+		case <-done:
+			return
+		}
+	}
+}
+
+// readBytes sends each byte read from in. It closes bytes once in ends, and stops once done is closed.
+func readBytes(in io.Reader, bytes chan<- byte, done <-chan struct{}) {
+	defer close(bytes)
+
+	r := bufio.NewReader(in)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+
+		select {
+		case bytes <- b:
+		case <-done:
+			return
+		}
+	}
+}
+
+// waitForByte returns the next byte, unless it takes longer than escapeWait to arrive.
+func waitForByte(bytes <-chan byte) (byte, bool) {
+	select {
+	case b, ok := <-bytes:
+		return b, ok
+	case <-time.After(escapeWait):
+		return 0, false
+	}
+}
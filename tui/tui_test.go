@@ -0,0 +1,174 @@
+package tui
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/synacor/sibyl/deck"
+	"github.com/synacor/sibyl/roomstate"
+)
+
+func TestNewStats(t *testing.T) {
+	d := deck.ModifiedFibonacci
+	s := NewStats([]roomstate.Card{{Card: 3}, {Card: 5}, {Card: 3}, {Card: 10}}, d)
+	assert.Equal(t, 4, s.Votes)
+	assert.True(t, s.HasAverage)
+	assert.InDelta(t, 14.0/3, s.Average, 0.001)
+	assert.Equal(t, "3", s.Lowest)
+	assert.Equal(t, "8", s.Highest)
+	assert.False(t, s.Consensus)
+	assert.Equal(t, []CardCount{{"3", 2}, {"8", 1}, {"?", 1}}, s.Counts)
+
+	s = NewStats([]roomstate.Card{{Card: 2}, {Card: 2}, {Card: 11}}, d)
+	assert.True(t, s.Consensus)
+
+	// shirt sizes can't be averaged
+	s = NewStats([]roomstate.Card{{Card: 1}, {Card: 3}}, deck.TShirtSizes)
+	assert.False(t, s.HasAverage)
+	assert.Equal(t, "S", s.Lowest)
+	assert.Equal(t, "L", s.Highest)
+
+	s = NewStats([]roomstate.Card{}, d)
+	assert.Equal(t, 0, s.Votes)
+	assert.False(t, s.HasAverage)
+	assert.False(t, s.Consensus)
+}
+
+func TestRender(t *testing.T) {
+	v := NewView("Test")
+	v.Update(roomstate.State{
+		Topic:      "Login page",
+		Players:    map[int]string{1: "One", 2: "Two", 3: "Three"},
+		Cards:      []roomstate.Card{{Card: 1, PlayerID: 1}},
+		Deck:       deck.ModifiedFibonacci.Name,
+		Username:   "One",
+		Round:      1,
+		Spectators: []int{3},
+		Presence:   map[int]string{2: "away"},
+	})
+
+	out := v.Render()
+	assert.Contains(t, out, "Topic: Login page")
+	assert.Contains(t, out, "One (you)")
+	assert.Regexp(t, `One \(you\) +voted`, out)
+	assert.Regexp(t, `Two +waiting \(away\)`, out)
+	assert.Regexp(t, `Three +spectating`, out)
+	assert.NotContains(t, out, "Results")
+	assert.True(t, strings.HasSuffix(out, "\r\n"))
+
+	v.State.Revealed = true
+	v.State.Cards = []roomstate.Card{{Card: 1, PlayerID: 1, Outlier: true}, {Card: 4, PlayerID: 2, Outlier: true}}
+	out = v.Render()
+	assert.Regexp(t, `One \(you\) +1 !`, out)
+	assert.Contains(t, out, "Results")
+	assert.Contains(t, out, "average 3.0")
+}
+
+func TestHandleKey(t *testing.T) {
+	v := NewView("Test")
+	v.Update(roomstate.State{Deck: deck.TShirtSizes.Name, Round: 1})
+	r := &roomTest{}
+
+	assert.True(t, v.HandleKey(keyLeftArrow, r))
+	assert.Equal(t, len(deck.TShirtSizes.Cards)-1, v.Cursor)
+	assert.True(t, v.HandleKey(keyRightArrow, r))
+	assert.True(t, v.HandleKey("l", r))
+	assert.Equal(t, 1, v.Cursor)
+
+	assert.True(t, v.HandleKey("\r", r))
+	assert.Equal(t, []string{"select 1 of T-Shirt Sizes"}, r.actions)
+	assert.Equal(t, 1, v.Chosen)
+
+	v.HandleKey("r", r)
+	v.HandleKey("v", r)
+	v.HandleKey("n", r)
+	assert.Equal(t, []string{"select 1 of T-Shirt Sizes", "reveal", "revote", "reset"}, r.actions)
+
+	// the vote is forgotten once a new round starts
+	v.Update(roomstate.State{Deck: deck.TShirtSizes.Name, Round: 2})
+	assert.Equal(t, -1, v.Chosen)
+
+	r.err = errors.New("not connected")
+	v.HandleKey("r", r)
+	assert.Equal(t, "Error: not connected", v.Status)
+
+	// escape on its own doesn't quit, since it starts the arrow keys
+	assert.True(t, v.HandleKey(string(rune(keyEscape)), r))
+	assert.False(t, v.HandleKey("q", r))
+}
+
+func TestReadKeys(t *testing.T) {
+	in, w := io.Pipe()
+	keys := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go readKeys(in, keys, done)
+
+	// an arrow key split over two reads is still one key
+	go func() {
+		w.Write([]byte("\x1b"))
+		time.Sleep(escapeWait / 5)
+		w.Write([]byte("[D"))
+		w.Write([]byte("x"))
+		w.Close()
+	}()
+
+	assert.Equal(t, keyLeftArrow, <-keys)
+	assert.Equal(t, "x", <-keys)
+	_, open := <-keys
+	assert.False(t, open)
+}
+
+func TestRun(t *testing.T) {
+	updates := make(chan roomstate.State, 1)
+	updates <- roomstate.State{Topic: "Topic", Deck: deck.ModifiedFibonacci.Name}
+	in, w := io.Pipe()
+	var out bytes.Buffer
+	r := &roomTest{}
+
+	done := make(chan error)
+	go func() {
+		done <- Run("Test", r, updates, in, &out)
+	}()
+
+	w.Write([]byte(keyRightArrow))
+	w.Write([]byte(" "))
+	w.Write([]byte("q"))
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"select 1 of Modified Fibonacci"}, r.actions)
+	assert.Contains(t, out.String(), "Topic: Topic")
+}
+
+type roomTest struct {
+	actions []string
+	err     error
+}
+
+func (r *roomTest) act(action string) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.actions = append(r.actions, action)
+	return nil
+}
+
+func (r *roomTest) SelectDeckCard(card int, deck string) error {
+	return r.act("select " + string(rune('0'+card)) + " of " + deck)
+}
+
+func (r *roomTest) Reveal() error {
+	return r.act("reveal")
+}
+
+func (r *roomTest) Reset() error {
+	return r.act("reset")
+}
+
+func (r *roomTest) Revote() error {
+	return r.act("revote")
+}
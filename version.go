@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// version is the release sibyl was built from. Releases set it with -ldflags "-X main.version=v1.2.3".
+var version string
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of sibyl, and how it was built",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		info, _ := debug.ReadBuildInfo()
+		fmt.Fprint(cmd.OutOrStdout(), buildInfo(info))
+	},
+}
+
+// currentVersion returns the version of this build.
+func currentVersion() string {
+	info, _ := debug.ReadBuildInfo()
+	return buildVersion(info)
+}
+
+// buildVersion returns the version of a build, or "devel" if it isn't a release. info may be nil.
+func buildVersion(info *debug.BuildInfo) string {
+	if version != "" {
+		return version
+	}
+	if info != nil && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	return "devel"
+}
+
+// buildInfo describes the version, Go toolchain and commit of a build. info may be nil.
+func buildInfo(info *debug.BuildInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "sibyl %s\n", buildVersion(info))
+	fmt.Fprintf(&b, "go:     %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	if info == nil {
+		return b.String()
+	}
+
+	settings := make(map[string]string)
+	for _, s := range info.Settings {
+		settings[s.Key] = s.Value
+	}
+
+	if commit := settings["vcs.revision"]; commit != "" {
+		if settings["vcs.modified"] == "true" {
+			commit += " (modified)"
+		}
+		fmt.Fprintf(&b, "commit: %s\n", commit)
+	}
+	if built := settings["vcs.time"]; built != "" {
+		fmt.Fprintf(&b, "date:   %s\n", built)
+	}
+
+	return b.String()
+}
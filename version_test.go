@@ -0,0 +1,29 @@
+package main
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildInfo(t *testing.T) {
+	assert.Regexp(t, `^sibyl devel\ngo: +go\S+ \S+/\S+\n$`, buildInfo(nil))
+
+	info := &debug.BuildInfo{
+		Main: debug.Module{Version: "v1.2.3"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2024-01-02T03:04:05Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	out := buildInfo(info)
+	assert.Contains(t, out, "sibyl v1.2.3\n")
+	assert.Contains(t, out, "commit: abc123 (modified)\n")
+	assert.Contains(t, out, "date:   2024-01-02T03:04:05Z\n")
+
+	version = "v2.0.0"
+	defer func() { version = "" }()
+	assert.Contains(t, buildInfo(info), "sibyl v2.0.0\n")
+}